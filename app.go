@@ -0,0 +1,104 @@
+package main
+
+import (
+	"gin-freemarket/carriers"
+	"gin-freemarket/controllers"
+	"gin-freemarket/infra"
+	"gin-freemarket/payments"
+	"gin-freemarket/services"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// サーバーを構成するもの一式
+type app struct {
+	router        *gin.Engine
+	healthService services.IHealthService
+	// バックグラウンド処理。起動・停止はmain()で行う
+	reservationSweeper    *services.ReservationSweeper
+	orderAutoCompleter    *services.OrderAutoCompleter
+	pendingOrderCanceler  *services.PendingOrderCanceler
+	idempotencyKeySweeper *services.IdempotencyKeySweeper
+}
+
+// リポジトリからサービス・コントローラを組み立て、ルーティングまで済ませる
+// dbChecksはreadyzで確認するDBの状態（メモリで動かすときは空）
+func newApp(cfg *infra.Config, repos repositorySet, dbChecks []services.HealthCheck, logger *slog.Logger) *app {
+	itemService := services.NewItemService(repos.item, repos.reservation, repos.category, cfg.Market.HoldTTL)
+	itemController := controllers.NewItemController(itemService)
+	categoryService := services.NewCategoryService(repos.category)
+	categoryController := controllers.NewCategoryController(categoryService)
+
+	authService := services.NewAuthService(repos.auth, cfg.Auth.SecretKey.Value(), cfg.Auth.TokenTTL)
+	authController := controllers.NewAuthController(authService)
+
+	// 期限切れホールドの掃除はバックグラウンドで定期的に行う
+	reservationSweeper := services.NewReservationSweeper(repos.reservation, cfg.Workers.Interval)
+
+	offerService := services.NewOfferService(repos.offer, repos.item, cfg.Market.OfferTTL)
+	offerController := controllers.NewOfferController(offerService)
+
+	addressService := services.NewAddressService(repos.address)
+	addressController := controllers.NewAddressController(addressService)
+
+	// 決済プロバイダ。本番のプロバイダに切り替えるときはここを差し替える
+	paymentGateway := payments.NewFakePaymentGateway()
+	fees := services.FeeSchedule{RateBasisPoints: cfg.Market.FeeRateBps, Minimum: cfg.Market.FeeMinimum}
+	orderService := services.NewOrderService(repos.order, addressService, paymentGateway, fees)
+	orderController := controllers.NewOrderController(orderService)
+	walletService := services.NewWalletService(repos.ledger)
+	walletController := controllers.NewWalletController(walletService)
+	reviewService := services.NewReviewService(repos.review, orderService)
+	reviewController := controllers.NewReviewController(reviewService)
+
+	// 配達完了から猶予期間が過ぎた注文は自動で取引完了にする
+	orderAutoCompleter := services.NewOrderAutoCompleter(orderService, cfg.Market.AutoCompleteAfter, cfg.Workers.Interval)
+	// 支払われないまま放置された注文はキャンセルし、商品を他のユーザーが買えるようにする
+	pendingOrderCanceler := services.NewPendingOrderCanceler(orderService, cfg.Market.PendingOrderTTL, cfg.Workers.Interval)
+
+	// 対応している運送会社。本番の運送会社に対応するときはここに追加する
+	shipmentService := services.NewShipmentService(repos.shipment, repos.order, []carriers.Carrier{carriers.NewFakeCarrier()})
+	shipmentController := controllers.NewShipmentController(shipmentService)
+
+	// Idempotency-Keyを付けたPOSTのレスポンスを覚えておき、再送には同じレスポンスを返す
+	idempotencyService := services.NewIdempotencyService(repos.idempotency, cfg.Server.IdempotencyKeyTTL, cfg.Server.IdempotencyKeyLease)
+	idempotencyKeySweeper := services.NewIdempotencyKeySweeper(repos.idempotency, cfg.Workers.Interval)
+
+	webhookController := controllers.NewWebhookController(orderService, shipmentService, cfg.Payments.WebhookSecret.Value(), cfg.Shipments.WebhookSecret.Value())
+
+	// readyzで確認する依存先。どれか1つでも失敗すればトラフィックを受けない
+	healthService := services.NewHealthService(append(dbChecks,
+		services.HealthCheck{Name: "reservation_sweeper", Check: workerCheck(reservationSweeper.Running)},
+		services.HealthCheck{Name: "order_auto_completer", Check: workerCheck(orderAutoCompleter.Running)},
+		services.HealthCheck{Name: "pending_order_canceler", Check: workerCheck(pendingOrderCanceler.Running)},
+		services.HealthCheck{Name: "idempotency_key_sweeper", Check: workerCheck(idempotencyKeySweeper.Running)},
+	), cfg.Server.HealthCheckTimeout, services.NewBuildInfo(version, commit))
+	healthController := controllers.NewHealthController(healthService)
+
+	router := newRouter(routerDeps{
+		logger:             logger,
+		requestTimeout:     cfg.Server.RequestTimeout,
+		authService:        authService,
+		idempotencyService: idempotencyService,
+		itemController:     itemController,
+		categoryController: categoryController,
+		authController:     authController,
+		offerController:    offerController,
+		orderController:    orderController,
+		addressController:  addressController,
+		walletController:   walletController,
+		reviewController:   reviewController,
+		shipmentController: shipmentController,
+		webhookController:  webhookController,
+		healthController:   healthController,
+	})
+	return &app{
+		router:                router,
+		healthService:         healthService,
+		reservationSweeper:    reservationSweeper,
+		orderAutoCompleter:    orderAutoCompleter,
+		pendingOrderCanceler:  pendingOrderCanceler,
+		idempotencyKeySweeper: idempotencyKeySweeper,
+	}
+}
@@ -0,0 +1,22 @@
+package carriers
+
+import "time"
+
+// 運送会社から届いた配送状況の更新。Statusはmodels.ShipmentStatus*のいずれか
+type TrackingUpdate struct {
+	EventId        string
+	TrackingNumber string
+	Status         string
+	Description    string
+	OccurredAt     time.Time
+}
+
+// 運送会社ごとの違い（追跡番号の形式・Webhookのペイロード）を吸収するインタフェース
+// 新しい運送会社に対応するときは、これを実装してmain.goで登録する
+type Carrier interface {
+	// 注文やWebhookで運送会社を指定するときの名前（"fake"など）
+	Name() string
+	ValidateTrackingNumber(trackingNumber string) error
+	// Webhookのボディを共通の形式に変換する。1回のWebhookに複数の更新が含まれていてもよい
+	ParseWebhook(body []byte) ([]TrackingUpdate, error)
+}
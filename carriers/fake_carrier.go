@@ -0,0 +1,69 @@
+package carriers
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-freemarket/models"
+	"regexp"
+	"time"
+)
+
+// ローカル開発・テスト用の運送会社
+// Webhookのボディは以下の形式で、statusには共通のステータスをそのまま書く
+//
+//	{"events": [{"id": "evt_1", "tracking_number": "FAKE000000001", "status": "delivered", "description": "...", "occurred_at": "2006-01-02T15:04:05Z"}]}
+type FakeCarrier struct{}
+
+func NewFakeCarrier() Carrier {
+	return &FakeCarrier{}
+}
+
+var fakeTrackingNumber = regexp.MustCompile(`^FAKE[0-9]{9}$`)
+
+var fakeStatuses = map[string]bool{
+	models.ShipmentStatusInTransit:      true,
+	models.ShipmentStatusOutForDelivery: true,
+	models.ShipmentStatusDelivered:      true,
+	models.ShipmentStatusException:      true,
+}
+
+func (c *FakeCarrier) Name() string {
+	return "fake"
+}
+
+func (c *FakeCarrier) ValidateTrackingNumber(trackingNumber string) error {
+	if !fakeTrackingNumber.MatchString(trackingNumber) {
+		return errors.New("Invalid tracking number")
+	}
+	return nil
+}
+
+func (c *FakeCarrier) ParseWebhook(body []byte) ([]TrackingUpdate, error) {
+	var payload struct {
+		Events []struct {
+			ID             string    `json:"id"`
+			TrackingNumber string    `json:"tracking_number"`
+			Status         string    `json:"status"`
+			Description    string    `json:"description"`
+			OccurredAt     time.Time `json:"occurred_at"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	updates := []TrackingUpdate{}
+	for _, v := range payload.Events {
+		if v.ID == "" || v.TrackingNumber == "" || !fakeStatuses[v.Status] {
+			return nil, errors.New("Invalid tracking event")
+		}
+		updates = append(updates, TrackingUpdate{
+			EventId:        v.ID,
+			TrackingNumber: v.TrackingNumber,
+			Status:         v.Status,
+			Description:    v.Description,
+			OccurredAt:     v.OccurredAt,
+		})
+	}
+	return updates, nil
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IAddressController interface {
+	FindAll(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Update(ctx *gin.Context)
+	Delete(ctx *gin.Context)
+}
+
+type AddressController struct {
+	service services.IAddressService
+}
+
+func NewAddressController(service services.IAddressService) IAddressController {
+	return &AddressController{service: service}
+}
+
+// GET /me/addresses
+func (c *AddressController) FindAll(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	addresses, err := c.service.FindAll(ctx.Request.Context(), user.ID)
+	if err != nil {
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": addresses})
+}
+
+// POST /me/addresses
+func (c *AddressController) Create(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	var input dto.AddressInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	newAddress, err := c.service.Create(ctx.Request.Context(), user.ID, input)
+	if err != nil {
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"data": newAddress})
+}
+
+// PUT /me/addresses/:id
+func (c *AddressController) Update(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	addressId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var input dto.AddressInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	updatedAddress, err := c.service.Update(ctx.Request.Context(), uint(addressId), user.ID, input)
+	if err != nil {
+		if err.Error() == "Address is not found" {
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": updatedAddress})
+}
+
+// DELETE /me/addresses/:id
+func (c *AddressController) Delete(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	addressId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	err = c.service.Delete(ctx.Request.Context(), uint(addressId), user.ID)
+	if err != nil {
+		if err.Error() == "Address is not found" {
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
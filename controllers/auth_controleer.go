@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"gin-freemarket/dto"
+	"gin-freemarket/problem"
 	"gin-freemarket/services"
 	"net/http"
 
@@ -24,13 +26,17 @@ func NewAuthController(service services.IAuthService) IAuthController {
 func (c *AuthController) Signup(ctx *gin.Context) {
 	var input dto.SignupInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Validation(ctx, err)
 		return
 	}
 
-	err := c.service.Signup(input.Email, input.Password)
+	err := c.service.Signup(ctx.Request.Context(), input.Email, input.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to Create User"})
+		if errors.Is(err, services.ErrEmailAlreadyExists) {
+			problem.Respond(ctx, http.StatusConflict, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
 		return
 	}
 
@@ -41,17 +47,21 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	var input dto.LoginInput
 
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Validation(ctx, err)
 		return
 	}
 
-	token, err := c.service.Login(input.Email, input.Password)
+	token, err := c.service.Login(ctx.Request.Context(), input.Email, input.Password)
 	if err != nil {
 		if err.Error() == "User not found" {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			problem.Respond(ctx, http.StatusUnauthorized, err.Error())
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Internal(ctx, err)
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"token": token})
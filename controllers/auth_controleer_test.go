@@ -0,0 +1,111 @@
+package controllers_test
+
+import (
+	"errors"
+	"gin-freemarket/controllers"
+	"gin-freemarket/mocks"
+	"gin-freemarket/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuthController(t *testing.T) {
+	token := "signed-token"
+
+	cases := []struct {
+		name    string
+		handler func(c controllers.IAuthController) gin.HandlerFunc
+		body    string
+		// サービスに期待する呼び出し。nilならサービスは呼ばれない
+		expect func(s *mocks.MockIAuthService)
+		status int
+		detail string
+		fields []string
+	}{
+		{
+			name:    "Signup",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Signup },
+			body:    `{"email":"alice@example.com","password":"password123"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Signup(gomock.Any(), "alice@example.com", "password123").Return(nil)
+			},
+			status: http.StatusCreated,
+		},
+		{
+			name:    "Signup email already registered",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Signup },
+			body:    `{"email":"alice@example.com","password":"password123"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Signup(gomock.Any(), "alice@example.com", "password123").Return(services.ErrEmailAlreadyExists)
+			},
+			status: http.StatusConflict, detail: services.ErrEmailAlreadyExists.Error(),
+		},
+		{
+			name:    "Signup service error",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Signup },
+			body:    `{"email":"alice@example.com","password":"password123"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Signup(gomock.Any(), "alice@example.com", "password123").Return(errors.New("connection refused"))
+			},
+			status: http.StatusInternalServerError, detail: "Unexpected error",
+		},
+		{
+			name:    "Signup validation error",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Signup },
+			body:    `{"email":"alice","password":"short"}`,
+			status:  http.StatusBadRequest, fields: []string{"email", "password"},
+		},
+		{
+			name:    "Login",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Login },
+			body:    `{"email":"alice@example.com","password":"password123"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Login(gomock.Any(), "alice@example.com", "password123").Return(&token, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name:    "Login wrong password",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Login },
+			body:    `{"email":"alice@example.com","password":"password456"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Login(gomock.Any(), "alice@example.com", "password456").Return(nil, services.ErrInvalidCredentials)
+			},
+			status: http.StatusUnauthorized, detail: services.ErrInvalidCredentials.Error(),
+		},
+		{
+			name:    "Login unknown user",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Login },
+			body:    `{"email":"bob@example.com","password":"password123"}`,
+			expect: func(s *mocks.MockIAuthService) {
+				s.EXPECT().Login(gomock.Any(), "bob@example.com", "password123").Return(nil, errors.New("User not found"))
+			},
+			status: http.StatusNotFound, detail: "User not found",
+		},
+		{
+			name:    "Login validation error",
+			handler: func(c controllers.IAuthController) gin.HandlerFunc { return c.Login },
+			body:    `{"email":"alice@example.com"}`,
+			status:  http.StatusBadRequest, fields: []string{"password"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			service := mocks.NewMockIAuthService(ctrl)
+			if tc.expect != nil {
+				tc.expect(service)
+			}
+			controller := controllers.NewAuthController(service)
+
+			rec := serve(t, "/auth", tc.handler(controller), http.MethodPost, "/auth", nil, tc.body)
+			assertResponse(t, rec, tc.status, tc.detail, tc.fields...)
+			if tc.status == http.StatusOK && rec.Body.String() != `{"token":"signed-token"}` {
+				t.Fatalf("body = %s, want the token", rec.Body.String())
+			}
+		})
+	}
+}
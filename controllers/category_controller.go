@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ICategoryController interface {
+	FindAll(ctx *gin.Context)
+}
+
+type CategoryController struct {
+	service services.ICategoryService
+}
+
+func NewCategoryController(service services.ICategoryService) ICategoryController {
+	return &CategoryController{service: service}
+}
+
+// GET /categories
+func (c *CategoryController) FindAll(ctx *gin.Context) {
+	categories, err := c.service.FindAll(ctx.Request.Context())
+	if err != nil {
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": categories})
+}
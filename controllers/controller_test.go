@@ -0,0 +1,83 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/validation"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	// 検証エラーの項目名をJSONのキー名にする（main.goと同じ）
+	if err := validation.Setup(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// AuthMiddlewareの代わりにctxへ詰めるログインユーザー
+var testUser = &models.User{Model: gorm.Model{ID: 7}, Email: "alice@example.com"}
+
+// handlerだけを登録したルーターにリクエストを送る。routeはgin形式のパス（例: /items/:id）
+func serve(t *testing.T, route string, handler gin.HandlerFunc, method string, path string, header http.Header, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.Handle(method, route, func(ctx *gin.Context) {
+		ctx.Set("user", testUser)
+	}, handler)
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	// Content-Typeもheaderで上書きできる
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+// ステータスコードと、エラーならproblem+jsonのdetail・項目ごとのエラーを確認する
+// detailが空なら比較しない
+func assertResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, detail string, fields ...string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, status, rec.Body.String())
+	}
+	if status < http.StatusBadRequest {
+		return
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, problem.ContentType) {
+		t.Fatalf("Content-Type = %q, want %s", ct, problem.ContentType)
+	}
+	var details problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("failed to decode problem details: %v", err)
+	}
+	if detail != "" && details.Detail != detail {
+		t.Fatalf("detail = %q, want %q", details.Detail, detail)
+	}
+	for _, field := range fields {
+		if !slices.ContainsFunc(details.Errors, func(e validation.FieldError) bool { return e.Field == field }) {
+			t.Fatalf("errors = %+v, want an error for %q", details.Errors, field)
+		}
+	}
+}
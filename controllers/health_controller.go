@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"gin-freemarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IHealthController interface {
+	Live(ctx *gin.Context)
+	Ready(ctx *gin.Context)
+	Version(ctx *gin.Context)
+}
+
+type HealthController struct {
+	service services.IHealthService
+}
+
+func NewHealthController(service services.IHealthService) IHealthController {
+	return &HealthController{service: service}
+}
+
+// GET /healthz
+// プロセスが応答できることだけを返す。依存先の状態は見ない（DBが落ちても再起動はさせたくないため）
+func (c *HealthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GET /readyz
+func (c *HealthController) Ready(ctx *gin.Context) {
+	checks, ready := c.service.Ready(ctx.Request.Context())
+	if !ready {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
+
+// GET /version
+func (c *HealthController) Version(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, c.service.BuildInfo())
+}
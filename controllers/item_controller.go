@@ -4,12 +4,20 @@ package controllers
 //
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/patch"
+	"gin-freemarket/problem"
 	"gin-freemarket/services"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // Itemコントローラのインタフェース
@@ -19,7 +27,10 @@ type IItemController interface {
 	FindById(ctx *gin.Context)
 	Create(ctx *gin.Context)
 	Update(ctx *gin.Context)
+	Patch(ctx *gin.Context)
 	Delete(ctx *gin.Context)
+	Hold(ctx *gin.Context)
+	Release(ctx *gin.Context)
 }
 
 // コントローラクラスの実態（classに相当。goにはクラスの概念がない。。。）
@@ -35,11 +46,12 @@ func NewItemController(service services.IItemService) IItemController {
 }
 
 // コントローラメソッド
+// サービスにはctx.Request.Context()を渡す（トレースのスパンやキャンセルを下の層へ伝えるため）
 func (c *ItemController) FindAll(ctx *gin.Context) {
-	items, err := c.service.FindAll()
+	items, err := c.service.FindAll(ctx.Request.Context())
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		problem.Internal(ctx, err)
 		return
 	}
 
@@ -52,22 +64,24 @@ func (c *ItemController) FindById(ctx *gin.Context) {
 
 	// パラメータチェック
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
 		return
 	}
 
 	//サービスクラスメソッド実行
-	item, err := c.service.FindById(uint(itemId))
+	item, err := c.service.FindById(ctx.Request.Context(), uint(itemId))
 
 	if err != nil {
 		if err.Error() == "Item is not found" {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		problem.Internal(ctx, err)
 		return
 	}
 
+	// 更新するときはこの値をIf-Matchに入れて送ってもらう
+	setItemETag(ctx, item)
 	ctx.JSON(http.StatusOK, gin.H{"data": item})
 }
 
@@ -84,17 +98,25 @@ func (c *ItemController) Create(ctx *gin.Context) {
 	// 大人しく一旦変数で受け取ってから、if判定
 
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Validation(ctx, err)
 		return
 	}
 
-	newItem, err := c.service.Create(input)
+	// AuthMiddlewareでctxに詰めたログインユーザーを出品者とする
+	user := ctx.MustGet("user").(*models.User)
+
+	newItem, err := c.service.Create(ctx.Request.Context(), input, user.ID)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
 		return
 	}
 
+	setItemETag(ctx, newItem)
 	ctx.JSON(http.StatusCreated, gin.H{"data": newItem})
 }
 
@@ -110,46 +132,252 @@ func (c *ItemController) Update(ctx *gin.Context) {
 
 	// パラメータチェック
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
 		return
 	}
 
+	// 更新できるのは出品者本人だけ
+	user := ctx.MustGet("user").(*models.User)
+
 	// ユーザーからのパラメータ受取用の箱を準備
+	// PUTは丸ごと置き換えなので、省略した項目は空の値になる（部分的な更新はPATCHで行う）
 	var input dto.UpdateItemInput
 	if err := ctx.ShouldBindJSON(&input); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Validation(ctx, err)
 		return
 	}
 
-	updateedItem, err := c.service.Update(uint(itemId), input)
+	updateedItem, err := c.service.Update(ctx.Request.Context(), uint(itemId), user.ID, version, input)
 
 	if err != nil {
 		if err.Error() == "Item is not found" {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrForbidden) {
+			problem.Respond(ctx, http.StatusForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrItemModified) {
+			problem.Respond(ctx, http.StatusPreconditionFailed, err.Error())
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
 		return
 	}
+	setItemETag(ctx, updateedItem)
 	ctx.JSON(http.StatusOK, gin.H{"data": updateedItem})
 }
 
+// 差分での更新。Content-TypeでJSON Merge Patch（RFC 7396）かJSON Patch（RFC 6902）かを選ぶ
+// 差分を適用した結果はPUTと同じルールで検証する
+func (c *ItemController) Patch(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	apply, ok := patch.ForContentType(ctx.ContentType())
+	if !ok {
+		// 対応している形式を知らせる（RFC 5789）
+		ctx.Header("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		problem.Respond(ctx, http.StatusUnsupportedMediaType, "Unsupported patch format")
+		return
+	}
+	body, err := ctx.GetRawData()
+	if err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	patchedItem, err := c.service.Patch(ctx.Request.Context(), uint(itemId), user.ID, version, func(current dto.UpdateItemInput) (dto.UpdateItemInput, error) {
+		doc, err := json.Marshal(current)
+		if err != nil {
+			return current, err
+		}
+		patched, err := apply(doc, body)
+		if err != nil {
+			return current, err
+		}
+		return decodeUpdateItemInput(patched)
+	})
+
+	if err != nil {
+		var invalid *invalidItemError
+		switch {
+		case err.Error() == "Item is not found":
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrForbidden):
+			problem.Respond(ctx, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrItemModified):
+			problem.Respond(ctx, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, patch.ErrMalformed), errors.Is(err, services.ErrCategoryNotFound):
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, patch.ErrConflict):
+			// testの不一致など、今の商品の内容には適用できない
+			problem.Respond(ctx, http.StatusConflict, err.Error())
+		case errors.As(err, &invalid):
+			problem.Validation(ctx, invalid.err)
+		default:
+			problem.Internal(ctx, err)
+		}
+		return
+	}
+	setItemETag(ctx, patchedItem)
+	ctx.JSON(http.StatusOK, gin.H{"data": patchedItem})
+}
+
 func (c *ItemController) Delete(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
 	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
 		return
 	}
 
-	err = c.service.Delete(uint(itemId))
+	err = c.service.Delete(ctx.Request.Context(), uint(itemId), user.ID)
 
 	if err != nil {
 		if err.Error() == "Item is not found" {
-			ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
 			return
 		}
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Unexpected error"})
+		if errors.Is(err, services.ErrForbidden) {
+			problem.Respond(ctx, http.StatusForbidden, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
 		return
 	}
 	ctx.Status(http.StatusOK) // ステータスコードのみを返す
 }
+
+// 購入手続きの開始。一定時間、他のユーザーが購入できないよう商品を確保する
+func (c *ItemController) Hold(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	reservation, err := c.service.Hold(ctx.Request.Context(), uint(itemId), user.ID)
+	if err != nil {
+		switch {
+		case err.Error() == "Item is not found":
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrOwnItem):
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrItemUnavailable), errors.Is(err, services.ErrItemReserved):
+			problem.Respond(ctx, http.StatusConflict, err.Error())
+		default:
+			problem.Internal(ctx, err)
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": reservation})
+}
+
+func (c *ItemController) Release(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	err = c.service.Release(ctx.Request.Context(), uint(itemId), user.ID)
+	if err != nil {
+		if err.Error() == "Reservation is not found" {
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// 差分を適用した結果が商品として正しくない
+type invalidItemError struct {
+	err error
+}
+
+func (e *invalidItemError) Error() string {
+	return e.err.Error()
+}
+
+// 差分を適用した結果のJSONを、ShouldBindJSONと同じく読み込んで検証する
+func decodeUpdateItemInput(data []byte) (dto.UpdateItemInput, error) {
+	var input dto.UpdateItemInput
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// 存在しない項目への変更を黙って無視しないよう、知らないキーはエラーにする
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&input); err != nil {
+		return input, &invalidItemError{err: err}
+	}
+	if err := binding.Validator.ValidateStruct(&input); err != nil {
+		return input, &invalidItemError{err: err}
+	}
+	return input, nil
+}
+
+// If-Matchのバージョンを取り出す。なければ428、形式が違えば412を返してfalse
+// 他の人の変更を知らずに上書きしないよう、どのバージョンをもとにした更新かを必ず送ってもらう
+func ifMatchVersion(ctx *gin.Context) (uint, bool) {
+	ifMatch := ctx.GetHeader("If-Match")
+	if ifMatch == "" {
+		problem.Respond(ctx, http.StatusPreconditionRequired, "If-Match header is required")
+		return 0, false
+	}
+	version, ok := parseItemETag(ifMatch)
+	if !ok {
+		// 形式が違うETagは今のETagと一致することがない
+		problem.Respond(ctx, http.StatusPreconditionFailed, services.ErrItemModified.Error())
+		return 0, false
+	}
+	return version, true
+}
+
+// 商品のETag。バージョンが変わらない限り内容も変わらないので、バージョンをそのまま使う（例: "3"）
+func setItemETag(ctx *gin.Context, item *models.Item) {
+	ctx.Header("ETag", strconv.Quote(strconv.FormatUint(uint64(item.Version), 10)))
+}
+
+// If-Matchの値からバージョンを取り出す。"*"（今ある内容なら何でもよい）は0を返す
+// 弱いETag（W/"3"）や複数の指定には対応しない（If-Matchの比較は強い比較なので、弱いETagは一致しない）
+func parseItemETag(value string) (uint, bool) {
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		return 0, true
+	}
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(unquoted, 10, 64)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return uint(version), true
+}
@@ -0,0 +1,391 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-freemarket/controllers"
+	"gin-freemarket/dto"
+	"gin-freemarket/mocks"
+	"gin-freemarket/models"
+	"gin-freemarket/patch"
+	"gin-freemarket/services"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+func TestItemController(t *testing.T) {
+	item := &models.Item{Model: gorm.Model{ID: 1}, Name: "book", Price: 1000, UserId: 7, Version: 3}
+	ifMatch := http.Header{"If-Match": {`"3"`}}
+	// PATCHの差分を適用する元の内容
+	current := dto.UpdateItemInput{Name: "book", Price: 1000, Description: "used", ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3}
+	// サービスのPatchの代わりに差分を適用し、結果がwantになることを確認する
+	expectPatch := func(want dto.UpdateItemInput) func(s *mocks.MockIItemService) {
+		return func(s *mocks.MockIItemService) {
+			s.EXPECT().Patch(gomock.Any(), uint(1), testUser.ID, uint(3), gomock.Any()).DoAndReturn(func(_ context.Context, _ uint, _ uint, _ uint, apply services.ItemPatchFunc) (*models.Item, error) {
+				input, err := apply(current)
+				if err != nil {
+					return nil, err
+				}
+				if input != want {
+					t.Errorf("patched input = %+v, want %+v", input, want)
+				}
+				return item, nil
+			})
+		}
+	}
+	mergePatch := http.Header{"If-Match": {`"3"`}, "Content-Type": {patch.MergePatchContentType}}
+	jsonPatch := http.Header{"If-Match": {`"3"`}, "Content-Type": {patch.JSONPatchContentType}}
+
+	cases := []struct {
+		name    string
+		route   string
+		handler func(c controllers.IItemController) gin.HandlerFunc
+		method  string
+		path    string
+		header  http.Header
+		body    string
+		// サービスに期待する呼び出し。nilならサービスは呼ばれない
+		expect func(s *mocks.MockIItemService)
+		status int
+		detail string
+		fields []string
+		// 成功したときに返るETag
+		etag string
+	}{
+		{
+			name: "FindAll", route: "/items", method: http.MethodGet, path: "/items",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.FindAll },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().FindAll(gomock.Any()).Return(&[]models.Item{*item}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "FindAll service error", route: "/items", method: http.MethodGet, path: "/items",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.FindAll },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().FindAll(gomock.Any()).Return(nil, errors.New("connection refused"))
+			},
+			status: http.StatusInternalServerError, detail: "Unexpected error",
+		},
+		{
+			name: "FindById", route: "/items/:id", method: http.MethodGet, path: "/items/1",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.FindById },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().FindById(gomock.Any(), uint(1)).Return(item, nil)
+			},
+			status: http.StatusOK, etag: `"3"`,
+		},
+		{
+			name: "FindById not found", route: "/items/:id", method: http.MethodGet, path: "/items/9",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.FindById },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().FindById(gomock.Any(), uint(9)).Return(nil, errors.New("Item is not found"))
+			},
+			status: http.StatusNotFound, detail: "Item is not found",
+		},
+		{
+			name: "FindById invalid id", route: "/items/:id", method: http.MethodGet, path: "/items/abc",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.FindById },
+			status:  http.StatusBadRequest, detail: "Invalid id",
+		},
+		{
+			name: "Create sells as the logged-in user", route: "/items", method: http.MethodPost, path: "/items",
+			body:    `{"name":"book","price":1000,"shipping_payer":"buyer"}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Create },
+			expect: func(s *mocks.MockIItemService) {
+				input := dto.CreateItemInput{Name: "book", Price: 1000, ShippingPayer: models.ShippingPayerBuyer}
+				s.EXPECT().Create(gomock.Any(), input, testUser.ID).Return(item, nil)
+			},
+			status: http.StatusCreated, etag: `"3"`,
+		},
+		{
+			name: "Create validation error", route: "/items", method: http.MethodPost, path: "/items",
+			body:    `{"name":"a","shipping_payer":"someone"}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Create },
+			status:  http.StatusBadRequest, fields: []string{"name", "price", "shipping_payer"},
+		},
+		{
+			name: "Create malformed JSON", route: "/items", method: http.MethodPost, path: "/items",
+			body:    `{"name":`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Create },
+			status:  http.StatusBadRequest,
+		},
+		{
+			name: "Update replaces the whole item", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: ifMatch, body: `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			expect: func(s *mocks.MockIItemService) {
+				// 省略された項目は空の値で渡る
+				want := dto.UpdateItemInput{Name: "book", Price: 800}
+				updated := *item
+				updated.Version = 4
+				s.EXPECT().Update(gomock.Any(), uint(1), testUser.ID, uint(3), want).Return(&updated, nil)
+			},
+			status: http.StatusOK, etag: `"4"`,
+		},
+		{
+			name: "Update with If-Match: *", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: http.Header{"If-Match": {"*"}}, body: `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Update(gomock.Any(), uint(1), testUser.ID, uint(0), gomock.Any()).Return(item, nil)
+			},
+			status: http.StatusOK, etag: `"3"`,
+		},
+		{
+			name: "Update requires the whole item", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: ifMatch, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			status:  http.StatusBadRequest, fields: []string{"name"},
+		},
+		{
+			name: "Update without If-Match", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			body:    `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			status:  http.StatusPreconditionRequired, detail: "If-Match header is required",
+		},
+		{
+			name: "Update with a weak ETag", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: http.Header{"If-Match": {`W/"3"`}}, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			status:  http.StatusPreconditionFailed, detail: services.ErrItemModified.Error(),
+		},
+		{
+			name: "Update with a stale version", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: http.Header{"If-Match": {`"2"`}}, body: `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Update(gomock.Any(), uint(1), testUser.ID, uint(2), gomock.Any()).Return(nil, services.ErrItemModified)
+			},
+			status: http.StatusPreconditionFailed, detail: services.ErrItemModified.Error(),
+		},
+		{
+			name: "Update validation error", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: ifMatch, body: `{"name":"a","price":0}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			status:  http.StatusBadRequest, fields: []string{"name", "price"},
+		},
+		{
+			name: "Update not found", route: "/items/:id", method: http.MethodPut, path: "/items/9",
+			header: ifMatch, body: `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Update(gomock.Any(), uint(9), testUser.ID, uint(3), gomock.Any()).Return(nil, errors.New("Item is not found"))
+			},
+			status: http.StatusNotFound, detail: "Item is not found",
+		},
+		{
+			name: "Update invalid id", route: "/items/:id", method: http.MethodPut, path: "/items/-1",
+			header: ifMatch, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			status:  http.StatusBadRequest, detail: "Invalid id",
+		},
+		{
+			name: "Patch with a merge patch", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: mergePatch, body: `{"price":800,"description":null}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{Name: "book", Price: 800, ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3}),
+			status:  http.StatusOK, etag: `"3"`,
+		},
+		{
+			name: "Patch with a JSON patch", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: jsonPatch, body: `[{"op":"test","path":"/price","value":1000},{"op":"replace","path":"/price","value":800},{"op":"remove","path":"/description"}]`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{Name: "book", Price: 800, ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3}),
+			status:  http.StatusOK, etag: `"3"`,
+		},
+		{
+			name: "Patch with a failing test operation", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: jsonPatch, body: `[{"op":"test","path":"/price","value":999},{"op":"replace","path":"/price","value":800}]`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{}),
+			status:  http.StatusConflict,
+		},
+		{
+			name: "Patch with a malformed JSON patch", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: jsonPatch, body: `{"op":"replace","path":"/price","value":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{}),
+			status:  http.StatusBadRequest,
+		},
+		{
+			name: "Patch result is validated", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: mergePatch, body: `{"name":null,"price":0,"shipping_payer":"someone"}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{}),
+			status:  http.StatusBadRequest, fields: []string{"name", "price", "shipping_payer"},
+		},
+		{
+			name: "Patch with an unknown field", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: jsonPatch, body: `[{"op":"add","path":"/nam","value":"lamp"}]`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{}),
+			status:  http.StatusBadRequest, fields: []string{"nam"},
+		},
+		{
+			name: "Patch with a wrong type", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: mergePatch, body: `{"price":"cheap"}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect:  expectPatch(dto.UpdateItemInput{}),
+			status:  http.StatusBadRequest, fields: []string{"price"},
+		},
+		{
+			name: "Patch with an unsupported content type", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: http.Header{"If-Match": {`"3"`}, "Content-Type": {"application/json"}}, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			status:  http.StatusUnsupportedMediaType, detail: "Unsupported patch format",
+		},
+		{
+			name: "Patch without If-Match", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: http.Header{"Content-Type": {patch.MergePatchContentType}}, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			status:  http.StatusPreconditionRequired, detail: "If-Match header is required",
+		},
+		{
+			name: "Patch with a stale version", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: mergePatch, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Patch(gomock.Any(), uint(1), testUser.ID, uint(3), gomock.Any()).Return(nil, services.ErrItemModified)
+			},
+			status: http.StatusPreconditionFailed, detail: services.ErrItemModified.Error(),
+		},
+		{
+			name: "Patch not found", route: "/items/:id", method: http.MethodPatch, path: "/items/9",
+			header: mergePatch, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Patch(gomock.Any(), uint(9), testUser.ID, uint(3), gomock.Any()).Return(nil, errors.New("Item is not found"))
+			},
+			status: http.StatusNotFound, detail: "Item is not found",
+		},
+		{
+			name: "Delete", route: "/items/:id", method: http.MethodDelete, path: "/items/1",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Delete },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Delete(gomock.Any(), uint(1), testUser.ID).Return(nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Delete another user's item", route: "/items/:id", method: http.MethodDelete, path: "/items/1",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Delete },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Delete(gomock.Any(), uint(1), testUser.ID).Return(services.ErrForbidden)
+			},
+			status: http.StatusForbidden, detail: services.ErrForbidden.Error(),
+		},
+		{
+			name: "Update another user's item", route: "/items/:id", method: http.MethodPut, path: "/items/1",
+			header: ifMatch, body: `{"name":"book","price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Update },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Update(gomock.Any(), uint(1), testUser.ID, uint(3), gomock.Any()).Return(nil, services.ErrForbidden)
+			},
+			status: http.StatusForbidden, detail: services.ErrForbidden.Error(),
+		},
+		{
+			name: "Patch another user's item", route: "/items/:id", method: http.MethodPatch, path: "/items/1",
+			header: mergePatch, body: `{"price":800}`,
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Patch },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Patch(gomock.Any(), uint(1), testUser.ID, uint(3), gomock.Any()).Return(nil, services.ErrForbidden)
+			},
+			status: http.StatusForbidden, detail: services.ErrForbidden.Error(),
+		},
+		{
+			name: "Delete not found", route: "/items/:id", method: http.MethodDelete, path: "/items/9",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Delete },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Delete(gomock.Any(), uint(9), testUser.ID).Return(errors.New("Item is not found"))
+			},
+			status: http.StatusNotFound, detail: "Item is not found",
+		},
+		{
+			name: "Hold", route: "/items/:id/hold", method: http.MethodPost, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Hold },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Hold(gomock.Any(), uint(1), testUser.ID).Return(&models.Reservation{ItemId: 1, UserId: testUser.ID}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Hold own item", route: "/items/:id/hold", method: http.MethodPost, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Hold },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Hold(gomock.Any(), uint(1), testUser.ID).Return(nil, services.ErrOwnItem)
+			},
+			status: http.StatusBadRequest, detail: services.ErrOwnItem.Error(),
+		},
+		{
+			name: "Hold reserved item", route: "/items/:id/hold", method: http.MethodPost, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Hold },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Hold(gomock.Any(), uint(1), testUser.ID).Return(nil, services.ErrItemReserved)
+			},
+			status: http.StatusConflict, detail: services.ErrItemReserved.Error(),
+		},
+		{
+			name: "Hold sold out item", route: "/items/:id/hold", method: http.MethodPost, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Hold },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Hold(gomock.Any(), uint(1), testUser.ID).Return(nil, services.ErrItemUnavailable)
+			},
+			status: http.StatusConflict, detail: services.ErrItemUnavailable.Error(),
+		},
+		{
+			name: "Release", route: "/items/:id/hold", method: http.MethodDelete, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Release },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Release(gomock.Any(), uint(1), testUser.ID).Return(nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "Release without a hold", route: "/items/:id/hold", method: http.MethodDelete, path: "/items/1/hold",
+			handler: func(c controllers.IItemController) gin.HandlerFunc { return c.Release },
+			expect: func(s *mocks.MockIItemService) {
+				s.EXPECT().Release(gomock.Any(), uint(1), testUser.ID).Return(errors.New("Reservation is not found"))
+			},
+			status: http.StatusNotFound, detail: "Reservation is not found",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			service := mocks.NewMockIItemService(ctrl)
+			if tc.expect != nil {
+				tc.expect(service)
+			}
+			controller := controllers.NewItemController(service)
+
+			rec := serve(t, tc.route, tc.handler(controller), tc.method, tc.path, tc.header, tc.body)
+			assertResponse(t, rec, tc.status, tc.detail, tc.fields...)
+			if got := rec.Header().Get("ETag"); got != tc.etag {
+				t.Fatalf("ETag = %q, want %q", got, tc.etag)
+			}
+			// 対応していない形式なら、対応している形式を知らせる
+			if tc.status == http.StatusUnsupportedMediaType {
+				want := patch.MergePatchContentType + ", " + patch.JSONPatchContentType
+				if got := rec.Header().Get("Accept-Patch"); got != want {
+					t.Fatalf("Accept-Patch = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IOfferController interface {
+	FindByItem(ctx *gin.Context)
+	Create(ctx *gin.Context)
+	Accept(ctx *gin.Context)
+	Reject(ctx *gin.Context)
+	Counter(ctx *gin.Context)
+}
+
+type OfferController struct {
+	service services.IOfferService
+}
+
+func NewOfferController(service services.IOfferService) IOfferController {
+	return &OfferController{service: service}
+}
+
+// GET /items/:id/offers
+func (c *OfferController) FindByItem(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	offers, err := c.service.FindByItem(ctx.Request.Context(), uint(itemId), user.ID)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": offers})
+}
+
+// POST /items/:id/offers
+func (c *OfferController) Create(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var input dto.CreateOfferInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	newOffer, err := c.service.Create(ctx.Request.Context(), uint(itemId), user.ID, input)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"data": newOffer})
+}
+
+// POST /offers/:id/accept
+func (c *OfferController) Accept(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	offerId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	offer, err := c.service.Accept(ctx.Request.Context(), uint(offerId), user.ID)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": offer})
+}
+
+// POST /offers/:id/reject
+func (c *OfferController) Reject(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	offerId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	offer, err := c.service.Reject(ctx.Request.Context(), uint(offerId), user.ID)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": offer})
+}
+
+// POST /offers/:id/counter
+func (c *OfferController) Counter(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	offerId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var input dto.CounterOfferInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	offer, err := c.service.Counter(ctx.Request.Context(), uint(offerId), user.ID, input)
+	if err != nil {
+		respondOfferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": offer})
+}
+
+// サービスから返ってきたエラーをステータスコードに振り分ける
+func respondOfferError(ctx *gin.Context, err error) {
+	switch {
+	case err.Error() == "Item is not found" || err.Error() == "Offer is not found":
+		problem.Respond(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrForbidden):
+		problem.Respond(ctx, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrOfferPriceInvalid), errors.Is(err, services.ErrOwnItem):
+		problem.Respond(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrItemUnavailable),
+		errors.Is(err, services.ErrItemReserved),
+		errors.Is(err, services.ErrOfferAlreadyExists),
+		errors.Is(err, services.ErrOfferNotOpen),
+		errors.Is(err, services.ErrOfferExpired):
+		problem.Respond(ctx, http.StatusConflict, err.Error())
+	default:
+		problem.Internal(ctx, err)
+	}
+}
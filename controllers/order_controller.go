@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IOrderController interface {
+	Purchase(ctx *gin.Context)
+	FindById(ctx *gin.Context)
+	Cancel(ctx *gin.Context)
+	Complete(ctx *gin.Context)
+}
+
+type OrderController struct {
+	service services.IOrderService
+}
+
+func NewOrderController(service services.IOrderService) IOrderController {
+	return &OrderController{service: service}
+}
+
+// POST /items/:id/purchase
+func (c *OrderController) Purchase(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	itemId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	// ボディは省略可能（その場合はデフォルトの住所に送る）
+	var input dto.PurchaseInput
+	if err := ctx.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	order, intent, err := c.service.Purchase(ctx.Request.Context(), uint(itemId), user.ID, input)
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"data": order, "payment": intent})
+}
+
+// GET /orders/:id
+func (c *OrderController) FindById(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	orderId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	order, err := c.service.FindById(ctx.Request.Context(), uint(orderId), user.ID)
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": order})
+}
+
+// POST /orders/:id/cancel
+func (c *OrderController) Cancel(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	orderId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	order, err := c.service.Cancel(ctx.Request.Context(), uint(orderId), user.ID)
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": order})
+}
+
+// POST /orders/:id/complete
+func (c *OrderController) Complete(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	orderId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	order, err := c.service.Complete(ctx.Request.Context(), uint(orderId), user.ID)
+	if err != nil {
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": order})
+}
+
+func respondOrderError(ctx *gin.Context, err error) {
+	switch {
+	case err.Error() == "Item is not found" || err.Error() == "Order is not found" || err.Error() == "Address is not found":
+		problem.Respond(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrForbidden):
+		problem.Respond(ctx, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrOwnItem), errors.Is(err, services.ErrAddressRequired):
+		problem.Respond(ctx, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrItemUnavailable),
+		errors.Is(err, services.ErrItemReserved),
+		errors.Is(err, services.ErrOrderAlreadyExists),
+		errors.Is(err, services.ErrOrderStatus):
+		problem.Respond(ctx, http.StatusConflict, err.Error())
+	default:
+		problem.Internal(ctx, err)
+	}
+}
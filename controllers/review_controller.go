@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IReviewController interface {
+	Create(ctx *gin.Context)
+	FindByUser(ctx *gin.Context)
+}
+
+type ReviewController struct {
+	service services.IReviewService
+}
+
+func NewReviewController(service services.IReviewService) IReviewController {
+	return &ReviewController{service: service}
+}
+
+// POST /orders/:id/reviews
+func (c *ReviewController) Create(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	orderId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var input dto.CreateReviewInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	review, err := c.service.Create(ctx.Request.Context(), uint(orderId), user.ID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrReviewAlreadyExists) {
+			problem.Respond(ctx, http.StatusConflict, err.Error())
+			return
+		}
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"data": review})
+}
+
+// GET /users/:id/reviews
+func (c *ReviewController) FindByUser(ctx *gin.Context) {
+	userId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	reviews, err := c.service.FindByUser(ctx.Request.Context(), uint(userId))
+	if err != nil {
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": reviews})
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IShipmentController interface {
+	Ship(ctx *gin.Context)
+}
+
+type ShipmentController struct {
+	service services.IShipmentService
+}
+
+func NewShipmentController(service services.IShipmentService) IShipmentController {
+	return &ShipmentController{service: service}
+}
+
+// POST /orders/:id/ship
+func (c *ShipmentController) Ship(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	orderId, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var input dto.ShipInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	order, err := c.service.Ship(ctx.Request.Context(), uint(orderId), user.ID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownCarrier) || errors.Is(err, services.ErrInvalidTrackingNumber) {
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+			return
+		}
+		respondOrderError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": order})
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IWalletController interface {
+	GetWallet(ctx *gin.Context)
+	RequestPayout(ctx *gin.Context)
+}
+
+type WalletController struct {
+	service services.IWalletService
+}
+
+func NewWalletController(service services.IWalletService) IWalletController {
+	return &WalletController{service: service}
+}
+
+// GET /me/wallet
+func (c *WalletController) GetWallet(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	wallet, err := c.service.GetWallet(ctx.Request.Context(), user.ID)
+	if err != nil {
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": wallet})
+}
+
+// POST /me/payouts
+func (c *WalletController) RequestPayout(ctx *gin.Context) {
+	user := ctx.MustGet("user").(*models.User)
+
+	var input dto.PayoutInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		problem.Validation(ctx, err)
+		return
+	}
+
+	payout, err := c.service.RequestPayout(ctx.Request.Context(), user.ID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrInsufficientBalance) {
+			problem.Respond(ctx, http.StatusConflict, err.Error())
+			return
+		}
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"data": payout})
+}
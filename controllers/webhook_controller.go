@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-freemarket/payments"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IWebhookController interface {
+	Payments(ctx *gin.Context)
+	Shipments(ctx *gin.Context)
+}
+
+type WebhookController struct {
+	orderService    services.IOrderService
+	shipmentService services.IShipmentService
+	// 決済プロバイダ・運送会社とそれぞれ共有している署名用のシークレット
+	paymentSecret  string
+	shipmentSecret string
+}
+
+func NewWebhookController(orderService services.IOrderService, shipmentService services.IShipmentService, paymentSecret string, shipmentSecret string) IWebhookController {
+	return &WebhookController{
+		orderService:    orderService,
+		shipmentService: shipmentService,
+		paymentSecret:   paymentSecret,
+		shipmentSecret:  shipmentSecret,
+	}
+}
+
+// POST /webhooks/payments
+func (c *WebhookController) Payments(ctx *gin.Context) {
+	// 署名はボディのバイト列に対して計算されているので、バインドする前に生のボディを取得する
+	body, err := ctx.GetRawData()
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	if !payments.VerifyWebhookSignature(c.paymentSecret, body, ctx.GetHeader("X-Payment-Signature")) {
+		problem.Respond(ctx, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	var event payments.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.ID == "" || event.Type == "" {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid event")
+		return
+	}
+
+	if err := c.orderService.HandlePaymentEvent(ctx.Request.Context(), event); err != nil {
+		if err.Error() == "Order is not found" {
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		// 2xx以外を返すとプロバイダが再送してくれる
+		problem.Internal(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// POST /webhooks/shipments?carrier=fake
+func (c *WebhookController) Shipments(ctx *gin.Context) {
+	body, err := ctx.GetRawData()
+	if err != nil {
+		problem.Respond(ctx, http.StatusBadRequest, "Invalid body")
+		return
+	}
+
+	// 署名の形式は決済のWebhookと同じ（"sha256=<hex>"）
+	if !payments.VerifyWebhookSignature(c.shipmentSecret, body, ctx.GetHeader("X-Shipment-Signature")) {
+		problem.Respond(ctx, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	if err := c.shipmentService.HandleTrackingWebhook(ctx.Request.Context(), ctx.Query("carrier"), body); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownCarrier), errors.Is(err, services.ErrInvalidTrackingPayload):
+			problem.Respond(ctx, http.StatusBadRequest, err.Error())
+		case err.Error() == "Shipment is not found":
+			problem.Respond(ctx, http.StatusNotFound, err.Error())
+		default:
+			problem.Internal(ctx, err)
+		}
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,12 @@
+package dto
+
+type AddressInput struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	PostalCode string `json:"postal_code" binding:"required,len=7,numeric"` // ハイフンなしの7桁
+	Prefecture string `json:"prefecture" binding:"required,max=10"`
+	City       string `json:"city" binding:"required,max=100"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2" binding:"max=200"`
+	Phone      string `json:"phone" binding:"required,min=10,max=11,numeric"`
+	IsDefault  bool   `json:"is_default"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "log/slog"
+
 type SignupInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
@@ -9,3 +11,12 @@ type LoginInput struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=8"`
 }
+
+// ログに出すときはパスワードを含めない（slogはLogValueの戻り値を出力する）
+func (i SignupInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", i.Email))
+}
+
+func (i LoginInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", i.Email))
+}
@@ -12,14 +12,25 @@ type CreateItemInput struct {
 	Name       string `json:"name" binding:"required,min=2"`
 	Price      uint   `json:"price" binding:"required,min=1,max=99999999"`
 	Desciption string `json:"description"`
+	CategoryId uint   `json:"category_id"` // 省略時は未分類
+
+	ShippingMethod  string `json:"shipping_method"`
+	ShippingPayer   string `json:"shipping_payer" binding:"omitempty,oneof=seller buyer"` // 省略時は出品者負担
+	ShippingFee     uint   `json:"shipping_fee" binding:"max=99999999"`
+	ShipsWithinDays uint   `json:"ships_within_days" binding:"omitempty,min=1,max=30"` // 省略時は3日
 }
 
+// PUT（丸ごと置き換え）とPATCH（差分を適用した結果）で受け取る、更新できる項目すべて
+// 省略した項目は空の値（出品時と同じく、送料の負担者は出品者・発送までの日数は3日）になる
+// 売り切れかどうかは注文の状態で決まるので、出品者は変更できない
 type UpdateItemInput struct {
-	// updateでは値が指定された部分のみを更新対象としたいので、型をポインタ型にする。
-	// さらにbindingタグでomitnilをいれることで、ポインタ型のプロパティにnilがはいってきたときバリデーションがスキップされるという動きになる
+	Name        string `json:"name" binding:"required,min=2"`
+	Price       uint   `json:"price" binding:"required,min=1,max=99999999"`
+	Description string `json:"description"`
+	CategoryId  uint   `json:"category_id"`
 
-	Name        *string `json:"name" binding:"omitnil,min=2"`
-	Price       *uint   `json:"price" binding:"omitnil,min=1,max=99999999"`
-	Description *string `json:"description"`
-	SoldOut     *bool   `json:"soldout"`
+	ShippingMethod  string `json:"shipping_method"`
+	ShippingPayer   string `json:"shipping_payer" binding:"omitempty,oneof=seller buyer"`
+	ShippingFee     uint   `json:"shipping_fee" binding:"max=99999999"`
+	ShipsWithinDays uint   `json:"ships_within_days" binding:"omitempty,min=1,max=30"`
 }
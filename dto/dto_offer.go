@@ -0,0 +1,9 @@
+package dto
+
+type CreateOfferInput struct {
+	Price uint `json:"price" binding:"required,min=1,max=99999999"`
+}
+
+type CounterOfferInput struct {
+	Price uint `json:"price" binding:"required,min=1,max=99999999"`
+}
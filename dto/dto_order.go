@@ -0,0 +1,6 @@
+package dto
+
+type PurchaseInput struct {
+	// 送り先。省略した場合はデフォルトの住所を使う
+	AddressId *uint `json:"address_id"`
+}
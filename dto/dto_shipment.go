@@ -0,0 +1,6 @@
+package dto
+
+type ShipInput struct {
+	Carrier        string `json:"carrier" binding:"required"`
+	TrackingNumber string `json:"tracking_number" binding:"required,max=64"`
+}
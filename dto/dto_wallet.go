@@ -0,0 +1,5 @@
+package dto
+
+type PayoutInput struct {
+	Amount uint `json:"amount" binding:"required,min=1"`
+}
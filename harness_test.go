@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"gin-freemarket/infra"
+	"gin-freemarket/problem"
+	"gin-freemarket/repositories"
+	"gin-freemarket/validation"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// E2Eテスト用のサーバー
+// main()と同じnewAppで組み立て、リポジトリだけメモリ上の実装にしている。テストごとに空の状態から始まる
+type testServer struct {
+	t      *testing.T
+	router http.Handler
+}
+
+var setupValidationOnce sync.Once
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	setupValidationOnce.Do(func() {
+		if err := validation.Setup(); err != nil {
+			t.Fatalf("failed to set up validation: %v", err)
+		}
+	})
+
+	cfg := &infra.Config{
+		Server: infra.ServerConfig{RequestTimeout: 5 * time.Second, HealthCheckTimeout: time.Second, IdempotencyKeyTTL: 24 * time.Hour, IdempotencyKeyLease: time.Minute},
+		DB:     infra.DBConfig{Driver: infra.DriverMemory},
+		Auth:   infra.AuthConfig{SecretKey: "test-secret", TokenTTL: time.Hour},
+		Market: infra.MarketConfig{
+			OfferTTL:          48 * time.Hour,
+			HoldTTL:           15 * time.Minute,
+			FeeRateBps:        1000,
+			AutoCompleteAfter: 72 * time.Hour,
+			PendingOrderTTL:   30 * time.Minute,
+		},
+		Payments:  infra.PaymentsConfig{WebhookSecret: "test-payment-secret"},
+		Shipments: infra.ShipmentsConfig{WebhookSecret: "test-shipment-secret"},
+		Workers:   infra.WorkersConfig{Interval: time.Minute},
+	}
+	// リクエストログはテストの出力に混ぜない
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	application := newApp(cfg, newMemoryRepositories(repositories.NewMemoryStore()), nil, logger)
+	return &testServer{t: t, router: application.router}
+}
+
+// リクエストを送ってレスポンスを返す
+// bodyがstringならそのまま、nil以外はJSONにして送る。tokenが空でなければBearerトークンとして付ける
+func (s *testServer) do(method string, path string, body any, token string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.doWithHeader(method, path, body, token, nil)
+}
+
+// doと同じ。headerのヘッダーも付けて送る
+func (s *testServer) doWithHeader(method string, path string, body any, token string, header http.Header) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	switch v := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			s.t.Fatalf("failed to marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *testServer) signup(email string, password string) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/auth/signup", map[string]string{"email": email, "password": password}, "")
+	assertStatus(s.t, rec, http.StatusCreated)
+}
+
+func (s *testServer) login(email string, password string) string {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": password}, "")
+	assertStatus(s.t, rec, http.StatusOK)
+	var res struct {
+		Token string `json:"token"`
+	}
+	decodeJSON(s.t, rec, &res)
+	if res.Token == "" {
+		s.t.Fatal("login returned an empty token")
+	}
+	return res.Token
+}
+
+// ユーザーを登録してログインし、そのユーザーとしてリクエストを送るクライアントを返す
+func (s *testServer) userClient(email string) *testClient {
+	s.t.Helper()
+	s.signup(email, "password123")
+	return &testClient{server: s, token: s.login(email, "password123")}
+}
+
+// Bearerトークンを付けてリクエストを送るクライアント
+type testClient struct {
+	server *testServer
+	token  string
+}
+
+func (c *testClient) do(method string, path string, body any) *httptest.ResponseRecorder {
+	c.server.t.Helper()
+	return c.server.do(method, path, body, c.token)
+}
+
+func (c *testClient) doWithHeader(method string, path string, body any, header http.Header) *httptest.ResponseRecorder {
+	c.server.t.Helper()
+	return c.server.doWithHeader(method, path, body, c.token, header)
+}
+
+func assertStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, want, rec.Body.String())
+	}
+}
+
+// JSONのレスポンスボディをvにデコードする
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("Content-Type = %q, want application/json (body: %s)", ct, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// {"data": ...}の形のレスポンスからdataをvにデコードする
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	decodeJSON(t, rec, &res)
+	if err := json.Unmarshal(res.Data, v); err != nil {
+		t.Fatalf("failed to decode data %q: %v", string(res.Data), err)
+	}
+}
+
+// RFC 7807のエラーレスポンスで、ステータスとdetailが期待どおりかを確認し、デコードした内容を返す
+// detailが空なら比較しない
+func assertProblem(t *testing.T, rec *httptest.ResponseRecorder, status int, detail string) problem.Details {
+	t.Helper()
+	assertStatus(t, rec, status)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, problem.ContentType) {
+		t.Fatalf("Content-Type = %q, want %s", ct, problem.ContentType)
+	}
+	var details problem.Details
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("failed to decode problem %q: %v", rec.Body.String(), err)
+	}
+	if details.Status != status {
+		t.Errorf("problem status = %d, want %d", details.Status, status)
+	}
+	if detail != "" && details.Detail != detail {
+		t.Errorf("problem detail = %q, want %q", details.Detail, detail)
+	}
+	return details
+}
+
+// 検証エラーに指定したフィールドが含まれているかを確認する
+func assertFieldErrors(t *testing.T, details problem.Details, fields ...string) {
+	t.Helper()
+	got := map[string]bool{}
+	for _, v := range details.Errors {
+		got[v.Field] = true
+	}
+	for _, field := range fields {
+		if !got[field] {
+			t.Errorf("errors = %+v, want an error for %q", details.Errors, field)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package infra
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// パスワードや署名用のシークレットなど、ログや画面に出してはいけない値
+// fmtで出力したりJSONにしたりしても中身は伏せられる。実際の値はValue()で取り出す
+type Secret string
+
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(s.String())), nil
+}
+
+// アプリケーションの設定
+// 値の優先順位は「環境変数 > 設定ファイル（CONFIG_FILE） > デフォルト値」。
+// .envがあれば、その内容も環境変数として読み込む（既に設定されている環境変数は上書きしない）
+//
+// 各項目のタグの意味
+//   - yaml/toml: 設定ファイル上のキー
+//   - env: 環境変数名
+//   - default: 何も指定されなかったときの値
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	DB        DBConfig        `yaml:"db" toml:"db"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Market    MarketConfig    `yaml:"market" toml:"market"`
+	Payments  PaymentsConfig  `yaml:"payments" toml:"payments"`
+	Shipments ShipmentsConfig `yaml:"shipments" toml:"shipments"`
+	Workers   WorkersConfig   `yaml:"workers" toml:"workers"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" toml:"addr" env:"SERVER_ADDR" default:"localhost:8080"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"60s"`
+	MaxHeaderBytes    uint          `yaml:"max_header_bytes" toml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" default:"1048576"`
+	// 1リクエストあたりの処理時間の上限。超えるとDBのクエリなどがキャンセルされる
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" default:"10s"`
+	// 終了シグナルを受け取ってから、処理中のリクエストの完了を待つ最大時間
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"20s"`
+	// 終了シグナルを受け取ってから接続の受付をやめるまでの時間。
+	// この間readyzは失敗を返すので、ロードバランサが振り分け先から外してくれる
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"SERVER_DRAIN_DELAY" default:"0s"`
+	// readyzで依存先（DBなど）を確認するときのタイムアウト
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" toml:"health_check_timeout" env:"SERVER_HEALTH_CHECK_TIMEOUT" default:"2s"`
+	// Idempotency-Keyを付けたリクエストのレスポンスを覚えておく期間。この間の再送には同じレスポンスを返す
+	IdempotencyKeyTTL time.Duration `yaml:"idempotency_key_ttl" toml:"idempotency_key_ttl" env:"IDEMPOTENCY_KEY_TTL" default:"24h"`
+	// 処理中のキーを持っていられる期間。過ぎると同じキーの再送が処理を引き継ぐので、request_timeoutより長くすること
+	IdempotencyKeyLease time.Duration `yaml:"idempotency_key_lease" toml:"idempotency_key_lease" env:"IDEMPOTENCY_KEY_LEASE" default:"1m"`
+}
+
+type DBConfig struct {
+	// postgres・sqlite・memoryのいずれか。sqliteとmemoryならDockerなしで動かせる（memoryは再起動で消える）
+	Driver string `yaml:"driver" toml:"driver" env:"DB_DRIVER" default:"postgres"`
+	// sqliteのときのファイルのパス。":memory:"ならメモリ上に作り、終了すると消える
+	Path string `yaml:"path" toml:"path" env:"DB_PATH" default:"freemarket.db"`
+
+	// 以下はpostgresのときだけ使う
+	Host     string `yaml:"host" toml:"host" env:"DB_HOST"`
+	User     string `yaml:"user" toml:"user" env:"DB_USER"`
+	Password Secret `yaml:"password" toml:"password" env:"DB_PASSWORD"`
+	Name     string `yaml:"name" toml:"name" env:"DB_NAME"`
+	Port     uint   `yaml:"port" toml:"port" env:"DB_PORT" default:"5432"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE" default:"disable"`
+	TimeZone string `yaml:"timezone" toml:"timezone" env:"DB_TIMEZONE" default:"Asia/Tokyo"`
+}
+
+// メモリ上のSQLiteを使うかどうか（起動のたびに空のDBになる）
+func (c DBConfig) InMemory() bool {
+	return c.Driver == DriverSQLite && c.Path == SQLiteInMemory
+}
+
+type AuthConfig struct {
+	SecretKey Secret        `yaml:"secret_key" toml:"secret_key" env:"SECRET_KEY"`
+	TokenTTL  time.Duration `yaml:"token_ttl" toml:"token_ttl" env:"TOKEN_TTL" default:"1h"`
+}
+
+type MarketConfig struct {
+	OfferTTL          time.Duration `yaml:"offer_ttl" toml:"offer_ttl" env:"OFFER_TTL" default:"48h"`
+	HoldTTL           time.Duration `yaml:"hold_ttl" toml:"hold_ttl" env:"HOLD_TTL" default:"15m"`
+	FeeRateBps        uint          `yaml:"fee_rate_bps" toml:"fee_rate_bps" env:"PLATFORM_FEE_BPS" default:"1000"`
+	FeeMinimum        uint          `yaml:"fee_minimum" toml:"fee_minimum" env:"PLATFORM_FEE_MIN" default:"0"`
+	AutoCompleteAfter time.Duration `yaml:"auto_complete_after" toml:"auto_complete_after" env:"ORDER_AUTO_COMPLETE_AFTER" default:"72h"`
+	// 支払い待ちの注文を自動でキャンセルするまでの時間
+	PendingOrderTTL time.Duration `yaml:"pending_order_ttl" toml:"pending_order_ttl" env:"PENDING_ORDER_TTL" default:"30m"`
+}
+
+type PaymentsConfig struct {
+	WebhookSecret Secret `yaml:"webhook_secret" toml:"webhook_secret" env:"PAYMENT_WEBHOOK_SECRET"`
+}
+
+type ShipmentsConfig struct {
+	WebhookSecret Secret `yaml:"webhook_secret" toml:"webhook_secret" env:"SHIPMENT_WEBHOOK_SECRET"`
+}
+
+type WorkersConfig struct {
+	// 期限切れホールドの解放・配達済み注文の自動完了・支払い待ちの注文のキャンセル・期限切れの冪等キーの削除を実行する間隔
+	Interval time.Duration `yaml:"interval" toml:"interval" env:"WORKER_INTERVAL" default:"1m"`
+}
+
+type TracingConfig struct {
+	// トレースの送り先。none（出力しない）、stdout（ローカル確認用）、otlp（コレクターに送る）のいずれか
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" default:"none"`
+	// exporterがstdoutのときの出力先ファイル。空なら標準出力
+	File string `yaml:"file" toml:"file" env:"TRACING_FILE"`
+	// exporterがotlpのときの送り先（例: http://localhost:4318）。空ならOTEL_EXPORTER_OTLP_ENDPOINTに従う
+	OTLPEndpoint string `yaml:"otlp_endpoint" toml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	ServiceName  string `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME" default:"gin-freemarket"`
+}
+
+type LogConfig struct {
+	// 出力するログの最低レベル。debug、info、warn、errorのいずれか
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" default:"info"`
+}
+
+// 設定を読み込んで検証する
+func LoadConfig() (*Config, error) {
+	// .envはローカル開発用。コンテナなどで環境変数を直接渡す場合はなくてもよい
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load .env: %w", err)
+	}
+
+	var cfg Config
+	if err := applyDefaults(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadConfigFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// 必須項目と値の範囲をチェックする。問題があればすべてまとめて返す
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr (SERVER_ADDR) is required"))
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server.drain_delay (SERVER_DRAIN_DELAY) must not be negative"))
+	}
+	if c.Server.MaxHeaderBytes == 0 {
+		errs = append(errs, errors.New("server.max_header_bytes (SERVER_MAX_HEADER_BYTES) must be positive"))
+	}
+	switch c.DB.Driver {
+	case DriverPostgres:
+		if c.DB.Host == "" {
+			errs = append(errs, errors.New("db.host (DB_HOST) is required"))
+		}
+		if c.DB.User == "" {
+			errs = append(errs, errors.New("db.user (DB_USER) is required"))
+		}
+		if c.DB.Name == "" {
+			errs = append(errs, errors.New("db.name (DB_NAME) is required"))
+		}
+		if c.DB.Port == 0 || c.DB.Port > 65535 {
+			errs = append(errs, errors.New("db.port (DB_PORT) must be between 1 and 65535"))
+		}
+	case DriverSQLite:
+		if c.DB.Path == "" {
+			errs = append(errs, errors.New("db.path (DB_PATH) is required"))
+		}
+	case DriverMemory:
+		// 接続先の設定は不要
+	default:
+		errs = append(errs, errors.New("db.driver (DB_DRIVER) must be one of postgres, sqlite, memory"))
+	}
+	if c.Auth.SecretKey == "" {
+		errs = append(errs, errors.New("auth.secret_key (SECRET_KEY) is required"))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, errors.New("tracing.exporter (TRACING_EXPORTER) must be one of none, stdout, otlp"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, errors.New("log.level (LOG_LEVEL) must be one of debug, info, warn, error"))
+	}
+	if c.Market.FeeRateBps > 10000 {
+		errs = append(errs, errors.New("market.fee_rate_bps (PLATFORM_FEE_BPS) must be 10000 or less"))
+	}
+
+	durations := map[string]time.Duration{
+		"server.read_header_timeout (SERVER_READ_HEADER_TIMEOUT)":   c.Server.ReadHeaderTimeout,
+		"server.read_timeout (SERVER_READ_TIMEOUT)":                 c.Server.ReadTimeout,
+		"server.write_timeout (SERVER_WRITE_TIMEOUT)":               c.Server.WriteTimeout,
+		"server.idle_timeout (SERVER_IDLE_TIMEOUT)":                 c.Server.IdleTimeout,
+		"server.request_timeout (SERVER_REQUEST_TIMEOUT)":           c.Server.RequestTimeout,
+		"server.shutdown_timeout (SERVER_SHUTDOWN_TIMEOUT)":         c.Server.ShutdownTimeout,
+		"server.health_check_timeout (SERVER_HEALTH_CHECK_TIMEOUT)": c.Server.HealthCheckTimeout,
+		"server.idempotency_key_ttl (IDEMPOTENCY_KEY_TTL)":          c.Server.IdempotencyKeyTTL,
+		"server.idempotency_key_lease (IDEMPOTENCY_KEY_LEASE)":      c.Server.IdempotencyKeyLease,
+		"auth.token_ttl (TOKEN_TTL)":                                c.Auth.TokenTTL,
+		"market.offer_ttl (OFFER_TTL)":                              c.Market.OfferTTL,
+		"market.hold_ttl (HOLD_TTL)":                                c.Market.HoldTTL,
+		"market.auto_complete_after (ORDER_AUTO_COMPLETE_AFTER)":    c.Market.AutoCompleteAfter,
+		"market.pending_order_ttl (PENDING_ORDER_TTL)":              c.Market.PendingOrderTTL,
+		"workers.interval (WORKER_INTERVAL)":                        c.Workers.Interval,
+	}
+	for name, v := range durations {
+		if v <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	// 処理中のリクエストのキーが、終わる前に別のリクエストに引き継がれないようにする
+	if c.Server.IdempotencyKeyLease <= c.Server.RequestTimeout {
+		errs = append(errs, errors.New("server.idempotency_key_lease (IDEMPOTENCY_KEY_LEASE) must be longer than server.request_timeout (SERVER_REQUEST_TIMEOUT)"))
+	}
+	return errors.Join(errs...)
+}
+
+// 設定内容を「section.key=value」の形式で1行ずつ出力する。シークレットは伏せられる
+func (c Config) String() string {
+	var lines []string
+	root := reflect.ValueOf(c)
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		for j := 0; j < section.Type.NumField(); j++ {
+			field := section.Type.Field(j)
+			value := root.Field(i).Field(j).Interface()
+			lines = append(lines, fmt.Sprintf("%s.%s=%v", section.Tag.Get("yaml"), field.Tag.Get("yaml"), value))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 拡張子（.yaml/.yml/.toml）で形式を判断して設定ファイルを読み込む
+// 期間は"15m"のような文字列で書く
+func loadConfigFile(path string, cfg *Config) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// 期間などの型変換を環境変数と共通にするため、一旦「セクション→キー→値」のmapとして読む
+	sections := map[string]map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &sections)
+	case ".toml":
+		err = toml.Unmarshal(body, &sections)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		values, ok := sections[root.Type().Field(i).Tag.Get("yaml")]
+		if !ok {
+			continue
+		}
+		section := root.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			value, ok := values[field.Tag.Get("yaml")]
+			if !ok {
+				continue
+			}
+			if err := setField(section.Field(j), fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("invalid value for %s.%s: %w", root.Type().Field(i).Tag.Get("yaml"), field.Tag.Get("yaml"), err)
+			}
+		}
+	}
+	return nil
+}
+
+func applyDefaults(root reflect.Value) error {
+	return eachField(root, func(field reflect.StructField, value reflect.Value) error {
+		if v, ok := field.Tag.Lookup("default"); ok {
+			return setField(value, v)
+		}
+		return nil
+	})
+}
+
+func applyEnv(root reflect.Value) error {
+	return eachField(root, func(field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("env")
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			if err := setField(value, v); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func eachField(root reflect.Value, fn func(field reflect.StructField, value reflect.Value) error) error {
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			if err := fn(section.Type().Field(j), section.Field(j)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 文字列を項目の型に変換して代入する
+func setField(field reflect.Value, raw string) error {
+	// time.Durationはint64の別名なので、Kindより先に型で判定する
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Uint:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
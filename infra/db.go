@@ -1,28 +1,87 @@
 package infra
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"gin-freemarket/logging"
+	"time"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func SetupDB() *gorm.DB {
-	// dsn := "host=localhost user=gorm password=gorm dbname=gorm port=9920 sslmode=disable TimeZone=Asia/Shanghai"
-	// db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=Asia/Tokyo",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// DBの種類
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	// DBを使わず、サーバーのメモリだけで動かす（repositories.MemoryStore）。SetupDBは呼ばない
+	DriverMemory = "memory"
+)
+
+// SQLiteをメモリ上で使うときのパス
+const SQLiteInMemory = ":memory:"
+
+func SetupDB(cfg DBConfig) *gorm.DB {
+	gormConfig := &gorm.Config{
+		// 200msを超えたクエリは遅いクエリとして警告を出す
+		Logger: logging.NewGormLogger(200 * time.Millisecond),
+		// 一意制約の違反などをDBの種類によらずgorm.ErrDuplicatedKeyなどで判定できるようにする
+		TranslateError: true,
+	}
+
+	var db *gorm.DB
+	var err error
+	switch cfg.Driver {
+	case DriverSQLite:
+		db, err = gorm.Open(sqlite.Open(sqliteDSN(cfg.Path)), gormConfig)
+		if err == nil && cfg.InMemory() {
+			// メモリ上のDBはコネクションごとに別のDBになり、コネクションを閉じると消えてしまう。
+			// 1本のコネクションを使い回し、閉じないようにする
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.SetMaxOpenConns(1)
+				sqlDB.SetMaxIdleConns(1)
+				sqlDB.SetConnMaxLifetime(0)
+				sqlDB.SetConnMaxIdleTime(0)
+			}
+		}
+	default:
+		// dsn := "host=localhost user=gorm password=gorm dbname=gorm port=9920 sslmode=disable TimeZone=Asia/Shanghai"
+		// db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		dsn := fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=%s",
+			cfg.Host,
+			cfg.User,
+			cfg.Password.Value(),
+			cfg.Name,
+			cfg.Port,
+			cfg.SSLMode,
+			cfg.TimeZone,
+		)
+		db, err = gorm.Open(postgres.Open(dsn), gormConfig)
+	}
 	if err != nil {
 		panic("Failed to connect database")
 	}
 	return db
 }
+
+// SQLiteには行ロック（SELECT ... FOR UPDATE）がないので、代わりに
+// トランザクションの開始時点でDB全体の書き込みロックを取る（BEGIN IMMEDIATE）。
+// ロックが取れない間はbusy_timeoutまで待つ
+func sqliteDSN(path string) string {
+	params := "_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+	if path == SQLiteInMemory {
+		return "file::memory:?" + params
+	}
+	return "file:" + path + "?" + params + "&_journal_mode=WAL"
+}
+
+// DBに接続できるかを確認する。ctxのタイムアウトで打ち切られる
+func PingDB(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
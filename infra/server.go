@@ -0,0 +1,30 @@
+package infra
+
+import (
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// タイムアウトやヘッダサイズの上限を設定したHTTPサーバーを作る
+// router.Run()だとこれらが無制限になり、遅いクライアントに接続を占有されてしまう
+func NewServer(cfg ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    int(cfg.MaxHeaderBytes),
+	}
+}
+
+// GORMが内部で持っているコネクションプールを閉じる
+func CloseDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
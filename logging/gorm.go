@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// GORMのログをslogで出力するロガー
+// ロガーはクエリのctxから取り出すので、db.WithContext(ctx)で呼ばれたクエリにはリクエストIDが付く
+type GormLogger struct {
+	// これより時間がかかったクエリは警告として出力する
+	SlowThreshold time.Duration
+}
+
+func NewGormLogger(slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{SlowThreshold: slowThreshold}
+}
+
+// 出力レベルはslogの設定で決めるので、GORM側のレベルは無視する
+func (l *GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	logger := FromContext(ctx)
+	elapsed := time.Since(begin)
+
+	switch {
+	// 見つからないのは正常系なのでエラーにしない
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.ErrorContext(ctx, "query failed", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed), slog.Any("error", err))
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold:
+		sql, rows := fc()
+		logger.WarnContext(ctx, "slow query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	case logger.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		logger.DebugContext(ctx, "query", slog.String("sql", sql), slog.Int64("rows", rows), slog.Duration("elapsed", elapsed))
+	}
+}
+
+// ログに出すSQLにはバインド値を埋め込まず、プレースホルダのままにする
+// （パスワードのハッシュなどがログに残らないようにするため）
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	return sql, nil
+}
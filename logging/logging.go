@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey struct{}
+
+// 値を出力してはいけない属性のキー（小文字で比較する）
+// リクエストボディやヘッダーをうっかりログに渡しても、これらは伏せて出力される
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"authorization": true,
+	"secret":        true,
+}
+
+// JSON形式で出力するロガーを作る
+// levelはdebug/info/warn/errorのいずれか
+func New(w io.Writer, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level: %s", level)
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: l,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if sensitiveKeys[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, "[REDACTED]")
+			}
+			return a
+		},
+	})
+	return slog.New(handler), nil
+}
+
+// ロガーをコンテキストに入れる
+// ミドルウェアでリクエストIDなどを付けたロガーを入れておけば、サービスやリポジトリのログにも同じ値が付く
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// コンテキストからロガーを取り出す。入っていなければデフォルトのロガーを返す
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
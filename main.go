@@ -1,53 +1,238 @@
 package main
 
 import (
-	"gin-freemarket/controllers"
+	"context"
+	"errors"
 	"gin-freemarket/infra"
+	"gin-freemarket/logging"
+	"gin-freemarket/metrics"
+	"gin-freemarket/migrate"
 	"gin-freemarket/repositories"
 	"gin-freemarket/services"
+	"gin-freemarket/tracing"
+	"gin-freemarket/validation"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ビルド時に埋め込む（例: go build -ldflags "-X main.version=1.2.0 -X main.commit=$(git rev-parse HEAD)"）
+var (
+	version = "dev"
+	commit  = ""
 )
 
 func main() {
 
-	infra.Initialize()
-	db := infra.SetupDB()
+	// 設定は起動時に1回だけ読み込み、必要な値を各コンストラクタに渡す
+	cfg, err := infra.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// ログはJSONで1行ずつ出力する。標準のlogパッケージの出力もこのロガーを通る
+	logger, err := logging.New(os.Stdout, cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Loaded configuration", slog.Any("config", cfg)) // シークレットは伏せて出力される
+
+	// トレースの送り先を設定する。DBのプラグインやミドルウェアより先に行う
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:       cfg.Tracing.Exporter,
+		File:           cfg.Tracing.File,
+		OTLPEndpoint:   cfg.Tracing.OTLPEndpoint,
+		ServiceName:    cfg.Tracing.ServiceName,
+		ServiceVersion: version,
+	})
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
-	// items := []models.Item{
-	// 	{ID: 1, Name: "商品1", Price: 1000, Description: "説明1", SoldOut: false},
-	// 	{ID: 2, Name: "商品2", Price: 2000, Description: "説明2", SoldOut: true},
-	// 	{ID: 3, Name: "商品3", Price: 3000, Description: "説明3", SoldOut: false},
-	// }
+	// 入力値の検証エラーをJSONのキー名・利用者の言語で返せるようにする
+	if err := validation.Setup(); err != nil {
+		log.Fatalf("Failed to set up validation: %v", err)
+	}
 
 	// リポジトリ形式にしているので、切り替えが簡単（大元を変えればいいだけ。）
 	// 実用的な例で言うと、モックで作っていた部分を本番ように差し替えたりするときに使える。
+	var db *gorm.DB
+	var repos repositorySet
+	// readyzで確認するDBの状態。メモリで動かすときは確認するものがない
+	var dbChecks []services.HealthCheck
+	if cfg.DB.Driver == infra.DriverMemory {
+		// サーバーのメモリをDB代わりにしたリポジトリ。再起動するとデータは消える
+		repos = newMemoryRepositories(repositories.NewMemoryStore())
+	} else {
+		var migrator *migrate.Migrator
+		db, migrator = setupDB(cfg.DB)
+		repos = newDBRepositories(db) // DBを利用したリポジトリ
+		dbChecks = []services.HealthCheck{
+			{Name: "database", Check: func(ctx context.Context) error { return infra.PingDB(ctx, db) }},
+			{Name: "migrations", Check: migrator.Check},
+		}
+	}
 
-	// itemRepository := repositories.NewItemMemoryRepository(items) //サーバーのメモリをDB代わりにしたリポジトリ
-	itemRepository := repositories.NewItemRepository(db) // DBを利用したリポジトリ
-	itemService := services.NewItemService(itemRepository)
-	itemController := controllers.NewItemController(itemService)
+	application := newApp(cfg, repos, dbChecks, logger)
 
-	authRepository := repositories.NewAuthRepository(db)
-	authService := services.NewAuthService(authRepository)
-	authController := controllers.NewAuthController(authService)
+	// SIGINT/SIGTERMを受け取ったらctxがキャンセルされる
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// エンドポイント設定
-	router := gin.Default()
+	// バックグラウンド処理はシグナルでは止めず、HTTPサーバーを止めた後で順番に止める
+	// （処理中のリクエストがホールドや注文を触っている間に止まらないようにするため）
+	application.reservationSweeper.Start(context.Background())
+	application.orderAutoCompleter.Start(context.Background())
+	application.pendingOrderCanceler.Start(context.Background())
+	application.idempotencyKeySweeper.Start(context.Background())
 
-	// ルーティングをグルーピング化する
-	itemRouter := router.Group("/items")
-	authRouter := router.Group("/auth")
+	server := infra.NewServer(cfg.Server, application.router)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", slog.String("addr", cfg.Server.Addr))
+		// Shutdownが呼ばれるとhttp.ErrServerClosedが返ってくるが、これは正常終了
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
 
-	itemRouter.GET("/", itemController.FindAll)
-	itemRouter.GET("/:id", itemController.FindById)
-	itemRouter.POST("/", itemController.Create)
-	itemRouter.PUT("/:id", itemController.Update)
-	itemRouter.DELETE("/:id", itemController.Delete)
+	// シグナルを受け取るか、サーバーの起動に失敗するまで待つ
+	select {
+	case <-ctx.Done():
+		slog.Info("Shutting down...")
+	case err := <-serverErr:
+		slog.Error("Server error", slog.Any("error", err))
+	}
+	// 2回目のシグナルではすぐに終了できるよう、シグナルの捕捉をやめる
+	stop()
 
-	authRouter.POST("/signup", authController.Signup)
-	authRouter.POST("/login", authController.Login)
+	// 0. readyzを失敗させ、ロードバランサが振り分け先から外すのを待つ
+	application.healthService.SetShuttingDown()
+	time.Sleep(cfg.Server.DrainDelay)
+
+	// 1. 新しい接続の受付をやめ、処理中のリクエストが終わるのを待つ（最大ShutdownTimeoutまで）
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to drain connections", slog.Any("error", err))
+	}
+
+	// 2. バックグラウンド処理を止める（実行中の処理は最後まで行われる）
+	application.reservationSweeper.Stop()
+	application.orderAutoCompleter.Stop()
+	application.pendingOrderCanceler.Stop()
+	application.idempotencyKeySweeper.Stop()
+
+	// 3. 最後にDBのコネクションプールを閉じる
+	if db != nil {
+		if err := infra.CloseDB(db); err != nil {
+			slog.Error("Failed to close database", slog.Any("error", err))
+		}
+	}
+	// 4. 残っているスパンを送り切る
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Failed to flush traces", slog.Any("error", err))
+	}
+	slog.Info("Server stopped")
+}
 
-	router.Run("localhost:8080") // デフォルトで0.0.0.0:8080で待機します
+// バックグラウンド処理が動いているかを確認するチェックを作る
+func workerCheck(running func() bool) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if !running() {
+			return errors.New("not running")
+		}
+		return nil
+	}
+}
+
+// DBに接続し、メトリクス・トレースのプラグインとマイグレーションを準備する
+func setupDB(cfg infra.DBConfig) (*gorm.DB, *migrate.Migrator) {
+	db := infra.SetupDB(cfg)
+	// クエリの実行時間・エラーとコネクションプールの状態をメトリクスとして記録する
+	if err := db.Use(metrics.NewGormPlugin()); err != nil {
+		log.Fatalf("Failed to register metrics plugin: %v", err)
+	}
+	// クエリごとのスパンを記録する（db.WithContext(ctx)で渡されたリクエストのスパンの子になる）
+	if err := db.Use(tracing.NewGormPlugin()); err != nil {
+		log.Fatalf("Failed to register tracing plugin: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database connection: %v", err)
+	}
+	dbName := cfg.Name
+	if cfg.Driver == infra.DriverSQLite {
+		dbName = cfg.Path
+	}
+	if err := metrics.RegisterDBStats(sqlDB, dbName); err != nil {
+		log.Fatalf("Failed to register database metrics: %v", err)
+	}
+	// マイグレーションはgo run ./migrations upで別途適用する。ここではreadyzで適用状況を確認するためだけに使う
+	migrator, err := migrate.New(sqlDB, cfg.Driver)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	// メモリ上のSQLiteは起動のたびに空なので、ここでテーブルを作る
+	if cfg.InMemory() {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+	}
+	return db, migrator
+}
+
+// main.goで組み立てるリポジトリ一式
+type repositorySet struct {
+	item        repositories.IItemRepository
+	auth        repositories.IAuthRepository
+	reservation repositories.IReservationRepository
+	offer       repositories.IOfferRepository
+	address     repositories.IAddressRepository
+	order       repositories.IOrderRepository
+	ledger      repositories.ILedgerRepository
+	shipment    repositories.IShipmentRepository
+	idempotency repositories.IIdempotencyKeyRepository
+	category    repositories.ICategoryRepository
+	review      repositories.IReviewRepository
+}
+
+func newDBRepositories(db *gorm.DB) repositorySet {
+	return repositorySet{
+		item:        repositories.NewItemRepository(db),
+		auth:        repositories.NewAuthRepository(db),
+		reservation: repositories.NewReservationRepository(db),
+		offer:       repositories.NewOfferRepository(db),
+		address:     repositories.NewAddressRepository(db),
+		order:       repositories.NewOrderRepository(db),
+		ledger:      repositories.NewLedgerRepository(db),
+		shipment:    repositories.NewShipmentRepository(db),
+		idempotency: repositories.NewIdempotencyKeyRepository(db),
+		category:    repositories.NewCategoryRepository(db),
+		review:      repositories.NewReviewRepository(db),
+	}
+}
 
+// すべてのリポジトリが同じMemoryStoreを共有する（注文の更新で商品や仕訳もまとめて書き換えるため）
+func newMemoryRepositories(store *repositories.MemoryStore) repositorySet {
+	return repositorySet{
+		item:        repositories.NewItemMemoryRepository(store),
+		auth:        repositories.NewAuthMemoryRepository(store),
+		reservation: repositories.NewReservationMemoryRepository(store),
+		offer:       repositories.NewOfferMemoryRepository(store),
+		address:     repositories.NewAddressMemoryRepository(store),
+		order:       repositories.NewOrderMemoryRepository(store),
+		ledger:      repositories.NewLedgerMemoryRepository(store),
+		shipment:    repositories.NewShipmentMemoryRepository(store),
+		idempotency: repositories.NewIdempotencyKeyMemoryRepository(store),
+		category:    repositories.NewCategoryMemoryRepository(store),
+		review:      repositories.NewReviewMemoryRepository(store),
+	}
 }
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// /metricsで公開するメトリクスを登録するレジストリ
+// グローバルのDefaultRegistererではなく専用のものを使い、何を公開しているかをこのパッケージで把握できるようにする
+var Registry = prometheus.NewRegistry()
+
+// HTTP
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route template and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// DB
+var (
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "GORM query latency by operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	DBQueryErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Number of failed GORM queries by operation and table (record not found is not counted).",
+	}, []string{"operation", "table"})
+)
+
+// ビジネスイベント
+var (
+	SignupsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "freemarket_signups_total",
+		Help: "Number of users signed up.",
+	})
+
+	LoginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "freemarket_logins_total",
+		Help: "Number of login attempts by result (success or failure).",
+	}, []string{"result"})
+
+	ItemsCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "freemarket_items_created_total",
+		Help: "Number of items listed.",
+	})
+
+	OrdersPlacedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "freemarket_orders_placed_total",
+		Help: "Number of orders placed.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		DBQueryDuration,
+		DBQueryErrorsTotal,
+		SignupsTotal,
+		LoginsTotal,
+		ItemsCreatedTotal,
+		OrdersPlacedTotal,
+	)
+	// ラベルの組み合わせが出揃う前でも0として見えるようにしておく
+	LoginsTotal.WithLabelValues("success")
+	LoginsTotal.WithLabelValues("failure")
+}
@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizationヘッダの「Bearer <token>」からユーザーを特定し、ctxに"user"として詰めるミドルウェア
+// 後続のコントローラでは ctx.MustGet("user").(*models.User) で取り出せる
+func AuthMiddleware(authService services.IAuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		header := ctx.GetHeader("Authorization")
+		if header == "" {
+			problem.Respond(ctx, http.StatusUnauthorized, "Authorization header is required")
+			return
+		}
+
+		if !strings.HasPrefix(header, "Bearer ") {
+			problem.Respond(ctx, http.StatusUnauthorized, "Authorization header must be a Bearer token")
+			return
+		}
+
+		tokenString := strings.TrimPrefix(header, "Bearer ")
+		user, err := authService.GetUserFromToken(ctx.Request.Context(), tokenString)
+		if err != nil || user == nil {
+			problem.Respond(ctx, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		ctx.Set("user", user)
+
+		// 後続の処理（コントローラ）へ
+		ctx.Next()
+	}
+}
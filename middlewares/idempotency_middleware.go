@@ -0,0 +1,136 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gin-freemarket/logging"
+	"gin-freemarket/models"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// 保存しておいたレスポンスを返したときに付けるヘッダー
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// 再送時にも返すレスポンスヘッダー
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// Idempotency-Keyヘッダの付いたPOSTを1回だけ処理するミドルウェア
+// 通信が不安定で再送されても、同じキーなら処理をやり直さずに最初のレスポンスをそのまま返す。
+// キーはユーザーごとなので、AuthMiddlewareの後に置くこと
+func IdempotencyMiddleware(idempotencyService services.IIdempotencyService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if ctx.Request.Method != http.MethodPost || key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Respond(ctx, http.StatusBadRequest, "Idempotency-Key must be 255 characters or less")
+			return
+		}
+		user := ctx.MustGet("user").(*models.User)
+
+		// ボディはハッシュを取るために読み切るので、コントローラでもう一度読めるよう戻しておく
+		body, err := ctx.GetRawData()
+		if err != nil {
+			problem.Respond(ctx, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := idempotencyService.Begin(ctx.Request.Context(), user.ID, key, fingerprint(ctx.Request, body))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrIdempotencyKeyInUse):
+				problem.Respond(ctx, http.StatusConflict, err.Error())
+			case errors.Is(err, services.ErrIdempotencyKeyReused):
+				problem.Respond(ctx, http.StatusUnprocessableEntity, err.Error())
+			default:
+				problem.Internal(ctx, err)
+			}
+			return
+		}
+		if stored != nil {
+			for name, values := range stored.Header {
+				ctx.Writer.Header()[http.CanonicalHeaderKey(name)] = values
+			}
+			ctx.Header(IdempotentReplayedHeader, "true")
+			ctx.Status(stored.StatusCode)
+			ctx.Writer.Write(stored.Body)
+			ctx.Abort()
+			return
+		}
+
+		// 処理の結果は、タイムアウトやクライアントの切断でリクエストのctxが終わっていても保存する
+		saveCtx := context.WithoutCancel(ctx.Request.Context())
+		completed := false
+		// パニックした場合も含め、レスポンスを保存できなかったらキーを消して同じキーでやり直せるようにする
+		defer func() {
+			if completed {
+				return
+			}
+			if err := idempotencyService.Abandon(saveCtx, user.ID, key); err != nil {
+				logging.FromContext(saveCtx).Error("Failed to release idempotency key", slog.Any("error", err))
+			}
+		}()
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		// サーバー側の失敗（5xx）は一時的なものかもしれないので保存せず、再送で処理し直す
+		if !writer.Written() || writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if values := writer.Header().Values(name); len(values) > 0 {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+		}
+		response := services.StoredResponse{StatusCode: writer.Status(), Header: header, Body: writer.body.Bytes()}
+		if err := idempotencyService.Complete(saveCtx, user.ID, key, response); err != nil {
+			logging.FromContext(saveCtx).Error("Failed to save idempotent response", slog.Any("error", err))
+			return
+		}
+		completed = true
+	}
+}
+
+// リクエストの内容を表すハッシュ。同じキーが別のエンドポイント・別のボディで使われたことを見分ける
+func fingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+"\n"+req.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// クライアントに返したボディを保存できるよう、書き込んだ内容を手元にも残すResponseWriter
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
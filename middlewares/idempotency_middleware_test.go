@@ -0,0 +1,101 @@
+package middlewares_test
+
+import (
+	"gin-freemarket/middlewares"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 認証済みのユーザーを詰めてからIdempotencyMiddlewareを通し、handlerを呼ぶルーター
+func newIdempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	service := services.NewIdempotencyService(repositories.NewIdempotencyKeyMemoryRepository(repositories.NewMemoryStore()), time.Hour, time.Minute)
+	router := gin.New()
+	router.POST("/things",
+		func(ctx *gin.Context) { ctx.Set("user", &models.User{Email: "user@example.com"}) },
+		middlewares.IdempotencyMiddleware(service),
+		handler,
+	)
+	return router
+}
+
+func post(router http.Handler, key string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddlewareInFlight(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		close(started)
+		<-finish
+		ctx.JSON(http.StatusCreated, gin.H{"data": "created"})
+	})
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post(router, "key-1", `{"a":1}`) }()
+	<-started
+
+	// 最初のリクエストの処理中に同じキーで来たものは処理しない
+	if rec := post(router, "key-1", `{"a":1}`); rec.Code != http.StatusConflict {
+		t.Fatalf("in-flight duplicate: status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	// 内容が違えば、処理中でも使い回しとして扱う
+	if rec := post(router, "key-1", `{"a":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("different body: status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+
+	close(finish)
+	if rec := <-first; rec.Code != http.StatusCreated {
+		t.Fatalf("first request: status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := post(router, "key-1", `{"a":1}`); rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry: status = %d, Idempotent-Replayed = %q, want a replayed %d", rec.Code, rec.Header().Get("Idempotent-Replayed"), http.StatusCreated)
+	}
+}
+
+func TestIdempotencyMiddlewareDoesNotStoreFailures(t *testing.T) {
+	calls := 0
+	router := newIdempotentRouter(func(ctx *gin.Context) {
+		calls++
+		switch calls {
+		case 1:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"detail": "temporary failure"})
+		case 2:
+			panic("handler panicked")
+		default:
+			ctx.JSON(http.StatusCreated, gin.H{"data": "created"})
+		}
+	})
+
+	if rec := post(router, "key-1", `{}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	func() {
+		// RecoveryMiddlewareの代わりにここでパニックを受け止める
+		defer func() { recover() }()
+		post(router, "key-1", `{}`)
+	}()
+
+	// 5xxやパニックの後は、同じキーで処理し直せる
+	rec := post(router, "key-1", `{}`)
+	if rec.Code != http.StatusCreated || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("status = %d, Idempotent-Replayed = %q, want a fresh %d", rec.Code, rec.Header().Get("Idempotent-Replayed"), http.StatusCreated)
+	}
+	if calls != 3 {
+		t.Fatalf("handler was called %d times, want 3", calls)
+	}
+}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gin-freemarket/logging"
+	"gin-freemarket/models"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// 呼び出し元から受け取るリクエストIDの形式。ログを壊されないよう、英数字と一部の記号だけ許可する
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// リクエストIDを割り当て、処理結果をJSONで1行ログに出すミドルウェア
+// X-Request-IDが送られてきた場合はそれを引き継ぎ、なければ新しく発行する。
+// リクエストIDを付けたロガーはctx.Request.Context()に入れるので、logging.FromContext(ctx)で取り出せる。
+// コントローラでctx.Error(err)に渡したエラーは、レスポンスには出さずにこのログのerrorsとして出力する。
+// ボディやヘッダーは出力しない（パスワードやトークンが含まれるため）
+func LoggingMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+		ctx.Set("request_id", requestID)
+
+		requestLogger := logger.With(slog.String("request_id", requestID))
+		// トレースが有効ならトレースIDも付け、ログからトレースを辿れるようにする
+		if sc := trace.SpanContextFromContext(ctx.Request.Context()); sc.IsValid() {
+			requestLogger = requestLogger.With(slog.String("trace_id", sc.TraceID().String()))
+		}
+		ctx.Request = ctx.Request.WithContext(logging.WithContext(ctx.Request.Context(), requestLogger))
+
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			// クエリ文字列は含めない
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+		if user, ok := ctx.Get("user"); ok {
+			if u, ok := user.(*models.User); ok {
+				attrs = append(attrs, slog.Uint64("user_id", uint64(u.ID)))
+			}
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.Any("errors", ctx.Errors.Errors()))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		requestLogger.LogAttrs(ctx.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// 16バイトの乱数を16進数にしたリクエストIDを作る
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
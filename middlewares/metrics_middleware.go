@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"gin-freemarket/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// リクエスト数とレイテンシを記録するミドルウェア
+// パスは生の値（/items/1）ではなくルート定義（/items/:id）で集計し、ラベルの種類が増え続けないようにする
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			// どのルートにも一致しなかった（404）
+			route = "unmatched"
+		}
+		status := strconv.Itoa(ctx.Writer.Status())
+
+		metrics.HTTPRequestsTotal.WithLabelValues(ctx.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(ctx.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,70 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"gin-freemarket/logging"
+	"gin-freemarket/problem"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ハンドラ内のpanicを捕まえ、スタックトレースをログに出して500を返すミドルウェア
+// gin.Recovery()は空のボディを返すので、代わりにこちらを使う。
+// ログはリクエストIDの付いたロガーに出すので、LoggingMiddlewareより後ろに登録すること
+func RecoveryMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// http.ErrAbortHandlerはレスポンスを打ち切るための意図的なpanicなので、そのまま投げ直す
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			logger := logging.FromContext(ctx.Request.Context())
+			err, ok := recovered.(error)
+			if !ok {
+				err = fmt.Errorf("%v", recovered)
+			}
+
+			// クライアントが切断していた場合は、レスポンスを書けないので記録だけして終わる
+			if isBrokenPipe(err) {
+				logger.Warn("connection closed by client", slog.Any("error", err))
+				ctx.Error(err)
+				ctx.Abort()
+				return
+			}
+
+			logger.Error("panic recovered", slog.Any("panic", err), slog.String("stack", string(debug.Stack())))
+			if ctx.Writer.Written() {
+				// 既にレスポンスを返し始めているので、ステータスは変えられない
+				ctx.Abort()
+				return
+			}
+			problem.Internal(ctx, err)
+		}()
+
+		ctx.Next()
+	}
+}
+
+func isBrokenPipe(err error) bool {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var syscallErr *os.SyscallError
+	if errors.As(opErr, &syscallErr) {
+		return errors.Is(syscallErr.Err, syscall.EPIPE) || errors.Is(syscallErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
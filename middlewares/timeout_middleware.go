@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/problem"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// リクエストごとに処理時間の上限を設けるミドルウェア
+// ctx.Request.Context()に期限を付けるので、サービス・リポジトリへ渡したctx経由でDBのクエリも打ち切られる。
+// クライアントが切断した場合も、net/httpがこのコンテキストをキャンセルしてくれる
+func TimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		timeoutCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(timeoutCtx)
+		ctx.Next()
+
+		// ハンドラがまだ何も返していなければ、タイムアウトしたことを伝える
+		if !ctx.Writer.Written() && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			problem.Respond(ctx, http.StatusGatewayTimeout, "Request timed out")
+		}
+	}
+}
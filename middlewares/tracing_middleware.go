@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"gin-freemarket/tracing"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// リクエストごとにサーバースパンを作るミドルウェア
+// 呼び出し元からtraceparentヘッダーが送られてきた場合は、そのトレースの子スパンになる。
+// スパンはctx.Request.Context()に入れるので、サービスやリポジトリにはそのコンテキストを渡す
+func TracingMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parent := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		// スパン名もメトリクスと同じくルート定義（/items/:id）にして、種類が増え続けないようにする
+		route := ctx.FullPath()
+		name := ctx.Request.Method + " " + route
+		if route == "" {
+			name = ctx.Request.Method
+		}
+
+		spanCtx, span := tracing.Tracer().Start(parent, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Request.Method),
+				semconv.URLPath(ctx.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		if route != "" {
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		// 4xxはクライアント側の問題なので、サーバースパンではエラーにしない
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		for _, err := range ctx.Errors {
+			span.RecordError(err.Err)
+		}
+	}
+}
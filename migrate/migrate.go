@@ -0,0 +1,392 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// マイグレーションのSQLファイル。DBの種類ごとにディレクトリを分けている
+// ファイル名は「<バージョン>_<名前>.up.sql」「<バージョン>_<名前>.down.sql」
+//
+//go:embed sql
+var files embed.FS
+
+// 同じDBに対して複数のプロセスが同時にマイグレーションしないよう、pg_advisory_lockで使うキー
+const advisoryLockKey int64 = 727_001_041
+
+const (
+	dialectPostgres = "postgres"
+	dialectSQLite   = "sqlite"
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // upのSQLのsha256。適用後にファイルが書き換えられていないかの確認に使う
+}
+
+// 適用状況
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// 適用時と今のファイルでチェックサムが違う（適用後にファイルが書き換えられた）
+	Modified bool
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// 埋め込まれたSQLファイルを読み込んだMigratorを作る
+// dialectはDBの種類（postgresまたはsqlite）で、sql/以下のディレクトリ名と同じ
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	if dialect != dialectPostgres && dialect != dialectSQLite {
+		return nil, fmt.Errorf("unsupported dialect: %s", dialect)
+	}
+	sub, err := fs.Sub(files, "sql/"+dialect)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := Load(sub)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// ディレクトリ内のSQLファイルを読み込み、バージョン順に並べて返す
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := fileNamePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name: %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has different names: %s, %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(body)
+			sum := sha256.Sum256(body)
+			migration.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// 未適用のマイグレーションをすべて適用し、適用した件数を返す
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.ensureTable(ctx, conn); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if record, ok := applied[migration.Version]; ok {
+				if record.checksum != migration.Checksum {
+					return fmt.Errorf("migration %d_%s was modified after it was applied", migration.Version, migration.Name)
+				}
+				continue
+			}
+			if err := m.run(ctx, conn, migration.Up, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES ($1, $2, $3, $4)`,
+					migration.Version, migration.Name, migration.Checksum, time.Now())
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// 適用済みのマイグレーションを新しい順にn件取り消し、取り消した件数を返す
+func (m *Migrator) Down(ctx context.Context, n int) (int, error) {
+	count := 0
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.ensureTable(ctx, conn); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			if err := m.run(ctx, conn, migration.Down, func(tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// すべてのマイグレーションの適用状況を返す
+// 一度もマイグレーションしていないDBでは、すべて未適用になる
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return m.statuses(ctx, conn)
+}
+
+func (m *Migrator) statuses(ctx context.Context, conn *sql.Conn) ([]Status, error) {
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.appliedAt
+			status.Modified = record.checksum != migration.Checksum
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// DBがこのバイナリの期待するバージョンになっているかを確認する（readyzで使う）
+// 頻繁に呼ばれるので、読み取りだけでDBには何も書き込まない
+func (m *Migrator) Check(ctx context.Context) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	exists, err := m.tableExists(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("database is not migrated")
+	}
+	statuses, err := m.statuses(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			return fmt.Errorf("migration %d_%s is not applied", status.Version, status.Name)
+		}
+		if status.Modified {
+			return fmt.Errorf("migration %d_%s was modified after it was applied", status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+type appliedRecord struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// 適用状況を記録するテーブルがなければ作る。マイグレーションを実行するときだけ呼ぶ
+func (m *Migrator) ensureTable(ctx context.Context, conn *sql.Conn) error {
+	// SQLiteのドライバは列の型がdatetimeのときだけtime.Timeとして読み込むので、型を変えている
+	timeType := "timestamptz"
+	if m.dialect == dialectSQLite {
+		timeType = "datetime"
+	}
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    bigint PRIMARY KEY,
+		name       text NOT NULL,
+		checksum   text NOT NULL,
+		applied_at `+timeType+` NOT NULL
+	)`)
+	return err
+}
+
+// 適用状況を記録するテーブルがあるかどうか
+func (m *Migrator) tableExists(ctx context.Context, conn *sql.Conn) (bool, error) {
+	query := `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	if m.dialect == dialectSQLite {
+		query = `SELECT count(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+	var exists bool
+	if err := conn.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// 適用済みのマイグレーションをバージョンごとに返す。テーブルがなければ空になる
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedRecord, error) {
+	exists, err := m.tableExists(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int64]appliedRecord{}, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]appliedRecord{}
+	for rows.Next() {
+		var version int64
+		var record appliedRecord
+		if err := rows.Scan(&version, &record.checksum, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+// SQLとschema_migrationsの更新を1つのトランザクションで実行する
+// 途中で失敗すればどちらもロールバックされる
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, statements string, record func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if err := record(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// アドバイザリロックを取ってからfnを実行する（postgresのみ）
+// ロックはセッション単位なので、取得から解放まで同じコネクションを使う
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// SQLiteにはアドバイザリロックがない。各マイグレーションのトランザクションがDB全体をロックするので、
+	// 同時に実行されても後から来た方はschema_migrationsの主キー重複で失敗し、二重には適用されない
+	if m.dialect == dialectSQLite {
+		return fn(conn)
+	}
+
+	// 他のプロセスがマイグレーション中なら、終わるまでここで待つ
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		// ctxがキャンセルされていても解放できるよう、キャンセルを引き継がないコンテキストを使う
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, advisoryLockKey)
+	}()
+
+	return fn(conn)
+}
+
+// 次のバージョン番号で空のup/downファイルを作り、作ったファイルのパスを返す
+func Create(dir string, name string) ([]string, error) {
+	if !regexp.MustCompile(`^[a-z0-9_]+$`).MatchString(name) {
+		return nil, errors.New("migration name must consist of lowercase letters, digits and underscores")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%04d_%s", next, name)
+	paths := []string{filepath.Join(dir, base+".up.sql"), filepath.Join(dir, base+".down.sql")}
+	for _, p := range paths {
+		if err := writeNewFile(p, "-- "+base+"\n"); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// 既にファイルがある場合は上書きせずにエラーにする
+func writeNewFile(path string, content string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	return f.Close()
+}
@@ -0,0 +1,52 @@
+package migrate_test
+
+import (
+	"context"
+	"gin-freemarket/infra"
+	"gin-freemarket/migrate"
+	"testing"
+)
+
+// readyzから呼ばれるCheckはDBを書き換えない。未マイグレーションのDBは「マイグレーションされていない」と報告する
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	db := infra.SetupDB(infra.DBConfig{Driver: infra.DriverSQLite, Path: infra.SQLiteInMemory})
+	t.Cleanup(func() { infra.CloseDB(db) })
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database connection: %v", err)
+	}
+	migrator, err := migrate.New(sqlDB, infra.DriverSQLite)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+
+	if err := migrator.Check(ctx); err == nil {
+		t.Fatal("Check succeeded on an empty database")
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Fatalf("migration %d_%s is applied on an empty database", status.Version, status.Name)
+		}
+	}
+	if db.Migrator().HasTable("schema_migrations") {
+		t.Fatal("Check or Status created schema_migrations")
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := migrator.Check(ctx); err != nil {
+		t.Fatalf("Check after Up: %v", err)
+	}
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if err := migrator.Check(ctx); err == nil {
+		t.Fatal("Check succeeded with a reverted migration")
+	}
+}
@@ -0,0 +1,14 @@
+-- 外部キーで参照されている側を後に消す
+DROP TABLE IF EXISTS shipment_events;
+DROP TABLE IF EXISTS shipments;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS payouts;
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP TABLE IF EXISTS webhook_events;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS offers;
+DROP TABLE IF EXISTS items;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,202 @@
+-- AutoMigrateで作っていたテーブルをそのまま定義したもの。
+-- 既にAutoMigrateでテーブルが作られているDBにも適用できるよう、IF NOT EXISTSを付けている
+
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    email      text NOT NULL,
+    password   text NOT NULL,
+    CONSTRAINT uni_users_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS items (
+    id                bigserial PRIMARY KEY,
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    deleted_at        timestamptz,
+    name              text NOT NULL,
+    price             bigint NOT NULL,
+    quantity          bigint,
+    description       text,
+    sold_out          boolean NOT NULL DEFAULT false,
+    user_id           bigint NOT NULL,
+    shipping_method   text,
+    shipping_payer    text NOT NULL DEFAULT 'seller',
+    shipping_fee      bigint,
+    ships_within_days bigint NOT NULL DEFAULT 3
+);
+CREATE INDEX IF NOT EXISTS idx_items_deleted_at ON items (deleted_at);
+
+CREATE TABLE IF NOT EXISTS offers (
+    id            bigserial PRIMARY KEY,
+    created_at    timestamptz,
+    updated_at    timestamptz,
+    deleted_at    timestamptz,
+    item_id       bigint NOT NULL,
+    buyer_id      bigint NOT NULL,
+    price         bigint NOT NULL,
+    counter_price bigint,
+    agreed_price  bigint,
+    status        text NOT NULL DEFAULT 'pending',
+    expires_at    timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_offers_deleted_at ON offers (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_offers_item_id ON offers (item_id);
+CREATE INDEX IF NOT EXISTS idx_offers_buyer_id ON offers (buyer_id);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    item_id    bigint NOT NULL,
+    user_id    bigint NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_deleted_at ON reservations (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_reservations_item_id ON reservations (item_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_expires_at ON reservations (expires_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id                  bigserial PRIMARY KEY,
+    created_at          timestamptz,
+    updated_at          timestamptz,
+    deleted_at          timestamptz,
+    item_id             bigint NOT NULL,
+    buyer_id            bigint NOT NULL,
+    seller_id           bigint NOT NULL,
+    price               bigint NOT NULL,
+    shipping_fee        bigint,
+    fee                 bigint,
+    status              text NOT NULL DEFAULT 'pending',
+    payment_intent_id   text,
+    ship_to_name        text NOT NULL,
+    ship_to_postal_code text NOT NULL,
+    ship_to_prefecture  text NOT NULL,
+    ship_to_city        text NOT NULL,
+    ship_to_line1       text NOT NULL,
+    ship_to_line2       text,
+    ship_to_phone       text NOT NULL,
+    delivered_at        timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_orders_item_id ON orders (item_id);
+CREATE INDEX IF NOT EXISTS idx_orders_buyer_id ON orders (buyer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_seller_id ON orders (seller_id);
+CREATE INDEX IF NOT EXISTS idx_orders_payment_intent_id ON orders (payment_intent_id);
+CREATE INDEX IF NOT EXISTS idx_orders_delivered_at ON orders (delivered_at);
+
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    provider   text NOT NULL,
+    event_id   text NOT NULL,
+    type       text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_deleted_at ON webhook_events (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_event ON webhook_events (provider, event_id);
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name       text NOT NULL,
+    user_id    bigint,
+    CONSTRAINT uni_ledger_accounts_name UNIQUE (name)
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_deleted_at ON ledger_accounts (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_user_id ON ledger_accounts (user_id);
+
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    reference   text NOT NULL,
+    description text
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_deleted_at ON ledger_transactions (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_transactions_reference ON ledger_transactions (reference);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    updated_at     timestamptz,
+    deleted_at     timestamptz,
+    transaction_id bigint NOT NULL,
+    account_id     bigint NOT NULL,
+    direction      text NOT NULL,
+    amount         bigint NOT NULL,
+    CONSTRAINT fk_ledger_transactions_entries FOREIGN KEY (transaction_id) REFERENCES ledger_transactions (id),
+    CONSTRAINT fk_ledger_entries_account FOREIGN KEY (account_id) REFERENCES ledger_accounts (id)
+);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_deleted_at ON ledger_entries (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction_id ON ledger_entries (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account_id ON ledger_entries (account_id);
+
+CREATE TABLE IF NOT EXISTS payouts (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    updated_at     timestamptz,
+    deleted_at     timestamptz,
+    user_id        bigint NOT NULL,
+    amount         bigint NOT NULL,
+    status         text NOT NULL DEFAULT 'requested',
+    transaction_id bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_payouts_deleted_at ON payouts (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_payouts_user_id ON payouts (user_id);
+
+CREATE TABLE IF NOT EXISTS addresses (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    user_id     bigint NOT NULL,
+    name        text NOT NULL,
+    postal_code text NOT NULL,
+    prefecture  text NOT NULL,
+    city        text NOT NULL,
+    line1       text NOT NULL,
+    line2       text,
+    phone       text NOT NULL,
+    is_default  boolean NOT NULL DEFAULT false
+);
+CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON addresses (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses (user_id);
+
+CREATE TABLE IF NOT EXISTS shipments (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz,
+    order_id        bigint NOT NULL,
+    carrier         text NOT NULL,
+    tracking_number text NOT NULL,
+    status          text NOT NULL,
+    CONSTRAINT fk_orders_shipment FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+CREATE INDEX IF NOT EXISTS idx_shipments_deleted_at ON shipments (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_order_id ON shipments (order_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipment_tracking ON shipments (carrier, tracking_number);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    shipment_id bigint NOT NULL,
+    external_id text NOT NULL,
+    status      text NOT NULL,
+    description text,
+    occurred_at timestamptz NOT NULL,
+    CONSTRAINT fk_shipments_events FOREIGN KEY (shipment_id) REFERENCES shipments (id)
+);
+CREATE INDEX IF NOT EXISTS idx_shipment_events_deleted_at ON shipment_events (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipment_event ON shipment_events (shipment_id, external_id);
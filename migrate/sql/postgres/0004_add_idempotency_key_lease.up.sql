@@ -0,0 +1,3 @@
+-- 処理中のキーを持っていられる期限。過ぎたら別のリクエストが引き継げる。
+-- 既存の処理中のキーは期限切れとして扱い、すぐに引き継げるようにする
+ALTER TABLE idempotency_keys ADD COLUMN locked_until timestamptz NOT NULL DEFAULT 'epoch';
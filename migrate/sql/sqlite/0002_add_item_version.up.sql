@@ -0,0 +1,2 @@
+-- 商品の楽観的排他制御に使うバージョン。既存の商品はバージョン1から始める
+ALTER TABLE items ADD COLUMN version integer NOT NULL DEFAULT 1;
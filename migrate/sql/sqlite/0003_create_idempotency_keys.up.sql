@@ -0,0 +1,17 @@
+-- Idempotency-Keyを付けたリクエストの処理結果。期限切れの行は物理削除する
+CREATE TABLE idempotency_keys (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    created_at      datetime,
+    updated_at      datetime,
+    deleted_at      datetime,
+    user_id         integer NOT NULL,
+    key             text NOT NULL,
+    fingerprint     text NOT NULL,
+    status_code     integer,
+    response_header text,
+    response_body   blob,
+    expires_at      datetime NOT NULL
+);
+CREATE INDEX idx_idempotency_keys_deleted_at ON idempotency_keys (deleted_at);
+CREATE UNIQUE INDEX idx_idempotency_key ON idempotency_keys (user_id, key);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reviews;
+DROP INDEX IF EXISTS idx_items_category_id;
+ALTER TABLE items DROP COLUMN category_id;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,29 @@
+-- 商品のカテゴリ。商品のcategory_idが0のものは未分類
+CREATE TABLE categories (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    name       text NOT NULL
+);
+CREATE INDEX idx_categories_deleted_at ON categories (deleted_at);
+CREATE UNIQUE INDEX idx_categories_name ON categories (name);
+
+ALTER TABLE items ADD COLUMN category_id integer NOT NULL DEFAULT 0;
+CREATE INDEX idx_items_category_id ON items (category_id);
+
+-- 取引が完了した注文について、購入者と出品者がお互いを評価する。1つの注文につき1人1件
+CREATE TABLE reviews (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    created_at  datetime,
+    updated_at  datetime,
+    deleted_at  datetime,
+    order_id    integer NOT NULL,
+    reviewer_id integer NOT NULL,
+    reviewee_id integer NOT NULL,
+    rating      integer NOT NULL,
+    comment     text
+);
+CREATE INDEX idx_reviews_deleted_at ON reviews (deleted_at);
+CREATE UNIQUE INDEX idx_review ON reviews (order_id, reviewer_id);
+CREATE INDEX idx_reviews_reviewee_id ON reviews (reviewee_id);
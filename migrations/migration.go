@@ -1,16 +1,122 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"gin-freemarket/infra"
-	"gin-freemarket/models"
+	"gin-freemarket/migrate"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
 )
 
+const usage = `Usage: go run ./migrations [-dir DIR] <command>
+
+Commands:
+  up            未適用のマイグレーションをすべて適用する
+  down N        適用済みのマイグレーションを新しい順にN件取り消す
+  status        マイグレーションの適用状況を表示する
+  create NAME   次のバージョンのup/downファイルを作る（DBには接続しない）
+                -dirを省略するとpostgres・sqliteの両方のディレクトリに作る
+`
+
 func main() {
-	infra.Initialize()
+	dir := flag.String("dir", "", "create NAMEでファイルを作るディレクトリ")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// createはファイルを作るだけなので、設定の読み込みやDBへの接続は不要
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		// DBの種類ごとにSQLを書き分ける必要があるので、既定では両方に同じバージョンのファイルを作る
+		dirs := []string{"migrate/sql/postgres", "migrate/sql/sqlite"}
+		if *dir != "" {
+			dirs = []string{*dir}
+		}
+		for _, d := range dirs {
+			paths, err := migrate.Create(d, args[1])
+			if err != nil {
+				log.Fatalf("Failed to create migration: %v", err)
+			}
+			for _, p := range paths {
+				fmt.Println(p)
+			}
+		}
+		return
+	}
 
-	db := infra.SetupDB()
+	cfg, err := infra.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// メモリで動かすときは保存先のDBがない
+	if cfg.DB.Driver == infra.DriverMemory {
+		log.Fatalf("DB_DRIVER=%s has no database to connect to", infra.DriverMemory)
+	}
+	db := infra.SetupDB(cfg.DB)
+	defer infra.CloseDB(db)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database connection: %v", err)
+	}
+	migrator, err := migrate.New(sqlDB, cfg.DB.Driver)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
 
-	if err := db.AutoMigrate(&models.Item{}, &models.User{}); err != nil {
-		panic("Failed to migrate database")
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate: %v", err)
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+	case "down":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			log.Fatalf("N must be a positive number: %s", args[1])
+		}
+		reverted, err := migrator.Down(ctx, n)
+		if err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
+		}
+		fmt.Printf("Reverted %d migrations\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: auth_repository.go
+//
+// Generated by this command:
+//
+//	mockgen -source=auth_repository.go -destination=../mocks/mock_auth_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	models "gin-freemarket/models"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIAuthRepository is a mock of IAuthRepository interface.
+type MockIAuthRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAuthRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockIAuthRepositoryMockRecorder is the mock recorder for MockIAuthRepository.
+type MockIAuthRepositoryMockRecorder struct {
+	mock *MockIAuthRepository
+}
+
+// NewMockIAuthRepository creates a new mock instance.
+func NewMockIAuthRepository(ctrl *gomock.Controller) *MockIAuthRepository {
+	mock := &MockIAuthRepository{ctrl: ctrl}
+	mock.recorder = &MockIAuthRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIAuthRepository) EXPECT() *MockIAuthRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateUser mocks base method.
+func (m *MockIAuthRepository) CreateUser(ctx context.Context, user models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockIAuthRepositoryMockRecorder) CreateUser(ctx, user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockIAuthRepository)(nil).CreateUser), ctx, user)
+}
+
+// FindUser mocks base method.
+func (m *MockIAuthRepository) FindUser(ctx context.Context, email string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUser", ctx, email)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUser indicates an expected call of FindUser.
+func (mr *MockIAuthRepositoryMockRecorder) FindUser(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUser", reflect.TypeOf((*MockIAuthRepository)(nil).FindUser), ctx, email)
+}
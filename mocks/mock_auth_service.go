@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: auth_service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=auth_service.go -destination=../mocks/mock_auth_service.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	models "gin-freemarket/models"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIAuthService is a mock of IAuthService interface.
+type MockIAuthService struct {
+	ctrl     *gomock.Controller
+	recorder *MockIAuthServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockIAuthServiceMockRecorder is the mock recorder for MockIAuthService.
+type MockIAuthServiceMockRecorder struct {
+	mock *MockIAuthService
+}
+
+// NewMockIAuthService creates a new mock instance.
+func NewMockIAuthService(ctrl *gomock.Controller) *MockIAuthService {
+	mock := &MockIAuthService{ctrl: ctrl}
+	mock.recorder = &MockIAuthServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIAuthService) EXPECT() *MockIAuthServiceMockRecorder {
+	return m.recorder
+}
+
+// GetUserFromToken mocks base method.
+func (m *MockIAuthService) GetUserFromToken(ctx context.Context, tokenString string) (*models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserFromToken", ctx, tokenString)
+	ret0, _ := ret[0].(*models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserFromToken indicates an expected call of GetUserFromToken.
+func (mr *MockIAuthServiceMockRecorder) GetUserFromToken(ctx, tokenString any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserFromToken", reflect.TypeOf((*MockIAuthService)(nil).GetUserFromToken), ctx, tokenString)
+}
+
+// Login mocks base method.
+func (m *MockIAuthService) Login(ctx context.Context, email, password string) (*string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, email, password)
+	ret0, _ := ret[0].(*string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockIAuthServiceMockRecorder) Login(ctx, email, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockIAuthService)(nil).Login), ctx, email, password)
+}
+
+// Signup mocks base method.
+func (m *MockIAuthService) Signup(ctx context.Context, email, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Signup", ctx, email, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Signup indicates an expected call of Signup.
+func (mr *MockIAuthServiceMockRecorder) Signup(ctx, email, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Signup", reflect.TypeOf((*MockIAuthService)(nil).Signup), ctx, email, password)
+}
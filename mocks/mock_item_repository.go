@@ -0,0 +1,116 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: item_repository.go
+//
+// Generated by this command:
+//
+//	mockgen -source=item_repository.go -destination=../mocks/mock_item_repository.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	models "gin-freemarket/models"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIItemRepository is a mock of IItemRepository interface.
+type MockIItemRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIItemRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockIItemRepositoryMockRecorder is the mock recorder for MockIItemRepository.
+type MockIItemRepositoryMockRecorder struct {
+	mock *MockIItemRepository
+}
+
+// NewMockIItemRepository creates a new mock instance.
+func NewMockIItemRepository(ctrl *gomock.Controller) *MockIItemRepository {
+	mock := &MockIItemRepository{ctrl: ctrl}
+	mock.recorder = &MockIItemRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIItemRepository) EXPECT() *MockIItemRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockIItemRepository) Create(ctx context.Context, newItem models.Item) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, newItem)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockIItemRepositoryMockRecorder) Create(ctx, newItem any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockIItemRepository)(nil).Create), ctx, newItem)
+}
+
+// Delete mocks base method.
+func (m *MockIItemRepository) Delete(ctx context.Context, itemId uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, itemId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockIItemRepositoryMockRecorder) Delete(ctx, itemId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockIItemRepository)(nil).Delete), ctx, itemId)
+}
+
+// FindAll mocks base method.
+func (m *MockIItemRepository) FindAll(ctx context.Context) (*[]models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", ctx)
+	ret0, _ := ret[0].(*[]models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockIItemRepositoryMockRecorder) FindAll(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockIItemRepository)(nil).FindAll), ctx)
+}
+
+// FindById mocks base method.
+func (m *MockIItemRepository) FindById(ctx context.Context, itemId uint) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, itemId)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockIItemRepositoryMockRecorder) FindById(ctx, itemId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockIItemRepository)(nil).FindById), ctx, itemId)
+}
+
+// Update mocks base method.
+func (m *MockIItemRepository) Update(ctx context.Context, newItem models.Item, fields []string) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, newItem, fields)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockIItemRepositoryMockRecorder) Update(ctx, newItem, fields any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockIItemRepository)(nil).Update), ctx, newItem, fields)
+}
@@ -0,0 +1,162 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: item_service.go
+//
+// Generated by this command:
+//
+//	mockgen -source=item_service.go -destination=../mocks/mock_item_service.go -package=mocks
+//
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	dto "gin-freemarket/dto"
+	models "gin-freemarket/models"
+	services "gin-freemarket/services"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIItemService is a mock of IItemService interface.
+type MockIItemService struct {
+	ctrl     *gomock.Controller
+	recorder *MockIItemServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockIItemServiceMockRecorder is the mock recorder for MockIItemService.
+type MockIItemServiceMockRecorder struct {
+	mock *MockIItemService
+}
+
+// NewMockIItemService creates a new mock instance.
+func NewMockIItemService(ctrl *gomock.Controller) *MockIItemService {
+	mock := &MockIItemService{ctrl: ctrl}
+	mock.recorder = &MockIItemServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIItemService) EXPECT() *MockIItemServiceMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockIItemService) Create(ctx context.Context, createItemInput dto.CreateItemInput, userId uint) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, createItemInput, userId)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockIItemServiceMockRecorder) Create(ctx, createItemInput, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockIItemService)(nil).Create), ctx, createItemInput, userId)
+}
+
+// Delete mocks base method.
+func (m *MockIItemService) Delete(ctx context.Context, itemId, userId uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, itemId, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockIItemServiceMockRecorder) Delete(ctx, itemId, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockIItemService)(nil).Delete), ctx, itemId, userId)
+}
+
+// FindAll mocks base method.
+func (m *MockIItemService) FindAll(ctx context.Context) (*[]models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAll", ctx)
+	ret0, _ := ret[0].(*[]models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAll indicates an expected call of FindAll.
+func (mr *MockIItemServiceMockRecorder) FindAll(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAll", reflect.TypeOf((*MockIItemService)(nil).FindAll), ctx)
+}
+
+// FindById mocks base method.
+func (m *MockIItemService) FindById(ctx context.Context, itemId uint) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindById", ctx, itemId)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindById indicates an expected call of FindById.
+func (mr *MockIItemServiceMockRecorder) FindById(ctx, itemId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockIItemService)(nil).FindById), ctx, itemId)
+}
+
+// Hold mocks base method.
+func (m *MockIItemService) Hold(ctx context.Context, itemId, userId uint) (*models.Reservation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Hold", ctx, itemId, userId)
+	ret0, _ := ret[0].(*models.Reservation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Hold indicates an expected call of Hold.
+func (mr *MockIItemServiceMockRecorder) Hold(ctx, itemId, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hold", reflect.TypeOf((*MockIItemService)(nil).Hold), ctx, itemId, userId)
+}
+
+// Patch mocks base method.
+func (m *MockIItemService) Patch(ctx context.Context, itemId, userId, version uint, apply services.ItemPatchFunc) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", ctx, itemId, userId, version, apply)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockIItemServiceMockRecorder) Patch(ctx, itemId, userId, version, apply any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockIItemService)(nil).Patch), ctx, itemId, userId, version, apply)
+}
+
+// Release mocks base method.
+func (m *MockIItemService) Release(ctx context.Context, itemId, userId uint) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, itemId, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockIItemServiceMockRecorder) Release(ctx, itemId, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockIItemService)(nil).Release), ctx, itemId, userId)
+}
+
+// Update mocks base method.
+func (m *MockIItemService) Update(ctx context.Context, itemId, userId, version uint, updateItemInput dto.UpdateItemInput) (*models.Item, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, itemId, userId, version, updateItemInput)
+	ret0, _ := ret[0].(*models.Item)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockIItemServiceMockRecorder) Update(ctx, itemId, userId, version, updateItemInput any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockIItemService)(nil).Update), ctx, itemId, userId, version, updateItemInput)
+}
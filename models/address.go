@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// 送り先の住所。Addressと注文時のスナップショット（Order.ShipTo）で共通して使う
+type ShippingAddress struct {
+	Name       string `gorm:"not null"` // 宛名
+	PostalCode string `gorm:"not null"`
+	Prefecture string `gorm:"not null"`
+	City       string `gorm:"not null"`
+	Line1      string `gorm:"not null"`
+	Line2      string
+	Phone      string `gorm:"not null"`
+}
+
+type Address struct {
+	gorm.Model
+	UserId          uint `gorm:"not null;index"`
+	ShippingAddress `gorm:"embedded"`
+	IsDefault       bool `gorm:"not null;default:false"` // ユーザーごとに1件だけtrueになる
+}
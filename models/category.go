@@ -0,0 +1,9 @@
+package models
+
+import "gorm.io/gorm"
+
+// 商品のカテゴリ。どのカテゴリにも入っていない商品はCategoryIdが0（未分類）になる
+type Category struct {
+	gorm.Model
+	Name string `gorm:"not null;uniqueIndex"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Idempotency-Keyヘッダを付けて送られたリクエストの記録
+// 同じユーザーが同じキーで再送してきたら、処理をやり直さずに保存しておいたレスポンスを返す。
+// 期限切れの記録は物理削除する（論理削除だとユーザーとキーの一意制約に引っかかり、同じキーを使い直せないため）
+type IdempotencyKey struct {
+	gorm.Model
+	UserId uint   `gorm:"not null;uniqueIndex:idx_idempotency_key"`
+	Key    string `gorm:"not null;uniqueIndex:idx_idempotency_key"`
+	// リクエストのメソッド・パス・ボディのハッシュ。同じキーで違うリクエストが来たら拒否する
+	Fingerprint string `gorm:"not null"`
+	// 処理中は0で、処理が終わるとレスポンスが入る
+	StatusCode     int
+	ResponseHeader string // 再送時に返すヘッダー（JSON）
+	ResponseBody   []byte
+	ExpiresAt      time.Time `gorm:"not null;index"`
+	// 処理中のリクエストがキーを持っていられる期限。
+	// 処理の途中でプロセスが落ちるなどして残ったキーは、これを過ぎると同じ内容の再送が引き継げる
+	LockedUntil time.Time `gorm:"not null"`
+}
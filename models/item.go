@@ -2,6 +2,12 @@ package models
 
 import "gorm.io/gorm"
 
+// 送料の負担者
+const (
+	ShippingPayerSeller = "seller" // 送料込み
+	ShippingPayerBuyer  = "buyer"  // 購入者負担（購入時に送料を上乗せ）
+)
+
 type Item struct {
 	// gorm.Modelにカーソルを当てると、内部で管理しているパラメータがみえる。（IDとかCreatedAtとか）
 	// このように構造体in構造体でモデルを定義できる
@@ -12,4 +18,13 @@ type Item struct {
 	Description string
 	SoldOut     bool `gorm:"not null;default:false"` //複数定義するときはセミコロンで区切る。ただし、スペースとかいれてはいけない
 	UserId      uint `gorm:"not null"`
+	CategoryId  uint `gorm:"not null;default:0;index"` // 0なら未分類
+
+	ShippingMethod  string // 配送方法（"yu-packet"など自由入力）
+	ShippingPayer   string `gorm:"not null;default:seller"`
+	ShippingFee     uint   // 購入者負担のときに上乗せする送料
+	ShipsWithinDays uint   `gorm:"not null;default:3"` // 購入から何日以内に発送するか
+
+	// 更新のたびに1つ上がる。ETagに使い、古い内容をもとにした更新で他の人の変更を上書きしないようにする
+	Version uint `gorm:"not null;default:1"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import "gorm.io/gorm"
+
+// 複式簿記の貸借
+const (
+	LedgerDebit  = "debit"
+	LedgerCredit = "credit"
+)
+
+// 勘定。"seller:1:available" のように名前で識別する
+type LedgerAccount struct {
+	gorm.Model
+	Name   string `gorm:"not null;unique"`
+	UserId *uint  `gorm:"index"` // 出品者の勘定ならそのユーザー、プラットフォームの勘定ならnil
+}
+
+// 1回の取引（仕訳）。Entriesの借方合計と貸方合計は必ず一致させる
+type LedgerTransaction struct {
+	gorm.Model
+	Reference   string `gorm:"not null;index"` // "order:1" のような発生元
+	Description string
+	Entries     []LedgerEntry `gorm:"foreignKey:TransactionId"`
+}
+
+type LedgerEntry struct {
+	gorm.Model
+	TransactionId uint          `gorm:"not null;index"`
+	AccountId     uint          `gorm:"not null;index"`
+	Account       LedgerAccount `gorm:"foreignKey:AccountId"`
+	Direction     string        `gorm:"not null"`
+	Amount        uint          `gorm:"not null"` // 円単位（Item.Priceと同じ）
+}
+
+// 出金申請
+const (
+	PayoutStatusRequested = "requested"
+)
+
+type Payout struct {
+	gorm.Model
+	UserId        uint   `gorm:"not null;index"`
+	Amount        uint   `gorm:"not null"`
+	Status        string `gorm:"not null;default:requested"`
+	TransactionId uint   `gorm:"not null"` // 出金申請時に計上した仕訳
+}
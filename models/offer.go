@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// オファー（値下げ交渉）のステータス
+const (
+	OfferStatusPending   = "pending"   // 購入希望者が提示し、出品者の回答待ち
+	OfferStatusCountered = "countered" // 出品者が別の金額を提示し、購入希望者の回答待ち
+	OfferStatusAccepted  = "accepted"  // 合意済み。この購入希望者に対して商品が確保される
+	OfferStatusRejected  = "rejected"
+	OfferStatusExpired   = "expired"
+	OfferStatusPurchased = "purchased" // 合意した金額で注文された
+)
+
+type Offer struct {
+	gorm.Model
+	ItemId       uint      `gorm:"not null;index"`
+	BuyerId      uint      `gorm:"not null;index"`
+	Price        uint      `gorm:"not null"` // 購入希望者の提示額
+	CounterPrice *uint     // 出品者からの逆提示額（提示がなければnil）
+	AgreedPrice  *uint     // 合意した金額（accepted以外はnil）
+	Status       string    `gorm:"not null;default:pending"`
+	ExpiresAt    time.Time `gorm:"not null"`
+}
+
+// 回答待ち（pending or countered）かどうか
+func (o *Offer) IsOpen() bool {
+	return o.Status == OfferStatusPending || o.Status == OfferStatusCountered
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 注文のステータス
+const (
+	OrderStatusPending          = "pending"           // 支払い待ち
+	OrderStatusCaptureRequested = "capture_requested" // オーソリ済み。決済プロバイダに確定を依頼し、確定の連絡待ち
+	OrderStatusPaid             = "paid"              // 決済確定済み
+	OrderStatusRefundRequested  = "refund_requested"  // 決済プロバイダに返金を依頼し、返金の連絡待ち
+	OrderStatusShipped          = "shipped"           // 出品者が発送済み
+	OrderStatusDelivered        = "delivered"         // 運送会社から配達完了の連絡があった
+	OrderStatusCompleted        = "completed"         // 購入者が受け取りを確認し（または配達から一定期間が経過し）、取引完了
+	OrderStatusCanceled         = "canceled"          // 支払い前にキャンセル、または決済失敗
+	OrderStatusRefunded         = "refunded"          // 決済確定後に返金
+)
+
+// 商品を確保している注文のステータス。この注文がある間は、他のユーザーは商品をホールド・購入できない
+var OpenOrderStatuses = []string{OrderStatusPending, OrderStatusCaptureRequested, OrderStatusPaid, OrderStatusRefundRequested}
+
+type Order struct {
+	gorm.Model
+	ItemId          uint   `gorm:"not null;index"`
+	BuyerId         uint   `gorm:"not null;index"`
+	SellerId        uint   `gorm:"not null;index"`
+	Price           uint   `gorm:"not null"` // 購入時点の金額（オファーで合意していればその金額）
+	ShippingFee     uint   // 購入者負担の送料（出品者負担なら0）
+	Fee             uint   // 取引完了時に差し引いた販売手数料
+	Status          string `gorm:"not null;default:pending"`
+	PaymentIntentId string `gorm:"index"` // 決済プロバイダ側の支払いID
+
+	// 購入時点の送り先。後から住所が編集・削除されても変わらないようにコピーして持つ
+	ShipTo ShippingAddress `gorm:"embedded;embeddedPrefix:ship_to_"`
+
+	Shipment    *Shipment  `gorm:"foreignKey:OrderId"`
+	DeliveredAt *time.Time `gorm:"index"` // 配達完了日時。ここから一定期間で自動的に取引完了になる
+}
+
+// 購入者の支払総額（商品代金 + 購入者負担の送料）
+func (o *Order) Total() uint {
+	return o.Price + o.ShippingFee
+}
+
+// 商品を確保している注文か
+func (o *Order) IsOpen() bool {
+	return slices.Contains(OpenOrderStatuses, o.Status)
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 購入手続き中の商品を一定時間確保（ホールド）しておくためのレコード
+// 解放・期限切れ時は論理削除されるので、削除されていないものが有効なホールドとなる
+type Reservation struct {
+	gorm.Model
+	ItemId    uint      `gorm:"not null;index"`
+	UserId    uint      `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// 取引相手の評価。取引が完了した注文について、購入者と出品者がそれぞれ1件ずつ相手を評価できる
+type Review struct {
+	gorm.Model
+	OrderId    uint `gorm:"not null;uniqueIndex:idx_review"`
+	ReviewerId uint `gorm:"not null;uniqueIndex:idx_review"` // 評価した人
+	RevieweeId uint `gorm:"not null;index"`                  // 評価された人（取引相手）
+	Rating     uint `gorm:"not null"`                        // 1〜5
+	Comment    string
+}
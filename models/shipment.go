@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 配送状況。運送会社ごとの表現はcarriersパッケージでこの値に揃える
+const (
+	ShipmentStatusShipped        = "shipped" // 出品者が発送を登録した
+	ShipmentStatusInTransit      = "in_transit"
+	ShipmentStatusOutForDelivery = "out_for_delivery"
+	ShipmentStatusDelivered      = "delivered"
+	ShipmentStatusException      = "exception" // 持ち戻りなど
+)
+
+type Shipment struct {
+	gorm.Model
+	OrderId        uint            `gorm:"not null;uniqueIndex"`
+	Carrier        string          `gorm:"not null;uniqueIndex:idx_shipment_tracking"`
+	TrackingNumber string          `gorm:"not null;uniqueIndex:idx_shipment_tracking"`
+	Status         string          `gorm:"not null"`
+	Events         []ShipmentEvent `gorm:"foreignKey:ShipmentId"` // 配送状況の履歴
+}
+
+type ShipmentEvent struct {
+	gorm.Model
+	ShipmentId  uint   `gorm:"not null;uniqueIndex:idx_shipment_event"`
+	ExternalId  string `gorm:"not null;uniqueIndex:idx_shipment_event"` // 運送会社側のイベントID。重複受信の判定に使う
+	Status      string `gorm:"not null"`
+	Description string
+	OccurredAt  time.Time `gorm:"not null"`
+}
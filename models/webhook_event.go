@@ -0,0 +1,12 @@
+package models
+
+import "gorm.io/gorm"
+
+// 受信済みのWebhookイベント
+// プロバイダは同じイベントを複数回送ってくることがあるので、EventIdの一意制約で二重処理を防ぐ
+type WebhookEvent struct {
+	gorm.Model
+	Provider string `gorm:"not null;uniqueIndex:idx_webhook_event"`
+	EventId  string `gorm:"not null;uniqueIndex:idx_webhook_event"`
+	Type     string `gorm:"not null"`
+}
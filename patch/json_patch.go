@@ -0,0 +1,387 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSON Patchの1つの操作
+type operation struct {
+	Op   string  `json:"op"`
+	Path *string `json:"path"`
+	From *string `json:"from"`
+	// キーがない場合はnil、nullの場合は"null"になるので区別できる
+	Value json.RawMessage `json:"value"`
+}
+
+// 適用できなかった理由。kindはErrMalformedかErrConflict
+type opError struct {
+	kind error
+	msg  string
+}
+
+func (e *opError) Error() string {
+	return e.msg
+}
+
+func malformed(format string, args ...any) error {
+	return &opError{kind: ErrMalformed, msg: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...any) error {
+	return &opError{kind: ErrConflict, msg: fmt.Sprintf(format, args...)}
+}
+
+// JSON Patch（RFC 6902）をdocに適用する
+// 操作は先頭から順に適用し、1つでも失敗すれば全体をエラーにする（途中までの結果は返さない）
+func Apply(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	for i, op := range ops {
+		target, err = op.apply(target)
+		if err != nil {
+			var oe *opError
+			if errors.As(err, &oe) {
+				path := ""
+				if op.Path != nil {
+					path = " " + *op.Path
+				}
+				return nil, fmt.Errorf("%w: operation %d (%s%s): %s", oe.kind, i, op.Op, path, oe.msg)
+			}
+			return nil, err
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op operation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, malformed("path is required")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case "remove":
+		doc, _, err := remove(doc, path)
+		return doc, err
+
+	case "replace":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		// 置き換える値が存在しなければエラー（addと違い、新しく作らない）
+		doc, _, err = remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case "move":
+		from, err := op.from()
+		if err != nil {
+			return nil, err
+		}
+		if len(from) < len(path) && hasPrefix(path, from) {
+			return nil, malformed("a value cannot be moved into one of its children")
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+
+	case "copy":
+		from, err := op.from()
+		if err != nil {
+			return nil, err
+		}
+		value, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, deepCopy(value))
+
+	case "test":
+		value, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, value) {
+			return nil, conflict("value does not match")
+		}
+		return doc, nil
+	}
+	return nil, malformed("unknown op %q", op.Op)
+}
+
+func (op operation) value() (any, error) {
+	if op.Value == nil {
+		return nil, malformed("value is required")
+	}
+	return decode(op.Value)
+}
+
+func (op operation) from() ([]string, error) {
+	if op.From == nil {
+		return nil, malformed("from is required")
+	}
+	return parsePointer(*op.From)
+}
+
+// JSON Pointer（RFC 6901）をキーの列に分ける。""はドキュメント全体
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, malformed("path %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		// ~の後ろは0（~そのもの）か1（/）だけ
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, malformed("path %q has an invalid escape", pointer)
+			}
+		}
+		// ~01は"~1"になるよう、~1を先に戻す
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func hasPrefix(path []string, prefix []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var arrayIndexPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)$`)
+
+// 配列の添字。0以上size未満でなければエラー
+func arrayIndex(token string, size int) (int, error) {
+	if !arrayIndexPattern.MatchString(token) {
+		return 0, conflict("%q is not an array index", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i >= size {
+		return 0, conflict("index %s is out of range", token)
+	}
+	return i, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	node := doc
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			value, ok := n[token]
+			if !ok {
+				return nil, conflict("path does not exist")
+			}
+			node = value
+		case []any:
+			i, err := arrayIndex(token, len(n))
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, conflict("path does not exist")
+		}
+	}
+	return node, nil
+}
+
+// pathにvalueを追加し、追加した後のnodeを返す
+// オブジェクトなら追加または上書き、配列ならその位置に挿入する（"-"は末尾）
+func add(node any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, rest := path[0], path[1:]
+
+	switch n := node.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+		child, ok := n[token]
+		if !ok {
+			return nil, conflict("path does not exist")
+		}
+		child, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = child
+		return n, nil
+
+	case []any:
+		if len(rest) == 0 {
+			i := len(n)
+			if token != "-" {
+				var err error
+				// 末尾の次（len）にも挿入できる
+				if i, err = arrayIndex(token, len(n)+1); err != nil {
+					return nil, err
+				}
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+			return n, nil
+		}
+		i, err := arrayIndex(token, len(n))
+		if err != nil {
+			return nil, err
+		}
+		child, err := add(n[i], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = child
+		return n, nil
+	}
+	return nil, conflict("path does not exist")
+}
+
+// pathの値を取り除き、取り除いた後のnodeと取り除いた値を返す
+func remove(node any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, conflict("the whole document cannot be removed")
+	}
+	token, rest := path[0], path[1:]
+
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[token]
+		if !ok {
+			return nil, nil, conflict("path does not exist")
+		}
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, child, nil
+		}
+		child, removed, err := remove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[token] = child
+		return n, removed, nil
+
+	case []any:
+		i, err := arrayIndex(token, len(n))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(n[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = child
+		return n, removed, nil
+	}
+	return nil, nil, conflict("path does not exist")
+}
+
+// testの比較。数値は表記が違っても（1と1.0）値が同じなら等しい
+func equal(a any, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		xf, _, errX := big.ParseFloat(string(x), 10, 256, big.ToNearestEven)
+		yf, _, errY := big.ParseFloat(string(y), 10, 256, big.ToNearestEven)
+		if errX != nil || errY != nil {
+			return x == y
+		}
+		return xf.Cmp(yf) == 0
+	}
+	return a == b
+}
+
+// copyで同じ値を2か所から参照しないよう、オブジェクトと配列は複製する
+func deepCopy(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(x))
+		for key, value := range x {
+			copied[key] = deepCopy(value)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(x))
+		for i, value := range x {
+			copied[i] = deepCopy(value)
+		}
+		return copied
+	}
+	return v
+}
@@ -0,0 +1,40 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSON Merge Patch（RFC 7396）をdocに適用する
+// patchのオブジェクトのキーで上書きし、値がnullのキーは削除する。オブジェクト以外のpatchはdoc全体を置き換える
+func Merge(doc []byte, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return json.Marshal(mergeValue(target, p))
+}
+
+// RFC 7396の2章の疑似コードそのまま
+func mergeValue(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergeValue(t[key], value)
+	}
+	return t
+}
@@ -0,0 +1,48 @@
+// PATCHリクエストの差分をJSONのドキュメントに適用する
+// JSON Merge Patch（RFC 7396）とJSON Patch（RFC 6902）に対応している
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+// PATCHで受け付けるContent-Type
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	// 差分の形式が正しくない（JSONでない、opが不明、pathの書き方が違うなど）
+	ErrMalformed = errors.New("Invalid patch document")
+	// 差分は正しいが今のドキュメントには適用できない（pathが存在しない、testが一致しないなど）
+	ErrConflict = errors.New("Patch cannot be applied")
+)
+
+// Content-Typeに対応する適用関数を返す。対応していなければfalse
+func ForContentType(contentType string) (func(doc []byte, patch []byte) ([]byte, error), bool) {
+	switch contentType {
+	case MergePatchContentType:
+		return Merge, true
+	case JSONPatchContentType:
+		return Apply, true
+	}
+	return nil, false
+}
+
+// 数値はjson.Numberのまま扱い、float64への変換で桁が落ちないようにする
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	// 1つのJSONの後ろに余計なものが続いていないか
+	if dec.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return v, nil
+}
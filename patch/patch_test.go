@@ -0,0 +1,140 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// 結果はキーの順序に依存しないよう、デコードしてから比較する
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	g, err := decode(got)
+	if err != nil {
+		t.Fatalf("result is not valid JSON: %v", err)
+	}
+	w, err := decode([]byte(want))
+	if err != nil {
+		t.Fatalf("want is not valid JSON: %v", err)
+	}
+	if !equal(g, w) {
+		t.Fatalf("result = %s, want %s", got, want)
+	}
+}
+
+// RFC 7396 付録Aの例
+func TestMerge(t *testing.T) {
+	cases := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		// 大きな整数も桁が落ちない
+		{`{"id":9007199254740993}`, `{"a":1}`, `{"id":9007199254740993,"a":1}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.doc+" + "+tc.patch, func(t *testing.T) {
+			got, err := Merge([]byte(tc.doc), []byte(tc.patch))
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+			assertJSON(t, got, tc.want)
+		})
+	}
+
+	t.Run("malformed patch", func(t *testing.T) {
+		if _, err := Merge([]byte(`{}`), []byte(`{"a":`)); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("err = %v, want ErrMalformed", err)
+		}
+	})
+}
+
+// 主にRFC 6902 付録Aの例
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		// 空でなければこのエラーになる
+		wantErr error
+	}{
+		{"add an object member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"add an array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"add to the end of an array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`, nil},
+		{"add a null value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"foo":"bar","baz":null}`, nil},
+		{"remove an object member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"remove an array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"replace a value", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{
+			"move a value", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil,
+		},
+		{"move an array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, nil},
+		{"copy a value", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"},{"op":"replace","path":"/baz/bar","value":2}]`, `{"foo":{"bar":1},"baz":{"bar":2}}`, nil},
+		{"test succeeds", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"test compares numbers by value", `{"price":1000}`, `[{"op":"test","path":"/price","value":1e3}]`, `{"price":1000}`, nil},
+		{"escaped keys", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":1}]`, `{"/":1,"~1":10}`, nil},
+		{"replace the whole document", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":{"baz":"qux"}}]`, `{"baz":"qux"}`, nil},
+		{"empty patch", `{"foo":"bar"}`, `[]`, `{"foo":"bar"}`, nil},
+
+		{"test fails", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", ErrConflict},
+		{"add to a nonexistent target", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", ErrConflict},
+		{"remove a nonexistent member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, "", ErrConflict},
+		{"replace a nonexistent member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, "", ErrConflict},
+		{"array index out of range", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, "", ErrConflict},
+		{"array index with a leading zero", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", ErrConflict},
+		{"later failure discards earlier operations", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":1},{"op":"test","path":"/foo","value":"x"}]`, "", ErrConflict},
+
+		{"unknown op", `{}`, `[{"op":"merge","path":"/a","value":1}]`, "", ErrMalformed},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`, "", ErrMalformed},
+		{"missing path", `{}`, `[{"op":"add","value":1}]`, "", ErrMalformed},
+		{"missing from", `{"a":1}`, `[{"op":"move","path":"/b"}]`, "", ErrMalformed},
+		{"path without a leading slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, "", ErrMalformed},
+		{"invalid escape", `{"a":1}`, `[{"op":"remove","path":"/a~2"}]`, "", ErrMalformed},
+		{"move into a child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b"}]`, "", ErrMalformed},
+		{"not an array", `{}`, `{"op":"add","path":"/a","value":1}`, "", ErrMalformed},
+		{"not JSON", `{}`, `[{"op":`, "", ErrMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			assertJSON(t, got, tc.want)
+		})
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	for _, contentType := range []string{MergePatchContentType, JSONPatchContentType} {
+		if _, ok := ForContentType(contentType); !ok {
+			t.Errorf("ForContentType(%q) is not supported", contentType)
+		}
+	}
+	if _, ok := ForContentType("application/json"); ok {
+		t.Error("ForContentType(application/json) is supported, want unsupported")
+	}
+}
@@ -0,0 +1,86 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ローカル開発・テスト用の決済プロバイダ
+// 外部への通信は行わず、メモリ上で支払いの状態を管理する。
+// IDは注文IDから決まるので、同じ注文に対しては常に同じ支払いIDになる
+type FakePaymentGateway struct {
+	mu      sync.Mutex
+	intents map[string]*PaymentIntent
+}
+
+func NewFakePaymentGateway() PaymentGateway {
+	return &FakePaymentGateway{intents: map[string]*PaymentIntent{}}
+}
+
+func (g *FakePaymentGateway) CreateIntent(ctx context.Context, orderId uint, amount uint) (*PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent := &PaymentIntent{
+		ID:     fmt.Sprintf("pi_fake_%d", orderId),
+		Amount: amount,
+		Status: IntentStatusRequiresPayment,
+	}
+	g.intents[intent.ID] = intent
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *FakePaymentGateway) Capture(ctx context.Context, intentId string) (*PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentId]
+	if !ok {
+		// サーバー再起動でメモリが消えていても、Webhookの再送を処理できるようにしておく
+		intent = &PaymentIntent{ID: intentId}
+		g.intents[intentId] = intent
+	}
+	if intent.Status == IntentStatusRefunded || intent.Status == IntentStatusCanceled {
+		return nil, errors.New("Payment is already " + intent.Status)
+	}
+	intent.Status = IntentStatusSucceeded
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *FakePaymentGateway) Refund(ctx context.Context, intentId string) (*PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentId]
+	if !ok {
+		intent = &PaymentIntent{ID: intentId, Status: IntentStatusSucceeded}
+		g.intents[intentId] = intent
+	}
+	if intent.Status != IntentStatusSucceeded && intent.Status != IntentStatusRefunded {
+		return nil, errors.New("Payment is not captured")
+	}
+	intent.Status = IntentStatusRefunded
+	copied := *intent
+	return &copied, nil
+}
+
+func (g *FakePaymentGateway) Void(ctx context.Context, intentId string) (*PaymentIntent, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	intent, ok := g.intents[intentId]
+	if !ok {
+		intent = &PaymentIntent{ID: intentId, Status: IntentStatusAuthorized}
+		g.intents[intentId] = intent
+	}
+	if intent.Status == IntentStatusSucceeded || intent.Status == IntentStatusRefunded {
+		return nil, errors.New("Payment is already captured")
+	}
+	intent.Status = IntentStatusCanceled
+	copied := *intent
+	return &copied, nil
+}
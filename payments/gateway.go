@@ -0,0 +1,33 @@
+package payments
+
+import "context"
+
+// 決済プロバイダとのやり取りを抽象化したインタフェース
+// 本番のプロバイダに切り替えるときは、このインタフェースを実装した構造体をmain.goで差し替えるだけでよい。
+// 外部への通信になるので、どのメソッドもctxでタイムアウト・キャンセルできるようにしている。
+// 応答が遅いと他のリクエストを止めてしまうので、DBのロックを持ったまま呼ばないこと（Capture・Refundの結果はWebhookで届く）
+type PaymentGateway interface {
+	// 支払いを作成する。金額は円単位（Item.Priceと同じ）
+	CreateIntent(ctx context.Context, orderId uint, amount uint) (*PaymentIntent, error)
+	// オーソリ済みの支払いを確定する
+	Capture(ctx context.Context, intentId string) (*PaymentIntent, error)
+	// 確定済みの支払いを返金する
+	Refund(ctx context.Context, intentId string) (*PaymentIntent, error)
+	// 確定していない支払いを取り消し、購入者の与信枠を解放する
+	Void(ctx context.Context, intentId string) (*PaymentIntent, error)
+}
+
+// 支払いのステータス
+const (
+	IntentStatusRequiresPayment = "requires_payment"
+	IntentStatusAuthorized      = "authorized"
+	IntentStatusSucceeded       = "succeeded"
+	IntentStatusRefunded        = "refunded"
+	IntentStatusCanceled        = "canceled"
+)
+
+type PaymentIntent struct {
+	ID     string `json:"id"`
+	Amount uint   `json:"amount"`
+	Status string `json:"status"`
+}
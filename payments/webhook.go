@@ -0,0 +1,44 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Webhookで受け取るイベントの種類
+const (
+	EventPaymentAuthorized = "payment_intent.authorized" // 購入者が支払い、オーソリが取れた
+	EventPaymentSucceeded  = "payment_intent.succeeded"  // Captureの依頼を受けて、決済が確定した
+	EventPaymentFailed     = "payment_intent.failed"
+	EventPaymentRefunded   = "payment_intent.refunded" // Refundの依頼を受けて、返金された
+)
+
+// Webhookのリクエストボディ
+type WebhookEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		PaymentIntentId string `json:"payment_intent_id"`
+	} `json:"data"`
+}
+
+// 署名ヘッダ（"sha256=<hex>"）の形式で、bodyのHMAC-SHA256を計算する
+// 偽の決済プロバイダからWebhookを送るときやテストでも使う
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 署名ヘッダがbodyと共有シークレットから計算した値と一致するかを検証する
+// シークレットが未設定の場合は常に失敗させる
+func VerifyWebhookSignature(secret string, body []byte, signature string) bool {
+	if secret == "" || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected := SignWebhook(secret, body)
+	// 比較にかかる時間から署名を推測されないよう、hmac.Equalで比較する
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
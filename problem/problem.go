@@ -0,0 +1,69 @@
+package problem
+
+import (
+	"gin-freemarket/validation"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RFC 7807で定められたエラーレスポンスのContent-Type
+const ContentType = "application/problem+json"
+
+// エラーレスポンスの共通の形（RFC 7807のProblem Details）
+// どのエンドポイントでも、バリデーションエラー・見つからない・競合・サーバーエラーはこの形で返す
+type Details struct {
+	// 問題の種類を表すURI。独自の種類を定義していないものは"about:blank"
+	Type string `json:"type"`
+	// ステータスコードに対応する短い説明（例: "Not Found"）
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// 今回のリクエストに固有の説明
+	Detail string `json:"detail,omitempty"`
+	// 問題が起きたリクエストのパス
+	Instance string `json:"instance,omitempty"`
+	// 問い合わせのときにログと突き合わせられるよう、リクエストIDも返す
+	RequestID string `json:"request_id,omitempty"`
+	// 入力値の検証エラーのときだけ、項目ごとのエラーを入れる
+	Errors []validation.FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, detail string) Details {
+	return Details{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// エラーレスポンスを返し、後続のハンドラを止める
+func Respond(ctx *gin.Context, status int, detail string) {
+	Write(ctx, New(status, detail))
+}
+
+// 想定外のエラー。原因はログにだけ残し（ctx.Error）、レスポンスには出さない
+func Internal(ctx *gin.Context, err error) {
+	ctx.Error(err)
+	Respond(ctx, http.StatusInternalServerError, "Unexpected error")
+}
+
+// ShouldBindJSONのエラー。項目ごとのエラーをAccept-Languageの言語で返す
+func Validation(ctx *gin.Context, err error) {
+	detail, fields := validation.Describe(err, validation.Translator(ctx.GetHeader("Accept-Language")))
+	details := New(http.StatusBadRequest, detail)
+	details.Errors = fields
+	Write(ctx, details)
+}
+
+func Write(ctx *gin.Context, details Details) {
+	if details.Instance == "" {
+		details.Instance = ctx.Request.URL.Path
+	}
+	if details.RequestID == "" {
+		details.RequestID = ctx.GetString("request_id")
+	}
+	// ctx.JSONはContent-Typeが設定済みならそれを使うので、先に設定しておく
+	ctx.Header("Content-Type", ContentType)
+	ctx.AbortWithStatusJSON(details.Status, details)
+}
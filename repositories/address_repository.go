@@ -0,0 +1,247 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+type IAddressRepository interface {
+	FindAll(ctx context.Context, userId uint) (*[]models.Address, error)
+	// 他のユーザーの住所は見つからない扱いにする
+	FindById(ctx context.Context, addressId uint, userId uint) (*models.Address, error)
+	FindDefault(ctx context.Context, userId uint) (*models.Address, error)
+
+	// IsDefaultがtrueなら同じユーザーの他の住所のデフォルトを外す。
+	// 最初の1件は指定がなくてもデフォルトになる
+	Create(ctx context.Context, newAddress models.Address) (*models.Address, error)
+	Update(ctx context.Context, updateAddress models.Address) (*models.Address, error)
+	// デフォルトの住所を削除した場合は、残りのうち最新のものをデフォルトにする
+	Delete(ctx context.Context, addressId uint, userId uint) error
+}
+
+type AddressRepository struct {
+	db *gorm.DB
+}
+
+func NewAddressRepository(db *gorm.DB) IAddressRepository {
+	return &AddressRepository{db: db}
+}
+
+func (r *AddressRepository) FindAll(ctx context.Context, userId uint) (*[]models.Address, error) {
+	var addresses []models.Address
+	result := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("is_default DESC, id").Find(&addresses)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &addresses, nil
+}
+
+func (r *AddressRepository) FindById(ctx context.Context, addressId uint, userId uint) (*models.Address, error) {
+	var address models.Address
+	result := r.db.WithContext(ctx).First(&address, "id = ? AND user_id = ?", addressId, userId)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Address is not found")
+		}
+		return nil, result.Error
+	}
+	return &address, nil
+}
+
+func (r *AddressRepository) FindDefault(ctx context.Context, userId uint) (*models.Address, error) {
+	var address models.Address
+	result := r.db.WithContext(ctx).First(&address, "user_id = ? AND is_default = ?", userId, true)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Address is not found")
+		}
+		return nil, result.Error
+	}
+	return &address, nil
+}
+
+func (r *AddressRepository) Create(ctx context.Context, newAddress models.Address) (*models.Address, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Address{}).Where("user_id = ?", newAddress.UserId).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			newAddress.IsDefault = true
+		}
+		if newAddress.IsDefault {
+			if err := clearDefaultAddress(tx, newAddress.UserId); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&newAddress).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &newAddress, nil
+}
+
+func (r *AddressRepository) Update(ctx context.Context, updateAddress models.Address) (*models.Address, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if updateAddress.IsDefault {
+			if err := clearDefaultAddress(tx, updateAddress.UserId); err != nil {
+				return err
+			}
+		}
+		return tx.Save(&updateAddress).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updateAddress, nil
+}
+
+func (r *AddressRepository) Delete(ctx context.Context, addressId uint, userId uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var address models.Address
+		if err := tx.First(&address, "id = ? AND user_id = ?", addressId, userId).Error; err != nil {
+			if err.Error() == "record not found" {
+				return errors.New("Address is not found")
+			}
+			return err
+		}
+		if err := tx.Delete(&address).Error; err != nil {
+			return err
+		}
+		if !address.IsDefault {
+			return nil
+		}
+
+		var next []models.Address
+		if err := tx.Where("user_id = ?", userId).Order("id DESC").Limit(1).Find(&next).Error; err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		return tx.Model(&next[0]).Update("is_default", true).Error
+	})
+}
+
+func clearDefaultAddress(tx *gorm.DB, userId uint) error {
+	return tx.Model(&models.Address{}).Where("user_id = ? AND is_default = ?", userId, true).Update("is_default", false).Error
+}
+
+type AddressMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewAddressMemoryRepository(store *MemoryStore) IAddressRepository {
+	return &AddressMemoryRepository{store: store}
+}
+
+func (r *AddressMemoryRepository) FindAll(ctx context.Context, userId uint) (*[]models.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	addresses := r.store.addresses.find(func(a *models.Address) bool { return a.UserId == userId })
+	// DBと同じく、デフォルトの住所を先頭にして残りは登録順
+	sort.SliceStable(addresses, func(i, j int) bool { return addresses[i].IsDefault && !addresses[j].IsDefault })
+	return &addresses, nil
+}
+
+func (r *AddressMemoryRepository) FindById(ctx context.Context, addressId uint, userId uint) (*models.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	address, ok := r.store.addresses.first(func(a *models.Address) bool { return a.ID == addressId && a.UserId == userId })
+	if !ok {
+		return nil, errors.New("Address is not found")
+	}
+	return address, nil
+}
+
+func (r *AddressMemoryRepository) FindDefault(ctx context.Context, userId uint) (*models.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	address, ok := r.store.addresses.first(func(a *models.Address) bool { return a.UserId == userId && a.IsDefault })
+	if !ok {
+		return nil, errors.New("Address is not found")
+	}
+	return address, nil
+}
+
+func (r *AddressMemoryRepository) Create(ctx context.Context, newAddress models.Address) (*models.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if len(r.store.addresses.find(func(a *models.Address) bool { return a.UserId == newAddress.UserId })) == 0 {
+		newAddress.IsDefault = true
+	}
+	if newAddress.IsDefault {
+		r.store.clearDefaultAddress(newAddress.UserId)
+	}
+	r.store.addresses.create(&newAddress)
+	return &newAddress, nil
+}
+
+func (r *AddressMemoryRepository) Update(ctx context.Context, updateAddress models.Address) (*models.Address, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if updateAddress.IsDefault {
+		r.store.clearDefaultAddress(updateAddress.UserId)
+	}
+	r.store.addresses.save(&updateAddress)
+	return &updateAddress, nil
+}
+
+func (r *AddressMemoryRepository) Delete(ctx context.Context, addressId uint, userId uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	address, ok := r.store.addresses.first(func(a *models.Address) bool { return a.ID == addressId && a.UserId == userId })
+	if !ok {
+		return errors.New("Address is not found")
+	}
+	r.store.addresses.delete(func(a *models.Address) bool { return a.ID == address.ID })
+	if !address.IsDefault {
+		return nil
+	}
+
+	// 残りのうち最新のものをデフォルトにする
+	rest := r.store.addresses.find(func(a *models.Address) bool { return a.UserId == userId })
+	if len(rest) == 0 {
+		return nil
+	}
+	next := rest[len(rest)-1]
+	next.IsDefault = true
+	r.store.addresses.save(&next)
+	return nil
+}
+
+func (s *MemoryStore) clearDefaultAddress(userId uint) {
+	for _, v := range s.addresses.find(func(a *models.Address) bool { return a.UserId == userId && a.IsDefault }) {
+		v.IsDefault = false
+		s.addresses.save(&v)
+	}
+}
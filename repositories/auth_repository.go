@@ -1,15 +1,19 @@
 package repositories
 
 import (
+	"context"
 	"errors"
 	"gin-freemarket/models"
 
 	"gorm.io/gorm"
 )
 
+//go:generate go tool mockgen -source=auth_repository.go -destination=../mocks/mock_auth_repository.go -package=mocks
+
 type IAuthRepository interface {
-	CreateUser(user models.User) error
-	FindUser(email string) (*models.User, error)
+	// メールアドレスが登録済みなら"Email is already registered"
+	CreateUser(ctx context.Context, user models.User) error
+	FindUser(ctx context.Context, email string) (*models.User, error)
 }
 
 type AuthRepository struct {
@@ -22,20 +26,24 @@ func NewAuthRepository(db *gorm.DB) IAuthRepository {
 	return &AuthRepository{db: db}
 }
 
-func (r *AuthRepository) CreateUser(user models.User) error {
-	result := r.db.Create(&user)
+func (r *AuthRepository) CreateUser(ctx context.Context, user models.User) error {
+	result := r.db.WithContext(ctx).Create(&user)
 	if result.Error != nil {
+		// 一意制約の違反はgormがErrDuplicatedKeyに変換している（infra.SetupDBのTranslateError）
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return errors.New("Email is already registered")
+		}
 		return result.Error
 	}
 	return nil
 }
 
-func (r *AuthRepository) FindUser(email string) (*models.User, error) {
+func (r *AuthRepository) FindUser(ctx context.Context, email string) (*models.User, error) {
 
 	// 検索結果を格納する変数
 	var user models.User
 
-	result := r.db.First(&user, "email = ?", email)
+	result := r.db.WithContext(ctx).First(&user, "email = ?", email)
 	if result.Error != nil {
 		if result.Error.Error() == "record not found" {
 			return nil, errors.New("User not found")
@@ -44,3 +52,40 @@ func (r *AuthRepository) FindUser(email string) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+type AuthMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewAuthMemoryRepository(store *MemoryStore) IAuthRepository {
+	return &AuthMemoryRepository{store: store}
+}
+
+func (r *AuthMemoryRepository) CreateUser(ctx context.Context, user models.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// DBのusers.emailの一意制約の代わり。退会（論理削除）したユーザーのメールアドレスも使えない
+	if r.store.users.exists(func(u *models.User) bool { return u.Email == user.Email }) {
+		return errors.New("Email is already registered")
+	}
+	r.store.users.create(&user)
+	return nil
+}
+
+func (r *AuthMemoryRepository) FindUser(ctx context.Context, email string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	user, ok := r.store.users.first(func(u *models.User) bool { return u.Email == email })
+	if !ok {
+		return nil, errors.New("User not found")
+	}
+	return user, nil
+}
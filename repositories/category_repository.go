@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+
+	"gorm.io/gorm"
+)
+
+type ICategoryRepository interface {
+	FindAll(ctx context.Context) (*[]models.Category, error)
+	FindById(ctx context.Context, categoryId uint) (*models.Category, error)
+	// 同じ名前のカテゴリがあれば"Category already exists"
+	Create(ctx context.Context, newCategory models.Category) (*models.Category, error)
+}
+
+type CategoryRepository struct {
+	db *gorm.DB
+}
+
+func NewCategoryRepository(db *gorm.DB) ICategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func (r *CategoryRepository) FindAll(ctx context.Context) (*[]models.Category, error) {
+	var categories []models.Category
+	result := r.db.WithContext(ctx).Order("id").Find(&categories)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &categories, nil
+}
+
+func (r *CategoryRepository) FindById(ctx context.Context, categoryId uint) (*models.Category, error) {
+	var category models.Category
+	result := r.db.WithContext(ctx).First(&category, categoryId)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Category is not found")
+		}
+		return nil, result.Error
+	}
+	return &category, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, newCategory models.Category) (*models.Category, error) {
+	result := r.db.WithContext(ctx).Create(&newCategory)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return nil, errors.New("Category already exists")
+		}
+		return nil, result.Error
+	}
+	return &newCategory, nil
+}
+
+type CategoryMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewCategoryMemoryRepository(store *MemoryStore) ICategoryRepository {
+	return &CategoryMemoryRepository{store: store}
+}
+
+func (r *CategoryMemoryRepository) FindAll(ctx context.Context) (*[]models.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	categories := r.store.categories.find(func(c *models.Category) bool { return true })
+	return &categories, nil
+}
+
+func (r *CategoryMemoryRepository) FindById(ctx context.Context, categoryId uint) (*models.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	category, ok := r.store.categories.first(func(c *models.Category) bool { return c.ID == categoryId })
+	if !ok {
+		return nil, errors.New("Category is not found")
+	}
+	return category, nil
+}
+
+func (r *CategoryMemoryRepository) Create(ctx context.Context, newCategory models.Category) (*models.Category, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// DBのcategories.nameの一意制約の代わり
+	if r.store.categories.exists(func(c *models.Category) bool { return c.Name == newCategory.Name }) {
+		return nil, errors.New("Category already exists")
+	}
+	r.store.categories.create(&newCategory)
+	return &newCategory, nil
+}
@@ -0,0 +1,251 @@
+package repositories_test
+
+import (
+	"context"
+	"gin-freemarket/infra"
+	"gin-freemarket/migrate"
+	"gin-freemarket/repositories"
+	"gin-freemarket/repositories/repositorytest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// 契約テストを実行するバックエンド
+// Postgresは接続先（TEST_POSTGRES_HOSTなど）が設定されているときだけ実行する。
+// 例: docker compose up -d のあと TEST_POSTGRES_HOST=localhost TEST_POSTGRES_USER=... go test ./repositories
+type backend struct {
+	name string
+	// 空の状態のデータ置き場を作り、そこに保存するリポジトリを返す
+	newItem           func(t *testing.T) repositories.IItemRepository
+	newAuth           func(t *testing.T) repositories.IAuthRepository
+	newIdempotencyKey func(t *testing.T) repositories.IIdempotencyKeyRepository
+	newAddress        func(t *testing.T) repositories.IAddressRepository
+	newLedger         func(t *testing.T) repositories.ILedgerRepository
+	newCategory       func(t *testing.T) repositories.ICategoryRepository
+	newReview         func(t *testing.T) repositories.IReviewRepository
+	// 商品に紐づくリポジトリは、同じデータ置き場を使う一式で作る
+	newRepos func(t *testing.T) repositorytest.Repositories
+}
+
+func backends() []backend {
+	list := []backend{
+		{
+			name: "memory",
+			newItem: func(t *testing.T) repositories.IItemRepository {
+				return repositories.NewItemMemoryRepository(repositories.NewMemoryStore())
+			},
+			newAuth: func(t *testing.T) repositories.IAuthRepository {
+				return repositories.NewAuthMemoryRepository(repositories.NewMemoryStore())
+			},
+			newIdempotencyKey: func(t *testing.T) repositories.IIdempotencyKeyRepository {
+				return repositories.NewIdempotencyKeyMemoryRepository(repositories.NewMemoryStore())
+			},
+			newAddress: func(t *testing.T) repositories.IAddressRepository {
+				return repositories.NewAddressMemoryRepository(repositories.NewMemoryStore())
+			},
+			newLedger: func(t *testing.T) repositories.ILedgerRepository {
+				return repositories.NewLedgerMemoryRepository(repositories.NewMemoryStore())
+			},
+			newCategory: func(t *testing.T) repositories.ICategoryRepository {
+				return repositories.NewCategoryMemoryRepository(repositories.NewMemoryStore())
+			},
+			newReview: func(t *testing.T) repositories.IReviewRepository {
+				return repositories.NewReviewMemoryRepository(repositories.NewMemoryStore())
+			},
+			newRepos: func(t *testing.T) repositorytest.Repositories {
+				store := repositories.NewMemoryStore()
+				return repositorytest.Repositories{
+					Item:        repositories.NewItemMemoryRepository(store),
+					Offer:       repositories.NewOfferMemoryRepository(store),
+					Reservation: repositories.NewReservationMemoryRepository(store),
+					Order:       repositories.NewOrderMemoryRepository(store),
+					Shipment:    repositories.NewShipmentMemoryRepository(store),
+					Ledger:      repositories.NewLedgerMemoryRepository(store),
+				}
+			},
+		},
+		gormBackend("sqlite", openSQLite),
+	}
+	if os.Getenv("TEST_POSTGRES_HOST") != "" {
+		list = append(list, gormBackend("postgres", openPostgres))
+	}
+	return list
+}
+
+func gormBackend(name string, open func(t *testing.T) *gorm.DB) backend {
+	return backend{
+		name:    name,
+		newItem: func(t *testing.T) repositories.IItemRepository { return repositories.NewItemRepository(open(t)) },
+		newAuth: func(t *testing.T) repositories.IAuthRepository { return repositories.NewAuthRepository(open(t)) },
+		newIdempotencyKey: func(t *testing.T) repositories.IIdempotencyKeyRepository {
+			return repositories.NewIdempotencyKeyRepository(open(t))
+		},
+		newAddress: func(t *testing.T) repositories.IAddressRepository { return repositories.NewAddressRepository(open(t)) },
+		newLedger:  func(t *testing.T) repositories.ILedgerRepository { return repositories.NewLedgerRepository(open(t)) },
+		newCategory: func(t *testing.T) repositories.ICategoryRepository {
+			return repositories.NewCategoryRepository(open(t))
+		},
+		newReview: func(t *testing.T) repositories.IReviewRepository { return repositories.NewReviewRepository(open(t)) },
+		newRepos: func(t *testing.T) repositorytest.Repositories {
+			db := open(t)
+			return repositorytest.Repositories{
+				Item:        repositories.NewItemRepository(db),
+				Offer:       repositories.NewOfferRepository(db),
+				Reservation: repositories.NewReservationRepository(db),
+				Order:       repositories.NewOrderRepository(db),
+				Shipment:    repositories.NewShipmentRepository(db),
+				Ledger:      repositories.NewLedgerRepository(db),
+			}
+		},
+	}
+}
+
+func TestItemRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestItemRepository(t, b.newItem)
+		})
+	}
+}
+
+func TestAuthRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestAuthRepository(t, b.newAuth)
+		})
+	}
+}
+
+func TestIdempotencyKeyRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestIdempotencyKeyRepository(t, b.newIdempotencyKey)
+		})
+	}
+}
+
+func TestAddressRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestAddressRepository(t, b.newAddress)
+		})
+	}
+}
+
+func TestLedgerRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestLedgerRepository(t, b.newLedger)
+		})
+	}
+}
+
+func TestCategoryRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestCategoryRepository(t, b.newCategory)
+		})
+	}
+}
+
+func TestReviewRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestReviewRepository(t, b.newReview)
+		})
+	}
+}
+
+func TestOfferRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestOfferRepository(t, b.newRepos)
+		})
+	}
+}
+
+func TestReservationRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestReservationRepository(t, b.newRepos)
+		})
+	}
+}
+
+func TestOrderRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestOrderRepository(t, b.newRepos)
+		})
+	}
+}
+
+func TestShipmentRepositoryContract(t *testing.T) {
+	for _, b := range backends() {
+		t.Run(b.name, func(t *testing.T) {
+			repositorytest.TestShipmentRepository(t, b.newRepos)
+		})
+	}
+}
+
+// テストごとに新しいメモリ上のSQLiteを作り、マイグレーションを適用する
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	return openMigrated(t, infra.DBConfig{Driver: infra.DriverSQLite, Path: infra.SQLiteInMemory})
+}
+
+// Postgresはテストごとに作り直せないので、マイグレーションを適用した上で全テーブルを空にする
+func openPostgres(t *testing.T) *gorm.DB {
+	t.Helper()
+	port, _ := strconv.Atoi(getenv("TEST_POSTGRES_PORT", "5432"))
+	db := openMigrated(t, infra.DBConfig{
+		Driver:   infra.DriverPostgres,
+		Host:     os.Getenv("TEST_POSTGRES_HOST"),
+		User:     getenv("TEST_POSTGRES_USER", "postgres"),
+		Password: infra.Secret(os.Getenv("TEST_POSTGRES_PASSWORD")),
+		Name:     getenv("TEST_POSTGRES_DB", "postgres"),
+		Port:     uint(port),
+		SSLMode:  "disable",
+		TimeZone: "UTC",
+	})
+
+	var tables []string
+	err := db.Raw(`SELECT tablename FROM pg_tables WHERE schemaname = current_schema() AND tablename <> 'schema_migrations'`).Scan(&tables).Error
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+	if err := db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+	return db
+}
+
+func openMigrated(t *testing.T, cfg infra.DBConfig) *gorm.DB {
+	t.Helper()
+	db := infra.SetupDB(cfg)
+	t.Cleanup(func() { infra.CloseDB(db) })
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get database connection: %v", err)
+	}
+	migrator, err := migrate.New(sqlDB, cfg.Driver)
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func getenv(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
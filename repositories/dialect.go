@@ -0,0 +1,16 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 行ロック（SELECT ... FOR UPDATE）を付ける。トランザクション内で呼ぶこと
+// SQLiteには行ロックがないので何も付けない。SQLiteではトランザクションをBEGIN IMMEDIATEで始めているので
+// （infra.SetupDBを参照）、トランザクション全体がDBの書き込みロックを持ち、同じように他の更新と直列になる
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == "sqlite" {
+		return tx
+	}
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IIdempotencyKeyRepository interface {
+	// 同じユーザー・キーの期限内の記録があればそれを返し（createdはfalse）、なければnewKeyを保存して返す（createdはtrue）。
+	// 処理中のままLockedUntilを過ぎた記録は、Fingerprintが同じならnewKeyの期限で引き継いで返す（createdはtrue）。
+	// 同じキーで同時に呼ばれても、createdがtrueになるのは1つだけ
+	Reserve(ctx context.Context, newKey models.IdempotencyKey) (key *models.IdempotencyKey, created bool, err error)
+	// 処理が終わったリクエストのレスポンスを保存する（記録がなければ"Idempotency key is not found"）
+	SaveResponse(ctx context.Context, userId uint, key string, statusCode int, header string, body []byte) error
+	// 記録を消す。処理に失敗したリクエストを同じキーでやり直せるようにするため
+	Delete(ctx context.Context, userId uint, key string) error
+
+	// 期限切れの記録をまとめて消し、消した件数を返す
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type IdempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IIdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{db: db}
+}
+
+func (r *IdempotencyKeyRepository) Reserve(ctx context.Context, newKey models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	db := r.db.WithContext(ctx)
+	now := time.Now()
+
+	// 期限切れの記録がまだ掃除されずに残っていれば、先に消して使い直せるようにする
+	expired := db.Unscoped().Where("user_id = ? AND key = ? AND expires_at <= ?", newKey.UserId, newKey.Key, now)
+	if err := expired.Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	// 先に読んでから作ると同時に来たリクエストが両方とも作ろうとするので、一意制約に任せてまず作ってみる
+	created := newKey
+	err := db.Create(&created).Error
+	if err == nil {
+		return &created, true, nil
+	}
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, false, err
+	}
+
+	// 処理中のまま持ち主の期限が過ぎていれば引き継ぐ。条件付きで更新するので、同時に来ても引き継げるのは1つだけ
+	takeover := db.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ? AND fingerprint = ? AND status_code = 0 AND locked_until <= ?", newKey.UserId, newKey.Key, newKey.Fingerprint, now).
+		Updates(map[string]any{"locked_until": newKey.LockedUntil, "expires_at": newKey.ExpiresAt})
+	if takeover.Error != nil {
+		return nil, false, takeover.Error
+	}
+	tookOver := takeover.RowsAffected > 0
+
+	var existing models.IdempotencyKey
+	if err := db.First(&existing, "user_id = ? AND key = ?", newKey.UserId, newKey.Key).Error; err != nil {
+		// 作れなかった直後に、先に作った側が処理に失敗して消した場合
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, errors.New("Idempotency key is not found")
+		}
+		return nil, false, err
+	}
+	return &existing, tookOver, nil
+}
+
+func (r *IdempotencyKeyRepository) SaveResponse(ctx context.Context, userId uint, key string, statusCode int, header string, body []byte) error {
+	result := r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userId, key).
+		Updates(map[string]any{"status_code": statusCode, "response_header": header, "response_body": body})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Idempotency key is not found")
+	}
+	return nil
+}
+
+func (r *IdempotencyKeyRepository) Delete(ctx context.Context, userId uint, key string) error {
+	return r.db.WithContext(ctx).Unscoped().Where("user_id = ? AND key = ?", userId, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+func (r *IdempotencyKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+type IdempotencyKeyMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewIdempotencyKeyMemoryRepository(store *MemoryStore) IIdempotencyKeyRepository {
+	return &IdempotencyKeyMemoryRepository{store: store}
+}
+
+func (r *IdempotencyKeyMemoryRepository) Reserve(ctx context.Context, newKey models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	r.store.idempotencyKeys.purge(func(k *models.IdempotencyKey) bool {
+		return k.UserId == newKey.UserId && k.Key == newKey.Key && !k.ExpiresAt.After(now)
+	})
+	if existing, ok := r.store.idempotencyKeys.first(func(k *models.IdempotencyKey) bool {
+		return k.UserId == newKey.UserId && k.Key == newKey.Key
+	}); ok {
+		// 処理中のまま持ち主の期限が過ぎていれば引き継ぐ
+		if existing.Fingerprint == newKey.Fingerprint && existing.StatusCode == 0 && !existing.LockedUntil.After(now) {
+			existing.LockedUntil = newKey.LockedUntil
+			existing.ExpiresAt = newKey.ExpiresAt
+			r.store.idempotencyKeys.save(existing)
+			return existing, true, nil
+		}
+		return existing, false, nil
+	}
+	r.store.idempotencyKeys.create(&newKey)
+	return &newKey, true, nil
+}
+
+func (r *IdempotencyKeyMemoryRepository) SaveResponse(ctx context.Context, userId uint, key string, statusCode int, header string, body []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	found, ok := r.store.idempotencyKeys.first(func(k *models.IdempotencyKey) bool { return k.UserId == userId && k.Key == key })
+	if !ok {
+		return errors.New("Idempotency key is not found")
+	}
+	found.StatusCode = statusCode
+	found.ResponseHeader = header
+	// 呼び出し元がbodyを使い回しても保存した内容が変わらないよう、コピーして持つ
+	found.ResponseBody = append([]byte(nil), body...)
+	r.store.idempotencyKeys.save(found)
+	return nil
+}
+
+func (r *IdempotencyKeyMemoryRepository) Delete(ctx context.Context, userId uint, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.idempotencyKeys.purge(func(k *models.IdempotencyKey) bool { return k.UserId == userId && k.Key == key })
+	return nil
+}
+
+func (r *IdempotencyKeyMemoryRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return int64(r.store.idempotencyKeys.purge(func(k *models.IdempotencyKey) bool { return !k.ExpiresAt.After(now) })), nil
+}
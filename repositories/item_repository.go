@@ -1,41 +1,51 @@
 package repositories
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"gin-freemarket/models"
+	"reflect"
 
 	"gorm.io/gorm"
 )
 
+//go:generate go tool mockgen -source=item_repository.go -destination=../mocks/mock_item_repository.go -package=mocks
+
 // アイテムのリポジトリが持つべき基本機能（インターフェース）の実装
 // メソッド名() (戻り値)
 // メソッドの引数は基本的に値渡し。参照を渡すのはDBぐらい
 type IItemRepository interface {
 
+	// 第1引数のctxはリクエストのコンテキスト。DBへのクエリに引き継ぐことで、トレースのスパンが親子でつながる
+
 	// FindAllというメソッド名で、戻り値がmodels.Item型スライスへのポインタとerrorを返す（errorがない場合はnil）
 	// 戻り値は参照を返すのが一般的（無駄なコピーを避けて省メモリ・省コストにしたい）
-	FindAll() (*[]models.Item, error)
+	FindAll(ctx context.Context) (*[]models.Item, error)
 
 	// id検索は1件のみ返ってくるので、戻り値は*models.Itemとなる（FindAllは複数件返ってくる想定だから配列）
-	FindById(itemId uint) (*models.Item, error)
+	FindById(ctx context.Context, itemId uint) (*models.Item, error)
 
-	Create(newItem models.Item) (*models.Item, error)
-	Update(newItem models.Item) (*models.Item, error)
-	Delete(itemId uint) error
+	Create(ctx context.Context, newItem models.Item) (*models.Item, error)
+
+	// 保存されている商品のバージョンがnewItem.Versionと一致するときだけ、fields（構造体のフィールド名）の列を更新し、バージョンを1つ上げる
+	// 一致しなければ（他の更新が先に入っていれば）"Item has been modified"、削除済み・存在しなければ"Item is not found"
+	Update(ctx context.Context, newItem models.Item, fields []string) (*models.Item, error)
+	Delete(ctx context.Context, itemId uint) error
 }
 
 // アイテム情報をメモリ上に保存・取り扱うための「リポジトリ（倉庫）」となる構造体の定義
 // データベースやファイルを直接使わず、一時的にメモリだけでデータ（アイテム一覧）を管理したい時に使う。（初っ端はDB使わないから）
-// itemsというフィールドに全Itemを保持する。
+// データは他のメモリ上のリポジトリと共有するMemoryStoreに保持する。
 type ItemMemoryRopository struct {
-	items []models.Item
+	store *MemoryStore
 }
 
 // ItemMemoryRopositoryのコンストラクタ
-func NewItemMemoryRepository(items []models.Item) IItemRepository {
+func NewItemMemoryRepository(store *MemoryStore) IItemRepository {
 	// 作成した構造体のポインタを返す
 	// &構造体{}とすると、その構造体のインスタンスをメモリ上に作り、そのポインタを取得する
-	return &ItemMemoryRopository{items: items}
+	return &ItemMemoryRopository{store: store}
 }
 
 // ItemMemoryRopository型のポインタ（参照）を受け取る
@@ -43,51 +53,96 @@ func NewItemMemoryRepository(items []models.Item) IItemRepository {
 // 元の構造体そのものを参照しているので、メソッド内から直接中身を変更ができるし、構造体が大きくてもパフォーマンスに影響がない
 
 // Laravelとかでいうインスタンスをメソッドの頭にくっつけていると思ったらいい
-func (r *ItemMemoryRopository) FindAll() (*[]models.Item, error) {
-	// r.itemsのポインタを返す必要があるので&をつける
-	return &r.items, nil
+func (r *ItemMemoryRopository) FindAll(ctx context.Context) (*[]models.Item, error) {
+	// DBを使う実装と同じく、キャンセル・タイムアウト済みのリクエストは処理しない
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// 複数のリクエストから同時に呼ばれるので、読み書きは必ずロックを取ってから行う
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	// 保存している行そのものではなくコピーを返すので、呼び出し元で書き換えても保存内容は変わらない
+	items := r.store.items.find(func(item *models.Item) bool { return true })
+	return &items, nil
 }
 
-func (r *ItemMemoryRopository) FindById(itemId uint) (*models.Item, error) {
-	for _, v := range r.items {
-		if v.ID == itemId {
-			return &v, nil
-		}
+func (r *ItemMemoryRopository) FindById(ctx context.Context, itemId uint) (*models.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	return nil, errors.New("Item is not found")
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.findItem(itemId)
 }
 
-func (r *ItemMemoryRopository) Create(newItem models.Item) (*models.Item, error) {
-	newItem.ID = uint(len(r.items) + 1)
-	r.items = append(r.items, newItem)
+func (r *ItemMemoryRopository) Create(ctx context.Context, newItem models.Item) (*models.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if newItem.Version == 0 {
+		newItem.Version = 1
+	}
+	// IDは削除しても再利用しない連番で振られる
+	r.store.items.create(&newItem)
 	return &newItem, nil
 }
 
-func (r *ItemMemoryRopository) Update(updateItem models.Item) (*models.Item, error) {
-	for i, v := range r.items {
-		if v.ID == updateItem.ID {
-			r.items[i] = updateItem
-			return &r.items[i], nil
+func (r *ItemMemoryRopository) Update(ctx context.Context, updateItem models.Item, fields []string) (*models.Item, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, err := r.store.findItem(updateItem.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item.Version != updateItem.Version {
+		return nil, errors.New("Item has been modified")
+	}
+
+	// DBと同じく、指定された項目だけを書き換える
+	src := reflect.ValueOf(updateItem)
+	dst := reflect.ValueOf(item).Elem()
+	for _, name := range fields {
+		field := dst.FieldByName(name)
+		if !field.IsValid() {
+			return nil, fmt.Errorf("unknown item field: %s", name)
 		}
+		field.Set(src.FieldByName(name))
 	}
-	return nil, errors.New("Unexpected Error")
+	item.Version++
+	r.store.items.save(item)
+	return item, nil
 }
 
-func (r *ItemMemoryRopository) Delete(itemId uint) error {
-	for i, v := range r.items {
-		if v.ID == itemId {
-			// goにはスライス（配列）から特定のindexを削除するという処理がないので、以下のように実現している
+func (r *ItemMemoryRopository) Delete(ctx context.Context, itemId uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
 
-			// 1.r.items[:i] は「削除対象より前の要素」の新しいスライス、r.items[i+1:] は「削除対象より後の要素」新しいスライス
-			// 2.appendを使って「削除対象より前の要素」の新しいスライスに対し、r.items[i+1:] は「削除対象より後の要素」新しいスライスを合体させる
-			// 3.ただし、スライス同士の結合はそのままではできないので、合体させるスライス（削除対象より後の要素）をスプレッド演算子を使って展開しながらappend
-			// → これにより該当idのみを除外しつつ、新しいスライスを作成するということが可能になる
+	// DBと同じく論理削除。以後の検索では見つからなくなる
+	if r.store.items.delete(func(item *models.Item) bool { return item.ID == itemId }) == 0 {
+		return errors.New("Item is not found")
+	}
+	return nil
+}
 
-			r.items = append(r.items[:i], r.items[i+1:]...)
-			return nil
-		}
+// 商品を取得する。DBのlockItemと違い、ロックは呼び出し元がstore.muで取っておくこと
+func (s *MemoryStore) findItem(itemId uint) (*models.Item, error) {
+	item, ok := s.items.first(func(item *models.Item) bool { return item.ID == itemId })
+	if !ok {
+		return nil, errors.New("Item is not found")
 	}
-	return errors.New("Item not Found")
+	return item, nil
 }
 
 type ItemRepository struct {
@@ -95,9 +150,12 @@ type ItemRepository struct {
 }
 
 // Create implements IItemRepository.
-func (r *ItemRepository) Create(newItem models.Item) (*models.Item, error) {
+func (r *ItemRepository) Create(ctx context.Context, newItem models.Item) (*models.Item, error) {
+	if newItem.Version == 0 {
+		newItem.Version = 1
+	}
 	// gormを介したDB登録では引数は参照を渡すこと
-	result := r.db.Create(&newItem)
+	result := r.db.WithContext(ctx).Create(&newItem)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -106,13 +164,13 @@ func (r *ItemRepository) Create(newItem models.Item) (*models.Item, error) {
 }
 
 // Delete implements IItemRepository.
-func (r *ItemRepository) Delete(itemId uint) error {
-	deleteItem, err := r.FindById(itemId)
+func (r *ItemRepository) Delete(ctx context.Context, itemId uint) error {
+	deleteItem, err := r.FindById(ctx, itemId)
 	if err != nil {
 		return err
 	}
 	// 論理削除(deleted atに時刻が入るだけ)
-	result := r.db.Delete(&deleteItem)
+	result := r.db.WithContext(ctx).Delete(&deleteItem)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -120,14 +178,14 @@ func (r *ItemRepository) Delete(itemId uint) error {
 }
 
 // FindAll implements IItemRepository.
-func (r *ItemRepository) FindAll() (*[]models.Item, error) {
+func (r *ItemRepository) FindAll(ctx context.Context) (*[]models.Item, error) {
 
 	// 検索結果を格納する変数
 	var items []models.Item
 
 	// 上記の変数の型はすでにmodels.Itemで定義されている。それに合わせた形で
 	// データを取得&整形してくれる
-	result := r.db.Find(&items)
+	result := r.db.WithContext(ctx).Find(&items)
 
 	if result.Error != nil {
 		return nil, result.Error
@@ -136,13 +194,13 @@ func (r *ItemRepository) FindAll() (*[]models.Item, error) {
 }
 
 // FindById implements IItemRepository.
-func (r *ItemRepository) FindById(itemId uint) (*models.Item, error) {
+func (r *ItemRepository) FindById(ctx context.Context, itemId uint) (*models.Item, error) {
 	var item models.Item
 
 	// 主キーがidであればカラムの指定はいらない
 	// カラム指定の場合は次のような感じ
 	// result := r.db.First(&item, "id = ?", itemId)
-	result := r.db.First(&item, itemId)
+	result := r.db.WithContext(ctx).First(&item, itemId)
 	if result.Error != nil {
 		if result.Error.Error() == "record not found" {
 			return nil, errors.New("Item is not found")
@@ -153,15 +211,38 @@ func (r *ItemRepository) FindById(itemId uint) (*models.Item, error) {
 }
 
 // Update implements IItemRepository.
-func (r *ItemRepository) Update(updateItem models.Item) (*models.Item, error) {
-
-	// Saveメソッドは更新対象が存在すればupdate、存在しなければinsertといったアップサートを行う
-	// updateItemには必要な部分のみを変更した1レコードが入っていて、それをそのまま上書きという感じ（=必要な部分のみ更新がはいる）
-	result := r.db.Save(&updateItem)
-	if result.Error != nil {
-		return nil, result.Error
+func (r *ItemRepository) Update(ctx context.Context, updateItem models.Item, fields []string) (*models.Item, error) {
+	var updated models.Item
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		version := updateItem.Version
+		updateItem.Version++
+
+		// Saveは全列を上書きし、行がなければinsertしてしまう（削除された商品が復活する）ので使わない
+		// UPDATE items SET <fields>, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL
+		columns := append([]string{"Version"}, fields...)
+		result := tx.Model(&models.Item{}).
+			Where("id = ? AND version = ?", updateItem.ID, version).
+			Select(columns).
+			Updates(&updateItem)
+		if result.Error != nil {
+			return result.Error
+		}
+		if err := tx.First(&updated, updateItem.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("Item is not found")
+			}
+			return err
+		}
+		// 1行も更新されず、商品は残っている → 読み込んだ後に他の更新が入った
+		if result.RowsAffected == 0 {
+			return errors.New("Item has been modified")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return &updateItem, nil
+	return &updated, nil
 }
 
 func NewItemRepository(db *gorm.DB) IItemRepository {
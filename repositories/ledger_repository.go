@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"context"
+	"gin-freemarket/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ILedgerRepository interface {
+	// 勘定の残高（貸方合計 - 借方合計）を返す。勘定がまだなければ0
+	Balance(ctx context.Context, accountName string) (int64, error)
+
+	// 出金申請を登録する。buildには出金元の勘定をロックした上での残高が渡され、
+	// 返した仕訳が出金申請と同じトランザクションで保存される
+	CreatePayout(ctx context.Context, newPayout models.Payout, accountName string, build func(balance int64) (*models.LedgerTransaction, error)) (*models.Payout, error)
+}
+
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) ILedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+func (r *LedgerRepository) Balance(ctx context.Context, accountName string) (int64, error) {
+	return balance(r.db.WithContext(ctx), accountName)
+}
+
+func (r *LedgerRepository) CreatePayout(ctx context.Context, newPayout models.Payout, accountName string, build func(balance int64) (*models.LedgerTransaction, error)) (*models.Payout, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 同じ勘定からの出金申請を直列化するため、勘定の行をロックしてから残高を計算する
+		account, err := findOrCreateAccount(tx, models.LedgerAccount{Name: accountName, UserId: &newPayout.UserId})
+		if err != nil {
+			return err
+		}
+		if err := forUpdate(tx).First(account, account.ID).Error; err != nil {
+			return err
+		}
+
+		current, err := balance(tx, accountName)
+		if err != nil {
+			return err
+		}
+
+		ledgerTransaction, err := build(current)
+		if err != nil {
+			return err
+		}
+		if err := saveLedgerTransaction(tx, ledgerTransaction); err != nil {
+			return err
+		}
+
+		newPayout.TransactionId = ledgerTransaction.ID
+		return tx.Create(&newPayout).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &newPayout, nil
+}
+
+func balance(db *gorm.DB, accountName string) (int64, error) {
+	var result struct {
+		Balance int64
+	}
+	err := db.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE -ledger_entries.amount END), 0) AS balance", models.LedgerCredit).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Where("ledger_accounts.name = ?", accountName).
+		Scan(&result).Error
+	if err != nil {
+		return 0, err
+	}
+	return result.Balance, nil
+}
+
+// 仕訳と明細を保存する。明細の勘定は名前で引き当て、なければ作成する。
+// 呼び出し元のトランザクション内で使うこと
+func saveLedgerTransaction(tx *gorm.DB, ledgerTransaction *models.LedgerTransaction) error {
+	for i := range ledgerTransaction.Entries {
+		account, err := findOrCreateAccount(tx, ledgerTransaction.Entries[i].Account)
+		if err != nil {
+			return err
+		}
+		ledgerTransaction.Entries[i].AccountId = account.ID
+		ledgerTransaction.Entries[i].Account = *account
+	}
+	// 勘定は上で保存済みなので、関連の自動保存（upsert）は行わない
+	return tx.Omit("Entries.Account").Create(ledgerTransaction).Error
+}
+
+func findOrCreateAccount(tx *gorm.DB, account models.LedgerAccount) (*models.LedgerAccount, error) {
+	// 同じ勘定が同時に作られても一意制約違反にならないよう、INSERT ... ON CONFLICT DO NOTHINGしてから読み直す
+	newAccount := models.LedgerAccount{Name: account.Name, UserId: account.UserId}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&newAccount).Error; err != nil {
+		return nil, err
+	}
+
+	var found models.LedgerAccount
+	if err := tx.First(&found, "name = ?", account.Name).Error; err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+type LedgerMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewLedgerMemoryRepository(store *MemoryStore) ILedgerRepository {
+	return &LedgerMemoryRepository{store: store}
+}
+
+func (r *LedgerMemoryRepository) Balance(ctx context.Context, accountName string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return r.store.balance(accountName), nil
+}
+
+func (r *LedgerMemoryRepository) CreatePayout(ctx context.Context, newPayout models.Payout, accountName string, build func(balance int64) (*models.LedgerTransaction, error)) (*models.Payout, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// ロックを持っている間は他の出金申請や仕訳が割り込まないので、ここで読んだ残高のまま計上できる
+	ledgerTransaction, err := build(r.store.balance(accountName))
+	if err != nil {
+		return nil, err
+	}
+	r.store.findOrCreateAccount(models.LedgerAccount{Name: accountName, UserId: &newPayout.UserId})
+	r.store.saveLedgerTransaction(ledgerTransaction)
+
+	newPayout.TransactionId = ledgerTransaction.ID
+	r.store.payouts.create(&newPayout)
+	return &newPayout, nil
+}
+
+func (s *MemoryStore) balance(accountName string) int64 {
+	account, ok := s.ledgerAccounts.first(func(a *models.LedgerAccount) bool { return a.Name == accountName })
+	if !ok {
+		return 0
+	}
+	var total int64
+	for _, v := range s.ledgerEntries.find(func(e *models.LedgerEntry) bool { return e.AccountId == account.ID }) {
+		if v.Direction == models.LedgerCredit {
+			total += int64(v.Amount)
+		} else {
+			total -= int64(v.Amount)
+		}
+	}
+	return total
+}
+
+// 仕訳と明細を保存し、ledgerTransactionにIDなどを書き戻す
+// 保存する明細には勘定（Account）を含めず、AccountIdだけで紐付ける
+func (s *MemoryStore) saveLedgerTransaction(ledgerTransaction *models.LedgerTransaction) {
+	header := *ledgerTransaction
+	header.Entries = nil
+	s.ledgerTransactions.create(&header)
+	ledgerTransaction.Model = header.Model
+
+	for i := range ledgerTransaction.Entries {
+		entry := &ledgerTransaction.Entries[i]
+		entry.Account = *s.findOrCreateAccount(entry.Account)
+		entry.AccountId = entry.Account.ID
+		entry.TransactionId = header.ID
+
+		stored := *entry
+		stored.Account = models.LedgerAccount{}
+		s.ledgerEntries.create(&stored)
+		entry.Model = stored.Model
+	}
+}
+
+func (s *MemoryStore) findOrCreateAccount(account models.LedgerAccount) *models.LedgerAccount {
+	if found, ok := s.ledgerAccounts.first(func(a *models.LedgerAccount) bool { return a.Name == account.Name }); ok {
+		return found
+	}
+	newAccount := models.LedgerAccount{Name: account.Name}
+	// 呼び出し元のポインタを保存しないよう、値をコピーしておく
+	if account.UserId != nil {
+		userId := *account.UserId
+		newAccount.UserId = &userId
+	}
+	s.ledgerAccounts.create(&newAccount)
+	return &newAccount
+}
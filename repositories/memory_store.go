@@ -0,0 +1,168 @@
+package repositories
+
+import (
+	"gin-freemarket/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// メモリ上のリポジトリが共有するデータ置き場（DBの代わり）
+// 注文の状態遷移のように複数のテーブルをまとめて更新する操作があるので、ロックはテーブルごとではなく1つにして、
+// ロックを持っている間をDBのトランザクションの代わりにする
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users              memoryTable[models.User]
+	items              memoryTable[models.Item]
+	categories         memoryTable[models.Category]
+	reservations       memoryTable[models.Reservation]
+	offers             memoryTable[models.Offer]
+	addresses          memoryTable[models.Address]
+	orders             memoryTable[models.Order]
+	webhookEvents      memoryTable[models.WebhookEvent]
+	shipments          memoryTable[models.Shipment]
+	shipmentEvents     memoryTable[models.ShipmentEvent]
+	ledgerAccounts     memoryTable[models.LedgerAccount]
+	ledgerTransactions memoryTable[models.LedgerTransaction]
+	ledgerEntries      memoryTable[models.LedgerEntry]
+	payouts            memoryTable[models.Payout]
+	idempotencyKeys    memoryTable[models.IdempotencyKey]
+	reviews            memoryTable[models.Review]
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:              memoryTable[models.User]{model: func(r *models.User) *gorm.Model { return &r.Model }},
+		items:              memoryTable[models.Item]{model: func(r *models.Item) *gorm.Model { return &r.Model }},
+		categories:         memoryTable[models.Category]{model: func(r *models.Category) *gorm.Model { return &r.Model }},
+		reservations:       memoryTable[models.Reservation]{model: func(r *models.Reservation) *gorm.Model { return &r.Model }},
+		offers:             memoryTable[models.Offer]{model: func(r *models.Offer) *gorm.Model { return &r.Model }},
+		addresses:          memoryTable[models.Address]{model: func(r *models.Address) *gorm.Model { return &r.Model }},
+		orders:             memoryTable[models.Order]{model: func(r *models.Order) *gorm.Model { return &r.Model }},
+		webhookEvents:      memoryTable[models.WebhookEvent]{model: func(r *models.WebhookEvent) *gorm.Model { return &r.Model }},
+		shipments:          memoryTable[models.Shipment]{model: func(r *models.Shipment) *gorm.Model { return &r.Model }},
+		shipmentEvents:     memoryTable[models.ShipmentEvent]{model: func(r *models.ShipmentEvent) *gorm.Model { return &r.Model }},
+		ledgerAccounts:     memoryTable[models.LedgerAccount]{model: func(r *models.LedgerAccount) *gorm.Model { return &r.Model }},
+		ledgerTransactions: memoryTable[models.LedgerTransaction]{model: func(r *models.LedgerTransaction) *gorm.Model { return &r.Model }},
+		ledgerEntries:      memoryTable[models.LedgerEntry]{model: func(r *models.LedgerEntry) *gorm.Model { return &r.Model }},
+		payouts:            memoryTable[models.Payout]{model: func(r *models.Payout) *gorm.Model { return &r.Model }},
+		idempotencyKeys:    memoryTable[models.IdempotencyKey]{model: func(r *models.IdempotencyKey) *gorm.Model { return &r.Model }},
+		reviews:            memoryTable[models.Review]{model: func(r *models.Review) *gorm.Model { return &r.Model }},
+	}
+}
+
+// 1つのテーブル。行はID順に並んでいる
+// 返す値はすべてコピーなので、呼び出し元が書き換えても保存されている行は変わらない
+type memoryTable[T any] struct {
+	rows   []T
+	lastId uint // 採番済みの最大のID。削除しても戻さないので、IDが再利用されることはない
+	model  func(row *T) *gorm.Model
+}
+
+// 論理削除されていない行のうち、matchに合うものをID順に返す
+func (t *memoryTable[T]) find(match func(row *T) bool) []T {
+	found := []T{}
+	for i := range t.rows {
+		if t.model(&t.rows[i]).DeletedAt.Valid {
+			continue
+		}
+		if match(&t.rows[i]) {
+			found = append(found, t.rows[i])
+		}
+	}
+	return found
+}
+
+// findの最初の1件（gormのFirstと同じく、IDが一番小さいもの）
+func (t *memoryTable[T]) first(match func(row *T) bool) (*T, bool) {
+	for i := range t.rows {
+		if t.model(&t.rows[i]).DeletedAt.Valid {
+			continue
+		}
+		if match(&t.rows[i]) {
+			row := t.rows[i]
+			return &row, true
+		}
+	}
+	return nil, false
+}
+
+// 論理削除された行も含めて、matchに合う行があるかどうか（DBの一意制約と同じく削除済みの行とも重複させない）
+func (t *memoryTable[T]) exists(match func(row *T) bool) bool {
+	for i := range t.rows {
+		if match(&t.rows[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// gormのCreateと同じく、IDを採番し、CreatedAt・UpdatedAtが空なら現在時刻を入れる
+// IDを指定した場合はそのIDで保存する
+func (t *memoryTable[T]) create(row *T) {
+	model := t.model(row)
+	if model.ID == 0 {
+		t.lastId++
+		model.ID = t.lastId
+	} else if model.ID > t.lastId {
+		t.lastId = model.ID
+	}
+	now := time.Now()
+	if model.CreatedAt.IsZero() {
+		model.CreatedAt = now
+	}
+	if model.UpdatedAt.IsZero() {
+		model.UpdatedAt = now
+	}
+	t.rows = append(t.rows, *row)
+	// IDを指定して既存の行より小さいIDで作った場合も、ID順を保つ
+	if n := len(t.rows); n > 1 && t.model(&t.rows[n-2]).ID > model.ID {
+		sort.SliceStable(t.rows, func(i, j int) bool { return t.model(&t.rows[i]).ID < t.model(&t.rows[j]).ID })
+	}
+}
+
+// gormのSaveと同じく、IDの行があれば丸ごと上書きし、なければ追加する
+func (t *memoryTable[T]) save(row *T) {
+	model := t.model(row)
+	for i := range t.rows {
+		if t.model(&t.rows[i]).ID == model.ID {
+			model.UpdatedAt = time.Now()
+			t.rows[i] = *row
+			return
+		}
+	}
+	t.create(row)
+}
+
+// 論理削除（DeletedAtに時刻が入るだけ）。削除した件数を返す
+func (t *memoryTable[T]) delete(match func(row *T) bool) int {
+	now := time.Now()
+	count := 0
+	for i := range t.rows {
+		model := t.model(&t.rows[i])
+		if model.DeletedAt.Valid || !match(&t.rows[i]) {
+			continue
+		}
+		model.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		count++
+	}
+	return count
+}
+
+// 物理削除（gormのUnscoped().Deleteと同じく、論理削除済みの行も含めて取り除く）。削除した件数を返す
+func (t *memoryTable[T]) purge(match func(row *T) bool) int {
+	kept := t.rows[:0]
+	for i := range t.rows {
+		if !match(&t.rows[i]) {
+			kept = append(kept, t.rows[i])
+		}
+	}
+	count := len(t.rows) - len(kept)
+	// 取り除いた行を参照し続けないよう、詰めた後ろの部分は空にする
+	clear(t.rows[len(kept):])
+	t.rows = kept
+	return count
+}
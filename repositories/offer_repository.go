@@ -0,0 +1,270 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IOfferRepository interface {
+	FindById(ctx context.Context, offerId uint) (*models.Offer, error)
+	FindByItem(ctx context.Context, itemId uint) (*[]models.Offer, error)
+	// 商品に対して合意済みで確保期限内のオファーを返す（なければ"Offer is not found"）
+	// 期限切れの合意の後に別の購入希望者と合意し直すことがあるので、期限切れのものは無視する
+	FindAccepted(ctx context.Context, itemId uint, now time.Time) (*models.Offer, error)
+
+	// validateには、ロック済みの商品とその商品に付いている既存オファーが渡される。
+	// エラーを返すと登録は行われない
+	Create(ctx context.Context, newOffer models.Offer, validate func(item *models.Item, offers []models.Offer) error) (*models.Offer, error)
+
+	// applyでオファーの状態を書き換えると、その内容で保存される。
+	// 同じ商品に対する操作は商品行のロックで直列化される
+	Transition(ctx context.Context, offerId uint, apply func(offer *models.Offer, item *models.Item) error) (*models.Offer, error)
+}
+
+type OfferRepository struct {
+	db *gorm.DB
+}
+
+func NewOfferRepository(db *gorm.DB) IOfferRepository {
+	return &OfferRepository{db: db}
+}
+
+func (r *OfferRepository) FindById(ctx context.Context, offerId uint) (*models.Offer, error) {
+	var offer models.Offer
+	result := r.db.WithContext(ctx).First(&offer, offerId)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Offer is not found")
+		}
+		return nil, result.Error
+	}
+	return &offer, nil
+}
+
+func (r *OfferRepository) FindByItem(ctx context.Context, itemId uint) (*[]models.Offer, error) {
+	var offers []models.Offer
+	result := r.db.WithContext(ctx).Where("item_id = ?", itemId).Order("id").Find(&offers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &offers, nil
+}
+
+func (r *OfferRepository) FindAccepted(ctx context.Context, itemId uint, now time.Time) (*models.Offer, error) {
+	offer, err := findAcceptedOffer(r.db.WithContext(ctx), itemId, now)
+	if err != nil {
+		return nil, err
+	}
+	if offer == nil {
+		return nil, errors.New("Offer is not found")
+	}
+	return offer, nil
+}
+
+func (r *OfferRepository) Create(ctx context.Context, newOffer models.Offer, validate func(item *models.Item, offers []models.Offer) error) (*models.Offer, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		item, err := lockItem(tx, newOffer.ItemId)
+		if err != nil {
+			return err
+		}
+
+		var offers []models.Offer
+		if err := tx.Where("item_id = ?", item.ID).Find(&offers).Error; err != nil {
+			return err
+		}
+
+		if err := validate(item, offers); err != nil {
+			return err
+		}
+		return tx.Create(&newOffer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &newOffer, nil
+}
+
+func (r *OfferRepository) Transition(ctx context.Context, offerId uint, apply func(offer *models.Offer, item *models.Item) error) (*models.Offer, error) {
+	var offer models.Offer
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&offer, offerId).Error; err != nil {
+			if err.Error() == "record not found" {
+				return errors.New("Offer is not found")
+			}
+			return err
+		}
+
+		item, err := lockItem(tx, offer.ItemId)
+		if err != nil {
+			return err
+		}
+
+		// ロックを取るまでの間に別のリクエストが状態を変えているかもしれないので読み直す
+		if err := tx.First(&offer, offerId).Error; err != nil {
+			return err
+		}
+
+		if err := apply(&offer, item); err != nil {
+			return err
+		}
+		if err := tx.Save(&offer).Error; err != nil {
+			return err
+		}
+
+		// 合意が成立したら、同じ商品への回答待ちオファーはすべて却下する
+		if offer.Status == models.OfferStatusAccepted {
+			return tx.Model(&models.Offer{}).
+				Where("item_id = ? AND id <> ? AND status IN ?", offer.ItemId, offer.ID,
+					[]string{models.OfferStatusPending, models.OfferStatusCountered}).
+				Update("status", models.OfferStatusRejected).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// 商品行をforUpdateでロックして取得する。トランザクション内で呼ぶこと
+func lockItem(tx *gorm.DB, itemId uint) (*models.Item, error) {
+	var item models.Item
+	result := forUpdate(tx).First(&item, itemId)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Item is not found")
+		}
+		return nil, result.Error
+	}
+	return &item, nil
+}
+
+// 確保期限内の合意済みオファーを取得する（なければnil）
+// 合意し直した場合に備えて、新しいものを優先する
+func findAcceptedOffer(db *gorm.DB, itemId uint, now time.Time) (*models.Offer, error) {
+	var offers []models.Offer
+	result := db.Where("item_id = ? AND status = ? AND expires_at > ?", itemId, models.OfferStatusAccepted, now).
+		Order("id DESC").Limit(1).Find(&offers)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(offers) == 0 {
+		return nil, nil
+	}
+	return &offers[0], nil
+}
+
+type OfferMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewOfferMemoryRepository(store *MemoryStore) IOfferRepository {
+	return &OfferMemoryRepository{store: store}
+}
+
+func (r *OfferMemoryRepository) FindById(ctx context.Context, offerId uint) (*models.Offer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offer, ok := r.store.offers.first(func(o *models.Offer) bool { return o.ID == offerId })
+	if !ok {
+		return nil, errors.New("Offer is not found")
+	}
+	return offer, nil
+}
+
+func (r *OfferMemoryRepository) FindByItem(ctx context.Context, itemId uint) (*[]models.Offer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offers := r.store.offers.find(func(o *models.Offer) bool { return o.ItemId == itemId })
+	return &offers, nil
+}
+
+func (r *OfferMemoryRepository) FindAccepted(ctx context.Context, itemId uint, now time.Time) (*models.Offer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	offer, ok := r.store.acceptedOffer(itemId, now)
+	if !ok {
+		return nil, errors.New("Offer is not found")
+	}
+	return offer, nil
+}
+
+func (r *OfferMemoryRepository) Create(ctx context.Context, newOffer models.Offer, validate func(item *models.Item, offers []models.Offer) error) (*models.Offer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, err := r.store.findItem(newOffer.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	offers := r.store.offers.find(func(o *models.Offer) bool { return o.ItemId == item.ID })
+	if err := validate(item, offers); err != nil {
+		return nil, err
+	}
+	r.store.offers.create(&newOffer)
+	return &newOffer, nil
+}
+
+func (r *OfferMemoryRepository) Transition(ctx context.Context, offerId uint, apply func(offer *models.Offer, item *models.Item) error) (*models.Offer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	offer, ok := r.store.offers.first(func(o *models.Offer) bool { return o.ID == offerId })
+	if !ok {
+		return nil, errors.New("Offer is not found")
+	}
+	item, err := r.store.findItem(offer.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	// applyはコピーを書き換えるので、エラーを返した場合は何も保存されない（ロールバックと同じ）
+	if err := apply(offer, item); err != nil {
+		return nil, err
+	}
+	r.store.offers.save(offer)
+
+	// 合意したら同じ商品に対する他の交渉中のオファーは断る
+	if offer.Status == models.OfferStatusAccepted {
+		for _, v := range r.store.offers.find(func(o *models.Offer) bool {
+			return o.ItemId == offer.ItemId && o.ID != offer.ID && o.IsOpen()
+		}) {
+			v.Status = models.OfferStatusRejected
+			r.store.offers.save(&v)
+		}
+	}
+	return offer, nil
+}
+
+// findAcceptedOfferのメモリ版。行はID順なので、最後に合うものが一番新しい
+func (s *MemoryStore) acceptedOffer(itemId uint, now time.Time) (*models.Offer, bool) {
+	offers := s.offers.find(func(o *models.Offer) bool {
+		return o.ItemId == itemId && o.Status == models.OfferStatusAccepted && o.ExpiresAt.After(now)
+	})
+	if len(offers) == 0 {
+		return nil, false
+	}
+	return &offers[len(offers)-1], true
+}
@@ -0,0 +1,429 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 注文の状態遷移。書き換えた注文・商品と一緒に保存する仕訳を返す（なければnil）
+type OrderTransitionFunc func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error)
+
+// 注文の作成前の確認。ロック済みの商品・その商品に対する既存の注文・nowの時点で期限内のホールド（なければnil）・
+// 確保期限内の合意済みオファー（なければnil）が渡される。orderを書き換えるとその内容で保存される
+type OrderCreateFunc func(order *models.Order, item *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error
+
+type IOrderRepository interface {
+	// 配送情報（Shipmentとその履歴）も一緒に取得する
+	FindById(ctx context.Context, orderId uint) (*models.Order, error)
+	// 配達完了日時がbefore以前で、まだ取引完了していない注文
+	FindDeliveredBefore(ctx context.Context, before time.Time) (*[]models.Order, error)
+	// 作成日時がbefore以前で、まだ支払い待ちの注文
+	FindPendingBefore(ctx context.Context, before time.Time) (*[]models.Order, error)
+
+	// 商品をロックした上でvalidateを呼び、エラーがなければ注文を保存する。
+	// 同じトランザクションで、購入者のホールドは解放し、購入者との合意済みオファーはpurchasedにする
+	Create(ctx context.Context, newOrder models.Order, now time.Time, validate OrderCreateFunc) (*models.Order, error)
+	Update(ctx context.Context, updateOrder models.Order) (*models.Order, error)
+
+	// 注文と商品をロックした上でapplyを呼び、書き換えた内容と返された仕訳を同じトランザクションで保存する
+	Transition(ctx context.Context, orderId uint, apply OrderTransitionFunc) (*models.Order, error)
+
+	// Webhookイベントを記録し、支払いIDに紐づく注文へapplyを適用して、適用後の注文を返す。
+	// イベントの記録と注文の更新は同じトランザクションで行われ、
+	// 同じイベントが処理済みであればapplyは呼ばれずにduplicate=trueと現在の注文が返る
+	// （決済プロバイダへの依頼に失敗して再送されたときに、依頼をやり直せるようにするため）
+	ApplyPaymentEvent(ctx context.Context, event models.WebhookEvent, paymentIntentId string, apply OrderTransitionFunc) (order *models.Order, duplicate bool, err error)
+}
+
+type OrderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) IOrderRepository {
+	return &OrderRepository{db: db}
+}
+
+func (r *OrderRepository) FindById(ctx context.Context, orderId uint) (*models.Order, error) {
+	var order models.Order
+	result := r.db.WithContext(ctx).Preload("Shipment.Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("occurred_at, id")
+	}).First(&order, orderId)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Order is not found")
+		}
+		return nil, result.Error
+	}
+	return &order, nil
+}
+
+func (r *OrderRepository) FindDeliveredBefore(ctx context.Context, before time.Time) (*[]models.Order, error) {
+	var orders []models.Order
+	result := r.db.WithContext(ctx).Where("status = ? AND delivered_at <= ?", models.OrderStatusDelivered, before).Order("id").Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &orders, nil
+}
+
+func (r *OrderRepository) FindPendingBefore(ctx context.Context, before time.Time) (*[]models.Order, error) {
+	var orders []models.Order
+	result := r.db.WithContext(ctx).Where("status = ? AND created_at <= ?", models.OrderStatusPending, before).Order("id").Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &orders, nil
+}
+
+func (r *OrderRepository) Create(ctx context.Context, newOrder models.Order, now time.Time, validate OrderCreateFunc) (*models.Order, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 同じ商品が同時に購入されないよう、商品行をロックしてから確認する
+		// ホールドやオファーもロックの後に読むので、確認してから保存するまでの間に変わることはない
+		item, err := lockItem(tx, newOrder.ItemId)
+		if err != nil {
+			return err
+		}
+
+		var orders []models.Order
+		if err := tx.Where("item_id = ?", item.ID).Find(&orders).Error; err != nil {
+			return err
+		}
+		active, err := findActiveReservation(tx, item.ID, now)
+		if err != nil {
+			return err
+		}
+		accepted, err := findAcceptedOffer(tx, item.ID, now)
+		if err != nil {
+			return err
+		}
+
+		if err := validate(&newOrder, item, orders, active, accepted); err != nil {
+			return err
+		}
+		if err := tx.Create(&newOrder).Error; err != nil {
+			return err
+		}
+
+		// 購入に使ったホールドと合意は、ここで使い切ったことにする
+		if err := tx.Where("item_id = ? AND user_id = ?", item.ID, newOrder.BuyerId).Delete(&models.Reservation{}).Error; err != nil {
+			return err
+		}
+		if accepted != nil && accepted.BuyerId == newOrder.BuyerId {
+			return tx.Model(accepted).Update("status", models.OfferStatusPurchased).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &newOrder, nil
+}
+
+func (r *OrderRepository) Update(ctx context.Context, updateOrder models.Order) (*models.Order, error) {
+	result := r.db.WithContext(ctx).Save(&updateOrder)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &updateOrder, nil
+}
+
+func (r *OrderRepository) Transition(ctx context.Context, orderId uint, apply OrderTransitionFunc) (*models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&order, orderId).Error; err != nil {
+			if err.Error() == "record not found" {
+				return errors.New("Order is not found")
+			}
+			return err
+		}
+		return transitionOrder(tx, &order, apply)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *OrderRepository) ApplyPaymentEvent(ctx context.Context, event models.WebhookEvent, paymentIntentId string, apply OrderTransitionFunc) (*models.Order, bool, error) {
+	duplicate := false
+	var order models.Order
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 既に同じイベントが記録されていれば何もしない（INSERT ... ON CONFLICT DO NOTHING）
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+		if result.Error != nil {
+			return result.Error
+		}
+		duplicate = result.RowsAffected == 0
+
+		if err := tx.First(&order, "payment_intent_id = ?", paymentIntentId).Error; err != nil {
+			// エラーを返すとイベントの記録もロールバックされるので、プロバイダの再送で再処理される
+			if err.Error() == "record not found" {
+				return errors.New("Order is not found")
+			}
+			return err
+		}
+		if duplicate {
+			return nil
+		}
+		return transitionOrder(tx, &order, apply)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &order, duplicate, nil
+}
+
+// 商品を確保している注文（models.OpenOrderStatuses）を取得する
+func findOpenOrders(db *gorm.DB, itemId uint) ([]models.Order, error) {
+	var orders []models.Order
+	result := db.Where("item_id = ? AND status IN ?", itemId, models.OpenOrderStatuses).Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return orders, nil
+}
+
+// 商品→注文の順にロックを取り、applyした結果を保存する。トランザクション内で呼ぶこと
+// ロックの順番をオファーやホールドと揃えておくことでデッドロックを防ぐ
+func transitionOrder(tx *gorm.DB, order *models.Order, apply OrderTransitionFunc) error {
+	item, err := lockItem(tx, order.ItemId)
+	if err != nil {
+		return err
+	}
+	if err := forUpdate(tx).First(order, order.ID).Error; err != nil {
+		return err
+	}
+	before := *item
+
+	ledgerTransaction, err := apply(order, item)
+	if err != nil {
+		return err
+	}
+	// order.Shipmentがセットされていれば、配送情報も一緒に保存される
+	if err := tx.Save(order).Error; err != nil {
+		return err
+	}
+	// 売り切れになった・戻ったなど商品が変わったときは、バージョンを上げて古いETagでの更新を弾く
+	if *item != before {
+		item.Version++
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+	}
+	if ledgerTransaction != nil {
+		return saveLedgerTransaction(tx, ledgerTransaction)
+	}
+	return nil
+}
+
+type OrderMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewOrderMemoryRepository(store *MemoryStore) IOrderRepository {
+	return &OrderMemoryRepository{store: store}
+}
+
+func (r *OrderMemoryRepository) FindById(ctx context.Context, orderId uint) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	order, ok := r.store.orders.first(func(o *models.Order) bool { return o.ID == orderId })
+	if !ok {
+		return nil, errors.New("Order is not found")
+	}
+	// DBのPreloadと同じく、配送情報と履歴（発生日時順）を付ける
+	if shipment, ok := r.store.shipments.first(func(v *models.Shipment) bool { return v.OrderId == order.ID }); ok {
+		shipment.Events = r.store.shipmentEvents.find(func(e *models.ShipmentEvent) bool { return e.ShipmentId == shipment.ID })
+		sort.SliceStable(shipment.Events, func(i, j int) bool { return shipment.Events[i].OccurredAt.Before(shipment.Events[j].OccurredAt) })
+		order.Shipment = shipment
+	}
+	return order, nil
+}
+
+func (r *OrderMemoryRepository) FindDeliveredBefore(ctx context.Context, before time.Time) (*[]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	orders := r.store.orders.find(func(o *models.Order) bool {
+		return o.Status == models.OrderStatusDelivered && o.DeliveredAt != nil && !o.DeliveredAt.After(before)
+	})
+	return &orders, nil
+}
+
+func (r *OrderMemoryRepository) FindPendingBefore(ctx context.Context, before time.Time) (*[]models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	orders := r.store.orders.find(func(o *models.Order) bool {
+		return o.Status == models.OrderStatusPending && !o.CreatedAt.After(before)
+	})
+	return &orders, nil
+}
+
+func (r *OrderMemoryRepository) Create(ctx context.Context, newOrder models.Order, now time.Time, validate OrderCreateFunc) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, err := r.store.findItem(newOrder.ItemId)
+	if err != nil {
+		return nil, err
+	}
+	orders := r.store.orders.find(func(o *models.Order) bool { return o.ItemId == item.ID })
+	// 見つからなければnilのまま渡す
+	active, _ := r.store.activeReservation(item.ID, now)
+	accepted, _ := r.store.acceptedOffer(item.ID, now)
+	if err := validate(&newOrder, item, orders, active, accepted); err != nil {
+		return nil, err
+	}
+	if err := r.store.saveOrder(&newOrder); err != nil {
+		return nil, err
+	}
+
+	r.store.reservations.delete(func(v *models.Reservation) bool { return v.ItemId == item.ID && v.UserId == newOrder.BuyerId })
+	if accepted != nil && accepted.BuyerId == newOrder.BuyerId {
+		accepted.Status = models.OfferStatusPurchased
+		r.store.offers.save(accepted)
+	}
+	return &newOrder, nil
+}
+
+func (r *OrderMemoryRepository) Update(ctx context.Context, updateOrder models.Order) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if err := r.store.saveOrder(&updateOrder); err != nil {
+		return nil, err
+	}
+	return &updateOrder, nil
+}
+
+func (r *OrderMemoryRepository) Transition(ctx context.Context, orderId uint, apply OrderTransitionFunc) (*models.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	order, ok := r.store.orders.first(func(o *models.Order) bool { return o.ID == orderId })
+	if !ok {
+		return nil, errors.New("Order is not found")
+	}
+	if err := r.store.transitionOrder(order, apply); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *OrderMemoryRepository) ApplyPaymentEvent(ctx context.Context, event models.WebhookEvent, paymentIntentId string, apply OrderTransitionFunc) (*models.Order, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	duplicate := r.store.webhookEvents.exists(func(e *models.WebhookEvent) bool {
+		return e.Provider == event.Provider && e.EventId == event.EventId
+	})
+	order, ok := r.store.orders.first(func(o *models.Order) bool { return o.PaymentIntentId == paymentIntentId })
+	if !ok {
+		return nil, false, errors.New("Order is not found")
+	}
+	if duplicate {
+		return order, true, nil
+	}
+	if err := r.store.transitionOrder(order, apply); err != nil {
+		return nil, false, err
+	}
+	// DBではイベントの記録と注文の更新が同じトランザクションなので、注文の更新に失敗したらイベントも記録しない
+	r.store.webhookEvents.create(&event)
+	return order, false, nil
+}
+
+// findOpenOrdersのメモリ版
+func (s *MemoryStore) openOrders(itemId uint) []models.Order {
+	return s.orders.find(func(o *models.Order) bool {
+		return o.ItemId == itemId && o.IsOpen()
+	})
+}
+
+// DBのtransitionOrderと同じ。ロックは呼び出し元がstore.muで取っておくこと
+// applyやその後の確認でエラーになった場合は何も保存しない
+func (s *MemoryStore) transitionOrder(order *models.Order, apply OrderTransitionFunc) error {
+	item, err := s.findItem(order.ItemId)
+	if err != nil {
+		return err
+	}
+	before := *item
+
+	ledgerTransaction, err := apply(order, item)
+	if err != nil {
+		return err
+	}
+	if err := s.saveOrder(order); err != nil {
+		return err
+	}
+	if *item != before {
+		item.Version++
+		s.items.save(item)
+	}
+	if ledgerTransaction != nil {
+		s.saveLedgerTransaction(ledgerTransaction)
+	}
+	return nil
+}
+
+// 注文を保存する。gormのSaveと同じく、Shipmentが付いていれば配送情報と履歴も一緒に保存する
+// 配送情報の一意制約（注文ごとに1件、運送会社と追跡番号の組み合わせ）に違反する場合は何も保存せずにエラーを返す
+func (s *MemoryStore) saveOrder(order *models.Order) error {
+	shipment := order.Shipment
+	if shipment != nil && shipment.ID == 0 {
+		if s.shipments.exists(func(v *models.Shipment) bool {
+			return v.OrderId == order.ID ||
+				(v.Carrier == shipment.Carrier && v.TrackingNumber == shipment.TrackingNumber)
+		}) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	stored := *order
+	stored.Shipment = nil
+	s.orders.save(&stored)
+	order.Model = stored.Model
+	if shipment == nil {
+		return nil
+	}
+
+	shipment.OrderId = order.ID
+	events := shipment.Events
+	storedShipment := *shipment
+	storedShipment.Events = nil
+	s.shipments.save(&storedShipment)
+	shipment.Model = storedShipment.Model
+	for i := range events {
+		events[i].ShipmentId = shipment.ID
+		s.shipmentEvents.save(&events[i])
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// IAddressRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestAddressRepository(t *testing.T, newRepo func(t *testing.T) repositories.IAddressRepository) {
+	ctx := context.Background()
+
+	t.Run("the first address becomes the default", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Create(ctx, newAddress(1, "home", false))
+		assertNoError(t, err)
+		second, err := repo.Create(ctx, newAddress(1, "office", false))
+		assertNoError(t, err)
+		if !first.IsDefault || second.IsDefault {
+			t.Fatalf("IsDefault = (%v, %v), want (true, false)", first.IsDefault, second.IsDefault)
+		}
+
+		found, err := repo.FindDefault(ctx, 1)
+		assertNoError(t, err)
+		if found.ID != first.ID {
+			t.Fatalf("FindDefault = %d, want %d", found.ID, first.ID)
+		}
+	})
+
+	t.Run("Create with IsDefault moves the default", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Create(ctx, newAddress(1, "home", false))
+		assertNoError(t, err)
+		second, err := repo.Create(ctx, newAddress(1, "office", true))
+		assertNoError(t, err)
+
+		assertDefaultAddress(t, repo, 1, second.ID)
+		found, err := repo.FindById(ctx, first.ID, 1)
+		assertNoError(t, err)
+		if found.IsDefault {
+			t.Fatal("the previous default is still the default")
+		}
+	})
+
+	t.Run("Update with IsDefault moves the default", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Create(ctx, newAddress(1, "home", false))
+		assertNoError(t, err)
+		second, err := repo.Create(ctx, newAddress(1, "office", false))
+		assertNoError(t, err)
+
+		second.IsDefault = true
+		second.City = "Minato"
+		_, err = repo.Update(ctx, *second)
+		assertNoError(t, err)
+
+		assertDefaultAddress(t, repo, 1, second.ID)
+		found, err := repo.FindById(ctx, second.ID, 1)
+		assertNoError(t, err)
+		if found.City != "Minato" {
+			t.Fatalf("City = %q, want Minato", found.City)
+		}
+	})
+
+	t.Run("FindAll returns the default first, then in creation order", func(t *testing.T) {
+		repo := newRepo(t)
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := repo.Create(ctx, newAddress(1, name, name == "c"))
+			assertNoError(t, err)
+		}
+		_, err := repo.Create(ctx, newAddress(2, "other", false))
+		assertNoError(t, err)
+
+		addresses, err := repo.FindAll(ctx, 1)
+		assertNoError(t, err)
+		names := []string{}
+		for _, v := range *addresses {
+			names = append(names, v.Name)
+		}
+		if !equalStrings(names, []string{"c", "a", "b"}) {
+			t.Fatalf("FindAll names = %v, want [c a b]", names)
+		}
+	})
+
+	t.Run("another user's address is not found", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newAddress(1, "home", false))
+		assertNoError(t, err)
+
+		_, err = repo.FindById(ctx, created.ID, 2)
+		assertErrorMessage(t, err, "Address is not found")
+		_, err = repo.FindDefault(ctx, 2)
+		assertErrorMessage(t, err, "Address is not found")
+		assertErrorMessage(t, repo.Delete(ctx, created.ID, 2), "Address is not found")
+	})
+
+	t.Run("deleting the default makes the newest remaining address the default", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Create(ctx, newAddress(1, "a", false))
+		assertNoError(t, err)
+		_, err = repo.Create(ctx, newAddress(1, "b", false))
+		assertNoError(t, err)
+		third, err := repo.Create(ctx, newAddress(1, "c", false))
+		assertNoError(t, err)
+
+		assertNoError(t, repo.Delete(ctx, first.ID, 1))
+		assertDefaultAddress(t, repo, 1, third.ID)
+		_, err = repo.FindById(ctx, first.ID, 1)
+		assertErrorMessage(t, err, "Address is not found")
+	})
+}
+
+func newAddress(userId uint, name string, isDefault bool) models.Address {
+	return models.Address{
+		UserId: userId,
+		ShippingAddress: models.ShippingAddress{
+			Name: name, PostalCode: "1000001", Prefecture: "Tokyo", City: "Chiyoda", Line1: "1-1", Phone: "0312345678",
+		},
+		IsDefault: isDefault,
+	}
+}
+
+// デフォルトの住所がちょうど1件で、wantIdであることを確認する
+func assertDefaultAddress(t *testing.T, repo repositories.IAddressRepository, userId uint, wantId uint) {
+	t.Helper()
+	addresses, err := repo.FindAll(context.Background(), userId)
+	assertNoError(t, err)
+	defaults := []uint{}
+	for _, v := range *addresses {
+		if v.IsDefault {
+			defaults = append(defaults, v.ID)
+		}
+	}
+	if len(defaults) != 1 || defaults[0] != wantId {
+		t.Fatalf("default addresses = %v, want [%d]", defaults, wantId)
+	}
+}
@@ -0,0 +1,64 @@
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// IAuthRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestAuthRepository(t *testing.T, newRepo func(t *testing.T) repositories.IAuthRepository) {
+	ctx := context.Background()
+
+	t.Run("CreateUser then FindUser returns the user", func(t *testing.T) {
+		repo := newRepo(t)
+		assertNoError(t, repo.CreateUser(ctx, models.User{Email: "alice@example.com", Password: "hashed"}))
+
+		found, err := repo.FindUser(ctx, "alice@example.com")
+		assertNoError(t, err)
+		if found.ID == 0 {
+			t.Error("found.ID = 0, want an assigned ID")
+		}
+		if found.Email != "alice@example.com" || found.Password != "hashed" {
+			t.Fatalf("user = {Email: %q, Password: %q}, want {alice@example.com hashed}", found.Email, found.Password)
+		}
+	})
+
+	t.Run("FindUser returns User not found for an unknown email", func(t *testing.T) {
+		repo := newRepo(t)
+		assertNoError(t, repo.CreateUser(ctx, models.User{Email: "alice@example.com", Password: "hashed"}))
+
+		_, err := repo.FindUser(ctx, "bob@example.com")
+		assertErrorMessage(t, err, "User not found")
+	})
+
+	t.Run("CreateUser rejects a registered email", func(t *testing.T) {
+		repo := newRepo(t)
+		assertNoError(t, repo.CreateUser(ctx, models.User{Email: "alice@example.com", Password: "first"}))
+
+		err := repo.CreateUser(ctx, models.User{Email: "alice@example.com", Password: "second"})
+		assertErrorMessage(t, err, "Email is already registered")
+
+		// 最初に登録したユーザーはそのまま残る
+		found, err := repo.FindUser(ctx, "alice@example.com")
+		assertNoError(t, err)
+		if found.Password != "first" {
+			t.Fatalf("Password = %q, want %q", found.Password, "first")
+		}
+	})
+
+	t.Run("users get distinct IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		assertNoError(t, repo.CreateUser(ctx, models.User{Email: "alice@example.com", Password: "hashed"}))
+		assertNoError(t, repo.CreateUser(ctx, models.User{Email: "bob@example.com", Password: "hashed"}))
+
+		alice, err := repo.FindUser(ctx, "alice@example.com")
+		assertNoError(t, err)
+		bob, err := repo.FindUser(ctx, "bob@example.com")
+		assertNoError(t, err)
+		if alice.ID == bob.ID {
+			t.Fatalf("alice.ID = bob.ID = %d, want distinct IDs", alice.ID)
+		}
+	})
+}
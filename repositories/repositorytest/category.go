@@ -0,0 +1,58 @@
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// ICategoryRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestCategoryRepository(t *testing.T, newRepo func(t *testing.T) repositories.ICategoryRepository) {
+	ctx := context.Background()
+
+	t.Run("Create then FindById and FindAll return the category", func(t *testing.T) {
+		repo := newRepo(t)
+		books, err := repo.Create(ctx, models.Category{Name: "本"})
+		assertNoError(t, err)
+		games, err := repo.Create(ctx, models.Category{Name: "ゲーム"})
+		assertNoError(t, err)
+		if books.ID == 0 || books.ID == games.ID {
+			t.Fatalf("IDs = %d, %d, want distinct assigned IDs", books.ID, games.ID)
+		}
+
+		found, err := repo.FindById(ctx, books.ID)
+		assertNoError(t, err)
+		if found.Name != "本" {
+			t.Fatalf("Name = %q, want %q", found.Name, "本")
+		}
+
+		// 登録順に返す
+		all, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if len(*all) != 2 || (*all)[0].ID != books.ID || (*all)[1].ID != games.ID {
+			t.Fatalf("FindAll = %+v, want [本 ゲーム]", *all)
+		}
+	})
+
+	t.Run("FindById returns Category is not found for an unknown ID", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.FindById(ctx, 99)
+		assertErrorMessage(t, err, "Category is not found")
+	})
+
+	t.Run("Create rejects a duplicated name", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Create(ctx, models.Category{Name: "本"})
+		assertNoError(t, err)
+
+		_, err = repo.Create(ctx, models.Category{Name: "本"})
+		assertErrorMessage(t, err, "Category already exists")
+
+		all, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if len(*all) != 1 {
+			t.Fatalf("len(FindAll) = %d, want 1", len(*all))
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"sync"
+	"testing"
+	"time"
+)
+
+// IIdempotencyKeyRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestIdempotencyKeyRepository(t *testing.T, newRepo func(t *testing.T) repositories.IIdempotencyKeyRepository) {
+	ctx := context.Background()
+	newKey := func(userId uint, key string, fingerprint string) models.IdempotencyKey {
+		return models.IdempotencyKey{UserId: userId, Key: key, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(time.Hour), LockedUntil: time.Now().Add(time.Minute)}
+	}
+	// 処理の途中で止まり、持ち主の期限が過ぎたまま残っている記録
+	stale := func(userId uint, key string, fingerprint string) models.IdempotencyKey {
+		k := newKey(userId, key, fingerprint)
+		k.LockedUntil = time.Now().Add(-time.Second)
+		return k
+	}
+
+	t.Run("Reserve creates a new key once", func(t *testing.T) {
+		repo := newRepo(t)
+		first, created, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		if !created || first.ID == 0 || first.StatusCode != 0 {
+			t.Fatalf("Reserve = (%+v, %v), want a new in-flight key", first, created)
+		}
+
+		// 2回目は作らずに最初の記録を返す
+		second, created, err := repo.Reserve(ctx, newKey(1, "key-1", "b"))
+		assertNoError(t, err)
+		if created || second.ID != first.ID || second.Fingerprint != "a" {
+			t.Fatalf("Reserve = (%+v, %v), want the first key", second, created)
+		}
+	})
+
+	t.Run("keys are scoped per user", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+
+		_, created, err := repo.Reserve(ctx, newKey(2, "key-1", "a"))
+		assertNoError(t, err)
+		if !created {
+			t.Fatal("created = false, want another user's key to be independent")
+		}
+	})
+
+	t.Run("SaveResponse stores the response", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		assertNoError(t, repo.SaveResponse(ctx, 1, "key-1", 201, `{"Content-Type":["application/json"]}`, []byte(`{"data":{}}`)))
+
+		found, created, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		if created || found.StatusCode != 201 || found.ResponseHeader != `{"Content-Type":["application/json"]}` || string(found.ResponseBody) != `{"data":{}}` {
+			t.Fatalf("key = %+v, want the saved response", found)
+		}
+	})
+
+	t.Run("SaveResponse returns Idempotency key is not found for an unknown key", func(t *testing.T) {
+		repo := newRepo(t)
+		err := repo.SaveResponse(ctx, 1, "unknown", 201, "", nil)
+		assertErrorMessage(t, err, "Idempotency key is not found")
+	})
+
+	t.Run("Delete allows the key to be reused", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		assertNoError(t, repo.Delete(ctx, 1, "key-1"))
+
+		_, created, err := repo.Reserve(ctx, newKey(1, "key-1", "b"))
+		assertNoError(t, err)
+		if !created {
+			t.Fatal("created = false, want the deleted key to be created again")
+		}
+	})
+
+	t.Run("expired keys are replaced and swept", func(t *testing.T) {
+		repo := newRepo(t)
+		expired := newKey(1, "key-1", "a")
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		_, _, err := repo.Reserve(ctx, expired)
+		assertNoError(t, err)
+
+		// 掃除される前でも、期限切れのキーは使い直せる
+		_, created, err := repo.Reserve(ctx, newKey(1, "key-1", "b"))
+		assertNoError(t, err)
+		if !created {
+			t.Fatal("created = false, want an expired key to be replaced")
+		}
+
+		old := newKey(1, "key-2", "a")
+		old.ExpiresAt = time.Now().Add(-time.Minute)
+		_, _, err = repo.Reserve(ctx, old)
+		assertNoError(t, err)
+		deleted, err := repo.DeleteExpired(ctx, time.Now())
+		assertNoError(t, err)
+		if deleted != 1 {
+			t.Fatalf("DeleteExpired = %d, want 1", deleted)
+		}
+	})
+
+	t.Run("an in-flight key is taken over once its lease has passed", func(t *testing.T) {
+		repo := newRepo(t)
+		first, _, err := repo.Reserve(ctx, stale(1, "key-1", "a"))
+		assertNoError(t, err)
+
+		retry := newKey(1, "key-1", "a")
+		taken, created, err := repo.Reserve(ctx, retry)
+		assertNoError(t, err)
+		if !created || taken.ID != first.ID || taken.StatusCode != 0 {
+			t.Fatalf("Reserve = (%+v, %v), want the stale key to be taken over", taken, created)
+		}
+		if taken.LockedUntil.Before(retry.LockedUntil.Add(-time.Second)) {
+			t.Fatalf("LockedUntil = %v, want the new lease %v", taken.LockedUntil, retry.LockedUntil)
+		}
+
+		// 引き継いだ後は新しい期限の中なので、さらに引き継がれることはない
+		_, created, err = repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		if created {
+			t.Fatal("created = true, want the renewed lease to be kept")
+		}
+	})
+
+	t.Run("an in-flight key within its lease is not taken over", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+
+		found, created, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+		assertNoError(t, err)
+		if created || found.StatusCode != 0 {
+			t.Fatalf("Reserve = (%+v, %v), want the in-flight key without taking it over", found, created)
+		}
+	})
+
+	t.Run("a stale key is not taken over by a different request or after it completes", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, stale(1, "key-1", "a"))
+		assertNoError(t, err)
+		found, created, err := repo.Reserve(ctx, newKey(1, "key-1", "b"))
+		assertNoError(t, err)
+		if created || found.Fingerprint != "a" {
+			t.Fatalf("Reserve = (%+v, %v), want the original key for a different fingerprint", found, created)
+		}
+
+		_, _, err = repo.Reserve(ctx, stale(1, "key-2", "a"))
+		assertNoError(t, err)
+		assertNoError(t, repo.SaveResponse(ctx, 1, "key-2", 201, "", []byte(`{"data":{}}`)))
+		found, created, err = repo.Reserve(ctx, newKey(1, "key-2", "a"))
+		assertNoError(t, err)
+		if created || found.StatusCode != 201 {
+			t.Fatalf("Reserve = (%+v, %v), want the saved response", found, created)
+		}
+	})
+
+	t.Run("concurrent Reserve takes over a stale key exactly once", func(t *testing.T) {
+		repo := newRepo(t)
+		_, _, err := repo.Reserve(ctx, stale(1, "key-1", "a"))
+		assertNoError(t, err)
+
+		const n = 8
+		var wg sync.WaitGroup
+		results := make(chan bool, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, created, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+				if err != nil {
+					t.Errorf("Reserve: %v", err)
+					return
+				}
+				results <- created
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		count := 0
+		for created := range results {
+			if created {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Fatalf("%d of %d calls took over the key, want exactly 1", count, n)
+		}
+	})
+
+	t.Run("concurrent Reserve creates the key exactly once", func(t *testing.T) {
+		repo := newRepo(t)
+		const n = 8
+		var wg sync.WaitGroup
+		results := make(chan bool, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, created, err := repo.Reserve(ctx, newKey(1, "key-1", "a"))
+				if err != nil {
+					t.Errorf("Reserve: %v", err)
+					return
+				}
+				results <- created
+			}()
+		}
+		wg.Wait()
+		close(results)
+
+		count := 0
+		for created := range results {
+			if created {
+				count++
+			}
+		}
+		if count != 1 {
+			t.Fatalf("%d of %d calls created the key, want exactly 1", count, n)
+		}
+	})
+}
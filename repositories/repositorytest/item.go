@@ -0,0 +1,345 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"sort"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// IItemRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestItemRepository(t *testing.T, newRepo func(t *testing.T) repositories.IItemRepository) {
+	ctx := context.Background()
+
+	t.Run("Create assigns an ID and FindById returns the same values", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("book"))
+		assertNoError(t, err)
+		if created.ID == 0 {
+			t.Fatal("created.ID = 0, want an assigned ID")
+		}
+		if created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+			t.Error("CreatedAt/UpdatedAt are not set")
+		}
+
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		assertSameItem(t, found, created)
+	})
+
+	t.Run("FindById returns Item is not found for an unknown ID", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.FindById(ctx, 999)
+		assertErrorMessage(t, err, "Item is not found")
+	})
+
+	t.Run("FindAll returns every item", func(t *testing.T) {
+		repo := newRepo(t)
+		items, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if len(*items) != 0 {
+			t.Fatalf("len(FindAll) = %d on an empty repository, want 0", len(*items))
+		}
+
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := repo.Create(ctx, newItem(name))
+			assertNoError(t, err)
+		}
+		items, err = repo.FindAll(ctx)
+		assertNoError(t, err)
+		if got := itemNames(*items); !equalStrings(got, []string{"a", "b", "c"}) {
+			t.Fatalf("FindAll names = %v, want [a b c]", got)
+		}
+	})
+
+	t.Run("Create starts at version 1", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("book"))
+		assertNoError(t, err)
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if created.Version != 1 || found.Version != 1 {
+			t.Fatalf("Version = %d (found %d), want 1", created.Version, found.Version)
+		}
+	})
+
+	t.Run("Update writes only the given fields and bumps the version", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("before"))
+		assertNoError(t, err)
+
+		changed := *created
+		changed.Name = "after"
+		changed.Price = 2500
+		changed.SoldOut = true
+		// fieldsに含まれないDescriptionの変更は保存されない
+		changed.Description = "ignored"
+		updated, err := repo.Update(ctx, changed, []string{"Name", "Price", "SoldOut"})
+		assertNoError(t, err)
+
+		want := *created
+		want.Name, want.Price, want.SoldOut = "after", 2500, true
+		assertSameItem(t, updated, &want)
+		if updated.Version != created.Version+1 {
+			t.Fatalf("updated.Version = %d, want %d", updated.Version, created.Version+1)
+		}
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		assertSameItem(t, found, &want)
+		if found.Version != updated.Version {
+			t.Fatalf("found.Version = %d, want %d", found.Version, updated.Version)
+		}
+	})
+
+	t.Run("Update can set zero values", func(t *testing.T) {
+		repo := newRepo(t)
+		item := newItem("zero")
+		item.SoldOut = true
+		item.ShippingFee = 300
+		created, err := repo.Create(ctx, item)
+		assertNoError(t, err)
+
+		changed := *created
+		changed.SoldOut = false
+		changed.ShippingFee = 0
+		changed.Description = ""
+		_, err = repo.Update(ctx, changed, []string{"SoldOut", "ShippingFee", "Description"})
+		assertNoError(t, err)
+
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if found.SoldOut || found.ShippingFee != 0 || found.Description != "" {
+			t.Fatalf("item = %+v, want zero SoldOut, ShippingFee and Description", *found)
+		}
+	})
+
+	t.Run("Update with a stale version returns Item has been modified", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("shared"))
+		assertNoError(t, err)
+
+		first := *created
+		first.Price = 1000
+		_, err = repo.Update(ctx, first, []string{"Price"})
+		assertNoError(t, err)
+
+		// 同じバージョンをもとにした2つ目の更新は、1つ目を上書きしない
+		second := *created
+		second.Name = "overwritten"
+		_, err = repo.Update(ctx, second, []string{"Name"})
+		assertErrorMessage(t, err, "Item has been modified")
+
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if found.Name != "shared" || found.Price != 1000 || found.Version != created.Version+1 {
+			t.Fatalf("item = %+v, want only the first update applied", *found)
+		}
+	})
+
+	t.Run("Update does not bring back a deleted item", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Update(ctx, models.Item{Model: gorm.Model{ID: 999}, Name: "ghost", Version: 1}, []string{"Name"})
+		assertErrorMessage(t, err, "Item is not found")
+
+		created, err := repo.Create(ctx, newItem("deleted"))
+		assertNoError(t, err)
+		assertNoError(t, repo.Delete(ctx, created.ID))
+
+		changed := *created
+		changed.Name = "revived"
+		_, err = repo.Update(ctx, changed, []string{"Name"})
+		assertErrorMessage(t, err, "Item is not found")
+		_, err = repo.FindById(ctx, created.ID)
+		assertErrorMessage(t, err, "Item is not found")
+	})
+
+	t.Run("concurrent Update with the same version succeeds only once", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("race"))
+		assertNoError(t, err)
+
+		const n = 10
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				changed := *created
+				changed.Price = uint(2000 + i)
+				_, errs[i] = repo.Update(ctx, changed, []string{"Price"})
+			}(i)
+		}
+		wg.Wait()
+
+		succeeded := 0
+		for _, err := range errs {
+			if err == nil {
+				succeeded++
+				continue
+			}
+			assertErrorMessage(t, err, "Item has been modified")
+		}
+		if succeeded != 1 {
+			t.Fatalf("%d updates succeeded, want exactly 1", succeeded)
+		}
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if found.Version != created.Version+1 {
+			t.Fatalf("Version = %d, want %d", found.Version, created.Version+1)
+		}
+	})
+
+	t.Run("Delete hides the item and its ID is never reused", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Create(ctx, newItem("first"))
+		assertNoError(t, err)
+		second, err := repo.Create(ctx, newItem("second"))
+		assertNoError(t, err)
+
+		assertNoError(t, repo.Delete(ctx, second.ID))
+		_, err = repo.FindById(ctx, second.ID)
+		assertErrorMessage(t, err, "Item is not found")
+		items, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if got := itemNames(*items); !equalStrings(got, []string{"first"}) {
+			t.Fatalf("FindAll names after delete = %v, want [first]", got)
+		}
+
+		// 削除済みのIDを振り直すと、別の商品として見えてしまう
+		third, err := repo.Create(ctx, newItem("third"))
+		assertNoError(t, err)
+		if third.ID == first.ID || third.ID == second.ID {
+			t.Fatalf("third.ID = %d reuses an existing ID (first=%d, second=%d)", third.ID, first.ID, second.ID)
+		}
+	})
+
+	t.Run("Delete returns Item is not found for an unknown or deleted ID", func(t *testing.T) {
+		repo := newRepo(t)
+		assertErrorMessage(t, repo.Delete(ctx, 999), "Item is not found")
+
+		created, err := repo.Create(ctx, newItem("once"))
+		assertNoError(t, err)
+		assertNoError(t, repo.Delete(ctx, created.ID))
+		assertErrorMessage(t, repo.Delete(ctx, created.ID), "Item is not found")
+	})
+
+	t.Run("returned items are copies", func(t *testing.T) {
+		repo := newRepo(t)
+		created, err := repo.Create(ctx, newItem("original"))
+		assertNoError(t, err)
+
+		found, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		found.Name = "changed"
+		items, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		(*items)[0].Name = "changed"
+
+		again, err := repo.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if again.Name != "original" {
+			t.Fatalf("Name = %q after modifying returned values, want %q", again.Name, "original")
+		}
+	})
+
+	t.Run("concurrent Create assigns unique IDs", func(t *testing.T) {
+		repo := newRepo(t)
+		const n = 20
+		ids := make([]uint, n)
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				created, err := repo.Create(ctx, newItem("concurrent"))
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				ids[i] = created.ID
+			}(i)
+		}
+		wg.Wait()
+		assertNoError(t, errors.Join(errs...))
+
+		seen := map[uint]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("ID %d was assigned twice", id)
+			}
+			seen[id] = true
+		}
+		items, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if len(*items) != n {
+			t.Fatalf("len(FindAll) = %d, want %d", len(*items), n)
+		}
+	})
+
+	t.Run("canceled context is not processed", func(t *testing.T) {
+		repo := newRepo(t)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if _, err := repo.Create(canceled, newItem("canceled")); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Create error = %v, want context.Canceled", err)
+		}
+		items, err := repo.FindAll(ctx)
+		assertNoError(t, err)
+		if len(*items) != 0 {
+			t.Fatalf("len(FindAll) = %d, want 0 (the canceled Create must not be saved)", len(*items))
+		}
+	})
+}
+
+func newItem(name string) models.Item {
+	return models.Item{
+		Name:            name,
+		Price:           1200,
+		Description:     name + " description",
+		UserId:          1,
+		ShippingMethod:  "yu-packet",
+		ShippingPayer:   models.ShippingPayerSeller,
+		ShipsWithinDays: 3,
+	}
+}
+
+// 日時はDBによって精度が違うので比較しない
+func assertSameItem(t *testing.T, got *models.Item, want *models.Item) {
+	t.Helper()
+	if got.ID != want.ID || got.Name != want.Name || got.Price != want.Price ||
+		got.Description != want.Description || got.SoldOut != want.SoldOut || got.UserId != want.UserId ||
+		got.ShippingMethod != want.ShippingMethod || got.ShippingPayer != want.ShippingPayer ||
+		got.ShippingFee != want.ShippingFee || got.ShipsWithinDays != want.ShipsWithinDays {
+		t.Fatalf("item = %+v, want %+v", *got, *want)
+	}
+}
+
+// 並び順は実装によって違ってよいので、名前を並べ替えて比較する
+func itemNames(items []models.Item) []string {
+	names := make([]string, 0, len(items))
+	for _, v := range items {
+		names = append(names, v.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
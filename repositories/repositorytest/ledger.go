@@ -0,0 +1,83 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// ILedgerRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestLedgerRepository(t *testing.T, newRepo func(t *testing.T) repositories.ILedgerRepository) {
+	ctx := context.Background()
+	const account = "seller:1:available"
+
+	t.Run("Balance of an unknown account is 0", func(t *testing.T) {
+		repo := newRepo(t)
+		balance, err := repo.Balance(ctx, account)
+		assertNoError(t, err)
+		if balance != 0 {
+			t.Fatalf("Balance = %d, want 0", balance)
+		}
+	})
+
+	t.Run("CreatePayout passes the current balance and saves the transaction", func(t *testing.T) {
+		repo := newRepo(t)
+		// 残高を作るため、最初の仕訳では勘定に入金する
+		_, err := repo.CreatePayout(ctx, models.Payout{UserId: 1}, account, func(balance int64) (*models.LedgerTransaction, error) {
+			if balance != 0 {
+				t.Errorf("balance = %d, want 0", balance)
+			}
+			return transfer("platform:cash", account, 1000), nil
+		})
+		assertNoError(t, err)
+
+		payout, err := repo.CreatePayout(ctx, models.Payout{UserId: 1, Amount: 400}, account, func(balance int64) (*models.LedgerTransaction, error) {
+			if balance != 1000 {
+				t.Errorf("balance = %d, want 1000", balance)
+			}
+			return transfer(account, "platform:payouts", 400), nil
+		})
+		assertNoError(t, err)
+		if payout.ID == 0 || payout.TransactionId == 0 {
+			t.Fatalf("payout = %+v, want assigned IDs", payout)
+		}
+
+		assertBalance(t, repo, account, 600)
+		assertBalance(t, repo, "platform:payouts", 400)
+		assertBalance(t, repo, "platform:cash", -1000)
+	})
+
+	t.Run("CreatePayout saves nothing when build fails", func(t *testing.T) {
+		repo := newRepo(t)
+		buildErr := errors.New("insufficient balance")
+		_, err := repo.CreatePayout(ctx, models.Payout{UserId: 1, Amount: 400}, account, func(balance int64) (*models.LedgerTransaction, error) {
+			return nil, buildErr
+		})
+		if !errors.Is(err, buildErr) {
+			t.Fatalf("err = %v, want the build error", err)
+		}
+		assertBalance(t, repo, account, 0)
+	})
+}
+
+// fromからtoへamountを移す仕訳
+func transfer(from string, to string, amount uint) *models.LedgerTransaction {
+	return &models.LedgerTransaction{
+		Reference: "test",
+		Entries: []models.LedgerEntry{
+			{Account: models.LedgerAccount{Name: from}, Direction: models.LedgerDebit, Amount: amount},
+			{Account: models.LedgerAccount{Name: to}, Direction: models.LedgerCredit, Amount: amount},
+		},
+	}
+}
+
+func assertBalance(t *testing.T, repo repositories.ILedgerRepository, account string, want int64) {
+	t.Helper()
+	balance, err := repo.Balance(context.Background(), account)
+	assertNoError(t, err)
+	if balance != want {
+		t.Fatalf("Balance(%s) = %d, want %d", account, balance, want)
+	}
+}
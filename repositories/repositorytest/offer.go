@@ -0,0 +1,152 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"testing"
+	"time"
+)
+
+// IOfferRepositoryの契約テスト。newReposはサブテストごとに呼ばれ、空の状態のリポジトリ一式を返すこと
+func TestOfferRepository(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("Create passes the item and its offers to validate", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		first := createOffer(t, repos, item.ID, 2, 800)
+
+		_, err := repos.Offer.Create(ctx, newOffer(item.ID, 3, 900), func(locked *models.Item, offers []models.Offer) error {
+			if locked.ID != item.ID {
+				t.Errorf("item = %d, want %d", locked.ID, item.ID)
+			}
+			if len(offers) != 1 || offers[0].ID != first.ID {
+				t.Errorf("offers = %+v, want [%d]", offers, first.ID)
+			}
+			return nil
+		})
+		assertNoError(t, err)
+
+		offers, err := repos.Offer.FindByItem(ctx, item.ID)
+		assertNoError(t, err)
+		if len(*offers) != 2 || (*offers)[0].ID != first.ID {
+			t.Fatalf("FindByItem = %+v, want 2 offers in creation order", *offers)
+		}
+	})
+
+	t.Run("Create saves nothing when validate fails", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		validateErr := errors.New("invalid")
+		_, err := repos.Offer.Create(ctx, newOffer(item.ID, 2, 800), func(*models.Item, []models.Offer) error { return validateErr })
+		if !errors.Is(err, validateErr) {
+			t.Fatalf("err = %v, want the validate error", err)
+		}
+
+		offers, err := repos.Offer.FindByItem(ctx, item.ID)
+		assertNoError(t, err)
+		if len(*offers) != 0 {
+			t.Fatalf("len(FindByItem) = %d, want 0", len(*offers))
+		}
+	})
+
+	t.Run("Create returns Item is not found for an unknown item", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Offer.Create(ctx, newOffer(999, 2, 800), func(*models.Item, []models.Offer) error { return nil })
+		assertErrorMessage(t, err, "Item is not found")
+	})
+
+	t.Run("FindById returns Offer is not found for an unknown ID", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Offer.FindById(ctx, 999)
+		assertErrorMessage(t, err, "Offer is not found")
+		_, err = repos.Offer.Transition(ctx, 999, func(*models.Offer, *models.Item) error { return nil })
+		assertErrorMessage(t, err, "Offer is not found")
+	})
+
+	t.Run("accepting an offer rejects the other open offers on the item", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		other := createItem(t, repos)
+		accepted := createOffer(t, repos, item.ID, 2, 800)
+		open := createOffer(t, repos, item.ID, 3, 900)
+		elsewhere := createOffer(t, repos, other.ID, 3, 900)
+
+		acceptOffer(t, repos, accepted.ID, time.Now().Add(time.Hour))
+
+		assertOfferStatus(t, repos, accepted.ID, models.OfferStatusAccepted)
+		assertOfferStatus(t, repos, open.ID, models.OfferStatusRejected)
+		assertOfferStatus(t, repos, elsewhere.ID, models.OfferStatusPending)
+	})
+
+	t.Run("Transition saves nothing when apply fails", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		offer := createOffer(t, repos, item.ID, 2, 800)
+		applyErr := errors.New("invalid")
+
+		_, err := repos.Offer.Transition(ctx, offer.ID, func(offer *models.Offer, item *models.Item) error {
+			offer.Status = models.OfferStatusRejected
+			return applyErr
+		})
+		if !errors.Is(err, applyErr) {
+			t.Fatalf("err = %v, want the apply error", err)
+		}
+		assertOfferStatus(t, repos, offer.ID, models.OfferStatusPending)
+	})
+
+	t.Run("FindAccepted returns the newest live acceptance", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		_, err := repos.Offer.FindAccepted(ctx, item.ID, time.Now())
+		assertErrorMessage(t, err, "Offer is not found")
+
+		// 期限切れの合意の後に、別の購入希望者と合意し直した
+		expired := createOffer(t, repos, item.ID, 2, 800)
+		acceptOffer(t, repos, expired.ID, time.Now().Add(-time.Minute))
+		live := createOffer(t, repos, item.ID, 3, 900)
+		acceptOffer(t, repos, live.ID, time.Now().Add(time.Hour))
+
+		found, err := repos.Offer.FindAccepted(ctx, item.ID, time.Now())
+		assertNoError(t, err)
+		if found.ID != live.ID {
+			t.Fatalf("FindAccepted = %d, want %d", found.ID, live.ID)
+		}
+		_, err = repos.Offer.FindAccepted(ctx, item.ID, time.Now().Add(2*time.Hour))
+		assertErrorMessage(t, err, "Offer is not found")
+	})
+}
+
+func newOffer(itemId uint, buyerId uint, price uint) models.Offer {
+	return models.Offer{ItemId: itemId, BuyerId: buyerId, Price: price, Status: models.OfferStatusPending, ExpiresAt: time.Now().Add(time.Hour)}
+}
+
+func createOffer(t *testing.T, repos Repositories, itemId uint, buyerId uint, price uint) *models.Offer {
+	t.Helper()
+	offer, err := repos.Offer.Create(context.Background(), newOffer(itemId, buyerId, price), func(*models.Item, []models.Offer) error { return nil })
+	assertNoError(t, err)
+	return offer
+}
+
+// 提示額で合意し、expiresAtまで確保する
+func acceptOffer(t *testing.T, repos Repositories, offerId uint, expiresAt time.Time) {
+	t.Helper()
+	_, err := repos.Offer.Transition(context.Background(), offerId, func(offer *models.Offer, item *models.Item) error {
+		agreed := offer.Price
+		offer.AgreedPrice = &agreed
+		offer.Status = models.OfferStatusAccepted
+		offer.ExpiresAt = expiresAt
+		return nil
+	})
+	assertNoError(t, err)
+}
+
+func assertOfferStatus(t *testing.T, repos Repositories, offerId uint, want string) {
+	t.Helper()
+	offer, err := repos.Offer.FindById(context.Background(), offerId)
+	assertNoError(t, err)
+	if offer.Status != want {
+		t.Fatalf("offer %d status = %q, want %q", offerId, offer.Status, want)
+	}
+}
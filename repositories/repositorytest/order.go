@@ -0,0 +1,287 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"testing"
+	"time"
+)
+
+// IOrderRepositoryの契約テスト。newReposはサブテストごとに呼ばれ、空の状態のリポジトリ一式を返すこと
+func TestOrderRepository(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("Create passes the locked state to validate and saves its changes", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		canceled := createOrder(t, repos, item.ID, 3)
+		transitionOrder(t, repos, canceled.ID, models.OrderStatusCanceled)
+		held := hold(t, repos, item.ID, 4, time.Now().Add(time.Minute))
+		offer := createOffer(t, repos, item.ID, 5, 800)
+		acceptOffer(t, repos, offer.ID, time.Now().Add(time.Hour))
+
+		created, err := repos.Order.Create(ctx, models.Order{ItemId: item.ID, BuyerId: 2, Status: models.OrderStatusPending}, time.Now(),
+			func(order *models.Order, locked *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error {
+				if locked.ID != item.ID {
+					t.Errorf("item = %d, want %d", locked.ID, item.ID)
+				}
+				if len(orders) != 1 || orders[0].ID != canceled.ID {
+					t.Errorf("orders = %+v, want [%d]", orders, canceled.ID)
+				}
+				if active == nil || active.ID != held.ID {
+					t.Errorf("active = %+v, want %d", active, held.ID)
+				}
+				if accepted == nil || accepted.ID != offer.ID {
+					t.Errorf("accepted = %+v, want %d", accepted, offer.ID)
+				}
+				order.SellerId = locked.UserId
+				order.Price = locked.Price
+				return nil
+			})
+		assertNoError(t, err)
+
+		found, err := repos.Order.FindById(ctx, created.ID)
+		assertNoError(t, err)
+		if found.Status != models.OrderStatusPending || found.SellerId != item.UserId || found.Price != item.Price {
+			t.Fatalf("order = %+v, want a pending order with the values set by validate", found)
+		}
+	})
+
+	t.Run("Create consumes the buyer's hold and accepted offer", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		other := createItem(t, repos)
+		hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+		hold(t, repos, other.ID, 2, time.Now().Add(time.Minute))
+		offer := createOffer(t, repos, item.ID, 2, 800)
+		acceptOffer(t, repos, offer.ID, time.Now().Add(time.Hour))
+
+		createOrder(t, repos, item.ID, 2)
+
+		_, err := repos.Reservation.FindActive(ctx, item.ID, time.Now())
+		assertErrorMessage(t, err, "Reservation is not found")
+		assertOfferStatus(t, repos, offer.ID, models.OfferStatusPurchased)
+		// 他の商品のホールドはそのまま
+		if _, err := repos.Reservation.FindActive(ctx, other.ID, time.Now()); err != nil {
+			t.Fatalf("FindActive(other item): %v", err)
+		}
+	})
+
+	t.Run("Create keeps another buyer's hold and accepted offer", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		hold(t, repos, item.ID, 3, time.Now().Add(time.Minute))
+		offer := createOffer(t, repos, item.ID, 3, 800)
+		acceptOffer(t, repos, offer.ID, time.Now().Add(time.Hour))
+
+		createOrder(t, repos, item.ID, 2)
+
+		if _, err := repos.Reservation.FindActive(ctx, item.ID, time.Now()); err != nil {
+			t.Fatalf("FindActive: %v", err)
+		}
+		assertOfferStatus(t, repos, offer.ID, models.OfferStatusAccepted)
+	})
+
+	t.Run("Create saves nothing when validate fails", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+		validateErr := errors.New("invalid")
+
+		_, err := repos.Order.Create(ctx, models.Order{ItemId: item.ID, BuyerId: 2}, time.Now(),
+			func(*models.Order, *models.Item, []models.Order, *models.Reservation, *models.Offer) error {
+				return validateErr
+			})
+		if !errors.Is(err, validateErr) {
+			t.Fatalf("err = %v, want the validate error", err)
+		}
+		pending, err := repos.Order.FindPendingBefore(ctx, time.Now().Add(time.Hour))
+		assertNoError(t, err)
+		if len(*pending) != 0 {
+			t.Fatalf("len(FindPendingBefore) = %d, want 0", len(*pending))
+		}
+		if _, err := repos.Reservation.FindActive(ctx, item.ID, time.Now()); err != nil {
+			t.Fatalf("the hold was released by a failed Create: %v", err)
+		}
+	})
+
+	t.Run("FindById returns Order is not found for an unknown ID", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Order.FindById(ctx, 999)
+		assertErrorMessage(t, err, "Order is not found")
+		_, err = repos.Order.Transition(ctx, 999, func(*models.Order, *models.Item) (*models.LedgerTransaction, error) { return nil, nil })
+		assertErrorMessage(t, err, "Order is not found")
+	})
+
+	t.Run("Transition saves the order, the item and the ledger transaction together", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		order := createOrder(t, repos, item.ID, 2)
+
+		_, err := repos.Order.Transition(ctx, order.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			order.Status = models.OrderStatusPaid
+			item.SoldOut = true
+			return transfer("platform:cash", "seller:1:pending", 1200), nil
+		})
+		assertNoError(t, err)
+
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.Status != models.OrderStatusPaid {
+			t.Fatalf("Status = %q, want %q", found.Status, models.OrderStatusPaid)
+		}
+		// 商品が変わったので、古いETagでの更新を弾けるようバージョンが上がる
+		foundItem, err := repos.Item.FindById(ctx, item.ID)
+		assertNoError(t, err)
+		if !foundItem.SoldOut || foundItem.Version != item.Version+1 {
+			t.Fatalf("item = {SoldOut: %v, Version: %d}, want {true %d}", foundItem.SoldOut, foundItem.Version, item.Version+1)
+		}
+		assertBalance(t, repos.Ledger, "seller:1:pending", 1200)
+	})
+
+	t.Run("Transition saves nothing when apply fails", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		order := createOrder(t, repos, item.ID, 2)
+		applyErr := errors.New("invalid")
+
+		_, err := repos.Order.Transition(ctx, order.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			order.Status = models.OrderStatusPaid
+			item.SoldOut = true
+			return nil, applyErr
+		})
+		if !errors.Is(err, applyErr) {
+			t.Fatalf("err = %v, want the apply error", err)
+		}
+
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		foundItem, err := repos.Item.FindById(ctx, item.ID)
+		assertNoError(t, err)
+		if found.Status != models.OrderStatusPending || foundItem.SoldOut {
+			t.Fatalf("order status = %q, item sold out = %v, want nothing saved", found.Status, foundItem.SoldOut)
+		}
+	})
+
+	t.Run("Update saves the order", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		order := createOrder(t, repos, item.ID, 2)
+
+		order.PaymentIntentId = "pi_1"
+		_, err := repos.Order.Update(ctx, *order)
+		assertNoError(t, err)
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.PaymentIntentId != "pi_1" {
+			t.Fatalf("PaymentIntentId = %q, want pi_1", found.PaymentIntentId)
+		}
+	})
+
+	t.Run("ApplyPaymentEvent applies each event once", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		order := createOrder(t, repos, item.ID, 2)
+		order.PaymentIntentId = "pi_1"
+		_, err := repos.Order.Update(ctx, *order)
+		assertNoError(t, err)
+
+		calls := 0
+		pay := func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			calls++
+			order.Status = models.OrderStatusPaid
+			return nil, nil
+		}
+		event := models.WebhookEvent{Provider: "fake", EventId: "evt_1", Type: "payment.authorized"}
+		applied, duplicate, err := repos.Order.ApplyPaymentEvent(ctx, event, "pi_1", pay)
+		assertNoError(t, err)
+		if duplicate || applied.ID != order.ID || applied.Status != models.OrderStatusPaid {
+			t.Fatalf("ApplyPaymentEvent = (%+v, %v), want the paid order for the first delivery", applied, duplicate)
+		}
+		// 再送されたときは、applyせずに現在の注文を返す
+		current, duplicate, err := repos.Order.ApplyPaymentEvent(ctx, event, "pi_1", pay)
+		assertNoError(t, err)
+		if !duplicate || calls != 1 {
+			t.Fatalf("duplicate = %v, calls = %d, want (true, 1)", duplicate, calls)
+		}
+		if current.ID != order.ID || current.Status != models.OrderStatusPaid {
+			t.Fatalf("order = %+v, want the current order", current)
+		}
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.Status != models.OrderStatusPaid {
+			t.Fatalf("Status = %q, want %q", found.Status, models.OrderStatusPaid)
+		}
+	})
+
+	t.Run("ApplyPaymentEvent does not record the event for an unknown payment", func(t *testing.T) {
+		repos := newRepos(t)
+		event := models.WebhookEvent{Provider: "fake", EventId: "evt_1", Type: "payment.authorized"}
+		noop := func(*models.Order, *models.Item) (*models.LedgerTransaction, error) { return nil, nil }
+		_, _, err := repos.Order.ApplyPaymentEvent(ctx, event, "pi_unknown", noop)
+		assertErrorMessage(t, err, "Order is not found")
+
+		// 記録されていないので、再送されたときにもう一度処理される
+		_, _, err = repos.Order.ApplyPaymentEvent(ctx, event, "pi_unknown", noop)
+		assertErrorMessage(t, err, "Order is not found")
+	})
+
+	t.Run("FindPendingBefore and FindDeliveredBefore", func(t *testing.T) {
+		repos := newRepos(t)
+		pending := createOrder(t, repos, createItem(t, repos).ID, 2)
+		paid := createOrder(t, repos, createItem(t, repos).ID, 2)
+		transitionOrder(t, repos, paid.ID, models.OrderStatusPaid)
+		delivered := createOrder(t, repos, createItem(t, repos).ID, 2)
+		deliveredAt := time.Now().Add(-time.Hour)
+		_, err := repos.Order.Transition(ctx, delivered.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			order.Status = models.OrderStatusDelivered
+			order.DeliveredAt = &deliveredAt
+			return nil, nil
+		})
+		assertNoError(t, err)
+
+		assertOrderIds(t, repos.Order.FindPendingBefore, time.Now().Add(-time.Hour), nil)
+		assertOrderIds(t, repos.Order.FindPendingBefore, time.Now().Add(time.Minute), []uint{pending.ID})
+		assertOrderIds(t, repos.Order.FindDeliveredBefore, deliveredAt.Add(-time.Minute), nil)
+		assertOrderIds(t, repos.Order.FindDeliveredBefore, time.Now(), []uint{delivered.ID})
+	})
+}
+
+// 確認なしで支払い待ちの注文を作る
+func createOrder(t *testing.T, repos Repositories, itemId uint, buyerId uint) *models.Order {
+	t.Helper()
+	order, err := repos.Order.Create(context.Background(), models.Order{ItemId: itemId, BuyerId: buyerId, SellerId: 1, Price: 1200, Status: models.OrderStatusPending}, time.Now(),
+		func(*models.Order, *models.Item, []models.Order, *models.Reservation, *models.Offer) error {
+			return nil
+		})
+	assertNoError(t, err)
+	return order
+}
+
+func transitionOrder(t *testing.T, repos Repositories, orderId uint, status string) {
+	t.Helper()
+	_, err := repos.Order.Transition(context.Background(), orderId, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		order.Status = status
+		return nil, nil
+	})
+	assertNoError(t, err)
+}
+
+func assertOrderIds(t *testing.T, find func(ctx context.Context, before time.Time) (*[]models.Order, error), before time.Time, want []uint) {
+	t.Helper()
+	orders, err := find(context.Background(), before)
+	assertNoError(t, err)
+	got := []uint{}
+	for _, v := range *orders {
+		got = append(got, v.ID)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("orders = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("orders = %v, want %v", got, want)
+		}
+	}
+}
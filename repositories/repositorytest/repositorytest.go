@@ -0,0 +1,51 @@
+// リポジトリのインタフェースごとの共通テスト（契約テスト）
+//
+// DBを使う実装とメモリ上の実装のどちらでも同じように振る舞うことを確認する。
+// 新しい実装を作ったら、空の状態のリポジトリを返す関数を渡してTestItemRepositoryなどを呼べばよい。
+// 新しいインタフェースを作ったら、ここにTestXxxRepositoryを追加し、repositoriesのcontract_test.goで各実装に対して呼ぶ。
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// エラーのメッセージが期待どおりかを確認する
+// リポジトリのエラーはメッセージで判定されている（例: "Item is not found"）ので、実装によらず同じ文言でなければならない
+func assertErrorMessage(t *testing.T, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("error = nil, want %q", want)
+	}
+	if err.Error() != want {
+		t.Fatalf("error = %q, want %q", err.Error(), want)
+	}
+}
+
+func assertNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// 商品に紐づくデータを扱うリポジトリ一式
+// 商品のロックや他のテーブルの更新も含めて確認するので、すべて同じデータ置き場（DBまたはMemoryStore）に保存すること
+type Repositories struct {
+	Item        repositories.IItemRepository
+	Offer       repositories.IOfferRepository
+	Reservation repositories.IReservationRepository
+	Order       repositories.IOrderRepository
+	Shipment    repositories.IShipmentRepository
+	Ledger      repositories.ILedgerRepository
+}
+
+// 出品者1の商品を登録する
+func createItem(t *testing.T, repos Repositories) *models.Item {
+	t.Helper()
+	item, err := repos.Item.Create(context.Background(), newItem("book"))
+	assertNoError(t, err)
+	return item
+}
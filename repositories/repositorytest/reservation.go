@@ -0,0 +1,131 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"testing"
+	"time"
+)
+
+// IReservationRepositoryの契約テスト。newReposはサブテストごとに呼ばれ、空の状態のリポジトリ一式を返すこと
+func TestReservationRepository(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	ctx := context.Background()
+
+	t.Run("Hold creates a reservation and FindActive returns it", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		_, err := repos.Reservation.FindActive(ctx, item.ID, time.Now())
+		assertErrorMessage(t, err, "Reservation is not found")
+
+		held := hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+		if held.ID == 0 {
+			t.Fatal("held.ID = 0, want an assigned ID")
+		}
+		found, err := repos.Reservation.FindActive(ctx, item.ID, time.Now())
+		assertNoError(t, err)
+		if found.ID != held.ID || found.UserId != 2 {
+			t.Fatalf("FindActive = %+v, want %+v", found, held)
+		}
+		// 期限を過ぎたホールドは見つからない
+		_, err = repos.Reservation.FindActive(ctx, item.ID, time.Now().Add(2*time.Minute))
+		assertErrorMessage(t, err, "Reservation is not found")
+	})
+
+	t.Run("Hold by the same user extends the reservation", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		first := hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+		second := hold(t, repos, item.ID, 2, time.Now().Add(time.Hour))
+		if second.ID != first.ID {
+			t.Fatalf("Hold created %d, want the existing reservation %d to be extended", second.ID, first.ID)
+		}
+		if _, err := repos.Reservation.FindActive(ctx, item.ID, time.Now().Add(30*time.Minute)); err != nil {
+			t.Fatalf("FindActive after the first expiry: %v", err)
+		}
+	})
+
+	t.Run("Hold passes the open orders, the active reservation and the live acceptance to validate", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		canceled := createOrder(t, repos, item.ID, 5)
+		transitionOrder(t, repos, canceled.ID, models.OrderStatusCanceled)
+		pending := createOrder(t, repos, item.ID, 6)
+		held := hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+		expired := createOffer(t, repos, item.ID, 3, 800)
+		acceptOffer(t, repos, expired.ID, time.Now().Add(-time.Minute))
+		live := createOffer(t, repos, item.ID, 4, 900)
+		acceptOffer(t, repos, live.ID, time.Now().Add(time.Hour))
+
+		validateErr := errors.New("reserved")
+		_, err := repos.Reservation.Hold(ctx, models.Reservation{ItemId: item.ID, UserId: 3, ExpiresAt: time.Now().Add(time.Minute)}, time.Now(),
+			func(locked *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error {
+				if locked.ID != item.ID {
+					t.Errorf("item = %d, want %d", locked.ID, item.ID)
+				}
+				if len(orders) != 1 || orders[0].ID != pending.ID {
+					t.Errorf("orders = %+v, want [%d]", orders, pending.ID)
+				}
+				if active == nil || active.ID != held.ID {
+					t.Errorf("active = %+v, want %d", active, held.ID)
+				}
+				if accepted == nil || accepted.ID != live.ID {
+					t.Errorf("accepted = %+v, want %d", accepted, live.ID)
+				}
+				return validateErr
+			})
+		if !errors.Is(err, validateErr) {
+			t.Fatalf("err = %v, want the validate error", err)
+		}
+
+		// validateが失敗したので、最初のホールドのまま
+		found, err := repos.Reservation.FindActive(ctx, item.ID, time.Now())
+		assertNoError(t, err)
+		if found.ID != held.ID {
+			t.Fatalf("FindActive = %d, want %d", found.ID, held.ID)
+		}
+	})
+
+	t.Run("Hold returns Item is not found for an unknown item", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Reservation.Hold(ctx, models.Reservation{ItemId: 999, UserId: 2, ExpiresAt: time.Now().Add(time.Minute)}, time.Now(),
+			func(*models.Item, []models.Order, *models.Reservation, *models.Offer) error { return nil })
+		assertErrorMessage(t, err, "Item is not found")
+	})
+
+	t.Run("Release deletes the user's reservation", func(t *testing.T) {
+		repos := newRepos(t)
+		item := createItem(t, repos)
+		hold(t, repos, item.ID, 2, time.Now().Add(time.Minute))
+
+		assertErrorMessage(t, repos.Reservation.Release(ctx, item.ID, 3), "Reservation is not found")
+		assertNoError(t, repos.Reservation.Release(ctx, item.ID, 2))
+		_, err := repos.Reservation.FindActive(ctx, item.ID, time.Now())
+		assertErrorMessage(t, err, "Reservation is not found")
+		assertErrorMessage(t, repos.Reservation.Release(ctx, item.ID, 2), "Reservation is not found")
+	})
+
+	t.Run("DeleteExpired deletes only expired reservations", func(t *testing.T) {
+		repos := newRepos(t)
+		expiredItem := createItem(t, repos)
+		liveItem := createItem(t, repos)
+		hold(t, repos, expiredItem.ID, 2, time.Now().Add(-time.Minute))
+		hold(t, repos, liveItem.ID, 2, time.Now().Add(time.Hour))
+
+		deleted, err := repos.Reservation.DeleteExpired(ctx, time.Now())
+		assertNoError(t, err)
+		if deleted != 1 {
+			t.Fatalf("DeleteExpired = %d, want 1", deleted)
+		}
+		assertErrorMessage(t, repos.Reservation.Release(ctx, expiredItem.ID, 2), "Reservation is not found")
+		assertNoError(t, repos.Reservation.Release(ctx, liveItem.ID, 2))
+	})
+}
+
+func hold(t *testing.T, repos Repositories, itemId uint, userId uint, expiresAt time.Time) *models.Reservation {
+	t.Helper()
+	reservation, err := repos.Reservation.Hold(context.Background(), models.Reservation{ItemId: itemId, UserId: userId, ExpiresAt: expiresAt}, time.Now(),
+		func(*models.Item, []models.Order, *models.Reservation, *models.Offer) error { return nil })
+	assertNoError(t, err)
+	return reservation
+}
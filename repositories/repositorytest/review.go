@@ -0,0 +1,54 @@
+package repositorytest
+
+import (
+	"context"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"testing"
+)
+
+// IReviewRepositoryの契約テスト。newRepoはサブテストごとに呼ばれ、空の状態のリポジトリを返すこと
+func TestReviewRepository(t *testing.T, newRepo func(t *testing.T) repositories.IReviewRepository) {
+	ctx := context.Background()
+
+	t.Run("FindByReviewee returns the reviews the user received, newest first", func(t *testing.T) {
+		repo := newRepo(t)
+		first, err := repo.Create(ctx, models.Review{OrderId: 1, ReviewerId: 2, RevieweeId: 1, Rating: 5, Comment: "good"})
+		assertNoError(t, err)
+		// 出品者からの評価は購入者が受けたものなので含まない
+		_, err = repo.Create(ctx, models.Review{OrderId: 1, ReviewerId: 1, RevieweeId: 2, Rating: 4})
+		assertNoError(t, err)
+		second, err := repo.Create(ctx, models.Review{OrderId: 2, ReviewerId: 3, RevieweeId: 1, Rating: 3})
+		assertNoError(t, err)
+
+		reviews, err := repo.FindByReviewee(ctx, 1)
+		assertNoError(t, err)
+		if len(*reviews) != 2 || (*reviews)[0].ID != second.ID || (*reviews)[1].ID != first.ID {
+			t.Fatalf("FindByReviewee = %+v, want reviews %d and %d", *reviews, second.ID, first.ID)
+		}
+		if got := (*reviews)[1]; got.Rating != 5 || got.Comment != "good" || got.ReviewerId != 2 {
+			t.Fatalf("review = %+v, want {ReviewerId: 2, Rating: 5, Comment: good}", got)
+		}
+
+		none, err := repo.FindByReviewee(ctx, 99)
+		assertNoError(t, err)
+		if len(*none) != 0 {
+			t.Fatalf("len(FindByReviewee) = %d, want 0", len(*none))
+		}
+	})
+
+	t.Run("Create rejects a second review of the same order by the same user", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.Create(ctx, models.Review{OrderId: 1, ReviewerId: 2, RevieweeId: 1, Rating: 5})
+		assertNoError(t, err)
+
+		_, err = repo.Create(ctx, models.Review{OrderId: 1, ReviewerId: 2, RevieweeId: 1, Rating: 1})
+		assertErrorMessage(t, err, "Review already exists")
+
+		reviews, err := repo.FindByReviewee(ctx, 1)
+		assertNoError(t, err)
+		if len(*reviews) != 1 || (*reviews)[0].Rating != 5 {
+			t.Fatalf("FindByReviewee = %+v, want only the first review", *reviews)
+		}
+	})
+}
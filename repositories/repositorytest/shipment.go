@@ -0,0 +1,174 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-freemarket/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// IShipmentRepositoryの契約テスト。newReposはサブテストごとに呼ばれ、空の状態のリポジトリ一式を返すこと
+func TestShipmentRepository(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	ctx := context.Background()
+	const carrier, trackingNumber = "fake", "FAKE000000001"
+	shippedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	event := func(id string, status string, occurredAt time.Time) models.ShipmentEvent {
+		return models.ShipmentEvent{ExternalId: id, Status: status, OccurredAt: occurredAt}
+	}
+
+	t.Run("ApplyTrackingEvent returns Shipment is not found for an unknown tracking number", func(t *testing.T) {
+		repos := newRepos(t)
+		_, err := repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, time.Now()),
+			func(*models.Shipment, *models.ShipmentEvent, *models.Order, *models.Item) error { return nil })
+		assertErrorMessage(t, err, "Shipment is not found")
+	})
+
+	t.Run("ApplyTrackingEvent passes the newest recorded event and saves the changes", func(t *testing.T) {
+		repos := newRepos(t)
+		order := shipOrder(t, repos, carrier, trackingNumber, shippedAt)
+		apply := func(status string) func(*models.Shipment, *models.ShipmentEvent, *models.Order, *models.Item) error {
+			return func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error {
+				shipment.Status = status
+				return nil
+			}
+		}
+
+		// 後から発生日時の古いイベントが届いても、比較対象は発生日時が最も新しいイベントになる
+		_, err := repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_2", models.ShipmentStatusOutForDelivery, shippedAt.Add(2*time.Hour)), apply(models.ShipmentStatusOutForDelivery))
+		assertNoError(t, err)
+		_, err = repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, shippedAt.Add(time.Hour)),
+			func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error {
+				if latest == nil || latest.ExternalId != "evt_2" {
+					t.Errorf("latest = %+v, want evt_2", latest)
+				}
+				order.Status = models.OrderStatusDelivered
+				return nil
+			})
+		assertNoError(t, err)
+
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.Status != models.OrderStatusDelivered || found.Shipment.Status != models.ShipmentStatusOutForDelivery {
+			t.Fatalf("order = %q, shipment = %q, want (delivered, out_for_delivery)", found.Status, found.Shipment.Status)
+		}
+		// 履歴は発生日時順
+		ids := []string{}
+		for _, v := range found.Shipment.Events {
+			ids = append(ids, v.ExternalId)
+		}
+		if !equalStrings(ids, []string{"order:shipped", "evt_1", "evt_2"}) {
+			t.Fatalf("events = %v, want [order:shipped evt_1 evt_2]", ids)
+		}
+	})
+
+	t.Run("ApplyTrackingEvent applies each event once", func(t *testing.T) {
+		repos := newRepos(t)
+		shipOrder(t, repos, carrier, trackingNumber, shippedAt)
+		calls := 0
+		apply := func(*models.Shipment, *models.ShipmentEvent, *models.Order, *models.Item) error {
+			calls++
+			return nil
+		}
+
+		duplicate, err := repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, time.Now()), apply)
+		assertNoError(t, err)
+		if duplicate {
+			t.Fatal("duplicate = true for the first delivery")
+		}
+		duplicate, err = repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, time.Now()), apply)
+		assertNoError(t, err)
+		if !duplicate || calls != 1 {
+			t.Fatalf("duplicate = %v, calls = %d, want (true, 1)", duplicate, calls)
+		}
+	})
+
+	t.Run("ApplyTrackingEvent does not record the event when apply fails", func(t *testing.T) {
+		repos := newRepos(t)
+		order := shipOrder(t, repos, carrier, trackingNumber, shippedAt)
+		applyErr := errors.New("invalid")
+
+		_, err := repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, time.Now()),
+			func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error {
+				shipment.Status = models.ShipmentStatusInTransit
+				return applyErr
+			})
+		if !errors.Is(err, applyErr) {
+			t.Fatalf("err = %v, want the apply error", err)
+		}
+
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.Shipment.Status != models.ShipmentStatusShipped || len(found.Shipment.Events) != 1 {
+			t.Fatalf("shipment = %+v, want nothing saved", found.Shipment)
+		}
+		// 記録されていないので、再送されたときにもう一度処理される
+		duplicate, err := repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, event("evt_1", models.ShipmentStatusInTransit, time.Now()),
+			func(*models.Shipment, *models.ShipmentEvent, *models.Order, *models.Item) error { return nil })
+		assertNoError(t, err)
+		if duplicate {
+			t.Fatal("duplicate = true after a failed delivery")
+		}
+	})
+
+	t.Run("concurrent ApplyTrackingEvent compares each event with the ones applied before it", func(t *testing.T) {
+		repos := newRepos(t)
+		order := shipOrder(t, repos, carrier, trackingNumber, shippedAt)
+		// 発生日時が新しいイベントより記録済みのイベントの方が新しければ反映しない。
+		// 同時に届いたイベントが同じlatestと比較すると、後から確定した古いイベントが新しい状況を上書きしてしまう
+		apply := func(update models.ShipmentEvent) func(*models.Shipment, *models.ShipmentEvent, *models.Order, *models.Item) error {
+			return func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error {
+				if latest == nil || !update.OccurredAt.Before(latest.OccurredAt) {
+					shipment.Status = update.Status
+				}
+				return nil
+			}
+		}
+
+		const n = 8
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			// 最後のイベントだけが配達完了
+			status := models.ShipmentStatusInTransit
+			if i == n-1 {
+				status = models.ShipmentStatusDelivered
+			}
+			update := event(fmt.Sprintf("evt_%d", i), status, shippedAt.Add(time.Duration(i+1)*time.Minute))
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = repos.Shipment.ApplyTrackingEvent(ctx, carrier, trackingNumber, update, apply(update))
+			}(i)
+		}
+		wg.Wait()
+		assertNoError(t, errors.Join(errs...))
+
+		found, err := repos.Order.FindById(ctx, order.ID)
+		assertNoError(t, err)
+		if found.Shipment.Status != models.ShipmentStatusDelivered || len(found.Shipment.Events) != n+1 {
+			t.Fatalf("shipment = %q with %d events, want delivered with %d events", found.Shipment.Status, len(found.Shipment.Events), n+1)
+		}
+	})
+}
+
+// 発送済みの注文を作る。配送には発送時のイベント（order:shipped）が記録される
+func shipOrder(t *testing.T, repos Repositories, carrier string, trackingNumber string, shippedAt time.Time) *models.Order {
+	t.Helper()
+	order := createOrder(t, repos, createItem(t, repos).ID, 2)
+	shipped, err := repos.Order.Transition(context.Background(), order.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		order.Status = models.OrderStatusShipped
+		order.Shipment = &models.Shipment{
+			OrderId:        order.ID,
+			Carrier:        carrier,
+			TrackingNumber: trackingNumber,
+			Status:         models.ShipmentStatusShipped,
+			Events:         []models.ShipmentEvent{{ExternalId: "order:shipped", Status: models.ShipmentStatusShipped, OccurredAt: shippedAt}},
+		}
+		return nil, nil
+	})
+	assertNoError(t, err)
+	return shipped
+}
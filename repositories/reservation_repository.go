@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ホールドしてよいかを確認する関数。ロック済みの商品・商品を確保している注文・nowの時点で期限内のホールド（なければnil）・
+// 確保期限内の合意済みオファー（なければnil）が渡される
+type ReservationHoldFunc func(item *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error
+
+type IReservationRepository interface {
+	// 商品に対する期限内のホールドを返す（なければ"Reservation is not found"）
+	FindActive(ctx context.Context, itemId uint, now time.Time) (*models.Reservation, error)
+
+	// 商品をロックした上でvalidateを呼び、エラーがなければホールドを保存する。
+	// 同じユーザーのホールドが既にあれば、新しい期限で延長する
+	Hold(ctx context.Context, newReservation models.Reservation, now time.Time, validate ReservationHoldFunc) (*models.Reservation, error)
+	Release(ctx context.Context, itemId uint, userId uint) error
+
+	// 期限切れのホールドをまとめて解放し、解放した件数を返す
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type ReservationRepository struct {
+	db *gorm.DB
+}
+
+func NewReservationRepository(db *gorm.DB) IReservationRepository {
+	return &ReservationRepository{db: db}
+}
+
+func (r *ReservationRepository) FindActive(ctx context.Context, itemId uint, now time.Time) (*models.Reservation, error) {
+	var reservation models.Reservation
+	result := r.db.WithContext(ctx).First(&reservation, "item_id = ? AND expires_at > ?", itemId, now)
+	if result.Error != nil {
+		if result.Error.Error() == "record not found" {
+			return nil, errors.New("Reservation is not found")
+		}
+		return nil, result.Error
+	}
+	return &reservation, nil
+}
+
+func (r *ReservationRepository) Hold(ctx context.Context, newReservation models.Reservation, now time.Time, validate ReservationHoldFunc) (*models.Reservation, error) {
+	var saved models.Reservation
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 同じ商品へのホールドが同時に作られないよう、商品行をロックしてから確認する
+		item, err := lockItem(tx, newReservation.ItemId)
+		if err != nil {
+			return err
+		}
+
+		orders, err := findOpenOrders(tx, item.ID)
+		if err != nil {
+			return err
+		}
+
+		active, err := findActiveReservation(tx, item.ID, now)
+		if err != nil {
+			return err
+		}
+
+		accepted, err := findAcceptedOffer(tx, item.ID, now)
+		if err != nil {
+			return err
+		}
+
+		if err := validate(item, orders, active, accepted); err != nil {
+			return err
+		}
+
+		// 自分のホールドが残っていれば期限だけ延ばす
+		if active != nil && active.UserId == newReservation.UserId {
+			active.ExpiresAt = newReservation.ExpiresAt
+			saved = *active
+			return tx.Save(&saved).Error
+		}
+		saved = newReservation
+		return tx.Create(&saved).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *ReservationRepository) Release(ctx context.Context, itemId uint, userId uint) error {
+	result := r.db.WithContext(ctx).Where("item_id = ? AND user_id = ?", itemId, userId).Delete(&models.Reservation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("Reservation is not found")
+	}
+	return nil
+}
+
+func (r *ReservationRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.Reservation{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+// nowの時点で期限内のホールドを取得する（なければnil）
+func findActiveReservation(db *gorm.DB, itemId uint, now time.Time) (*models.Reservation, error) {
+	var reservations []models.Reservation
+	if err := db.Where("item_id = ? AND expires_at > ?", itemId, now).Limit(1).Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, nil
+	}
+	return &reservations[0], nil
+}
+
+type ReservationMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewReservationMemoryRepository(store *MemoryStore) IReservationRepository {
+	return &ReservationMemoryRepository{store: store}
+}
+
+func (r *ReservationMemoryRepository) FindActive(ctx context.Context, itemId uint, now time.Time) (*models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	reservation, ok := r.store.activeReservation(itemId, now)
+	if !ok {
+		return nil, errors.New("Reservation is not found")
+	}
+	return reservation, nil
+}
+
+func (r *ReservationMemoryRepository) Hold(ctx context.Context, newReservation models.Reservation, now time.Time, validate ReservationHoldFunc) (*models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, err := r.store.findItem(newReservation.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := r.store.openOrders(item.ID)
+	// 見つからなければnilのまま渡す
+	active, _ := r.store.activeReservation(item.ID, now)
+	accepted, _ := r.store.acceptedOffer(item.ID, now)
+
+	if err := validate(item, orders, active, accepted); err != nil {
+		return nil, err
+	}
+
+	if active != nil && active.UserId == newReservation.UserId {
+		active.ExpiresAt = newReservation.ExpiresAt
+		r.store.reservations.save(active)
+		return active, nil
+	}
+	r.store.reservations.create(&newReservation)
+	return &newReservation, nil
+}
+
+func (r *ReservationMemoryRepository) Release(ctx context.Context, itemId uint, userId uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if r.store.reservations.delete(func(v *models.Reservation) bool { return v.ItemId == itemId && v.UserId == userId }) == 0 {
+		return errors.New("Reservation is not found")
+	}
+	return nil
+}
+
+func (r *ReservationMemoryRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	return int64(r.store.reservations.delete(func(v *models.Reservation) bool { return !v.ExpiresAt.After(now) })), nil
+}
+
+func (s *MemoryStore) activeReservation(itemId uint, now time.Time) (*models.Reservation, bool) {
+	return s.reservations.first(func(v *models.Reservation) bool { return v.ItemId == itemId && v.ExpiresAt.After(now) })
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+type IReviewRepository interface {
+	// 同じ注文を同じ人が評価済みなら"Review already exists"
+	Create(ctx context.Context, newReview models.Review) (*models.Review, error)
+	// userIdが受けた評価を新しい順に返す
+	FindByReviewee(ctx context.Context, userId uint) (*[]models.Review, error)
+}
+
+type ReviewRepository struct {
+	db *gorm.DB
+}
+
+func NewReviewRepository(db *gorm.DB) IReviewRepository {
+	return &ReviewRepository{db: db}
+}
+
+func (r *ReviewRepository) Create(ctx context.Context, newReview models.Review) (*models.Review, error) {
+	result := r.db.WithContext(ctx).Create(&newReview)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return nil, errors.New("Review already exists")
+		}
+		return nil, result.Error
+	}
+	return &newReview, nil
+}
+
+func (r *ReviewRepository) FindByReviewee(ctx context.Context, userId uint) (*[]models.Review, error) {
+	var reviews []models.Review
+	result := r.db.WithContext(ctx).Where("reviewee_id = ?", userId).Order("id DESC").Find(&reviews)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &reviews, nil
+}
+
+type ReviewMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewReviewMemoryRepository(store *MemoryStore) IReviewRepository {
+	return &ReviewMemoryRepository{store: store}
+}
+
+func (r *ReviewMemoryRepository) Create(ctx context.Context, newReview models.Review) (*models.Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	// DBのidx_review（注文と評価した人）の一意制約の代わり
+	if r.store.reviews.exists(func(v *models.Review) bool {
+		return v.OrderId == newReview.OrderId && v.ReviewerId == newReview.ReviewerId
+	}) {
+		return nil, errors.New("Review already exists")
+	}
+	r.store.reviews.create(&newReview)
+	return &newReview, nil
+}
+
+func (r *ReviewMemoryRepository) FindByReviewee(ctx context.Context, userId uint) (*[]models.Review, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	reviews := r.store.reviews.find(func(v *models.Review) bool { return v.RevieweeId == userId })
+	slices.Reverse(reviews)
+	return &reviews, nil
+}
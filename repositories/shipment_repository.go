@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IShipmentRepository interface {
+	// 運送会社と追跡番号に紐づく配送へイベントを記録し、注文・商品をロックした上でapplyを適用する。
+	// latestには、これまでに記録されたイベントのうち発生日時が最も新しいものが渡される。
+	// 配送とlatestはロックを取ってから読むので、同じ配送へのイベントが同時に届いても順番に適用される。
+	// 同じイベントが処理済みであればapplyは呼ばれずにduplicate=trueが返る
+	ApplyTrackingEvent(ctx context.Context, carrier string, trackingNumber string, event models.ShipmentEvent, apply TrackingEventFunc) (bool, error)
+}
+
+// 配送イベントを配送・注文に反映する関数。latestは記録済みのイベントのうち最も新しいもの（なければnil）
+type TrackingEventFunc func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error
+
+type ShipmentRepository struct {
+	db *gorm.DB
+}
+
+func NewShipmentRepository(db *gorm.DB) IShipmentRepository {
+	return &ShipmentRepository{db: db}
+}
+
+func (r *ShipmentRepository) ApplyTrackingEvent(ctx context.Context, carrier string, trackingNumber string, event models.ShipmentEvent, apply TrackingEventFunc) (bool, error) {
+	duplicate := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var shipment models.Shipment
+		if err := tx.First(&shipment, "carrier = ? AND tracking_number = ?", carrier, trackingNumber).Error; err != nil {
+			if err.Error() == "record not found" {
+				return errors.New("Shipment is not found")
+			}
+			return err
+		}
+
+		// 既に同じイベントが記録されていれば何もしない
+		event.ShipmentId = shipment.ID
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			duplicate = true
+			return nil
+		}
+
+		var order models.Order
+		if err := tx.First(&order, shipment.OrderId).Error; err != nil {
+			return err
+		}
+		return transitionOrder(tx, &order, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			// 同じ配送へのイベントが同時に届くと、ロックを待つ間に先の方が配送を書き換えている。
+			// 古い内容で上書きしないよう、ロックを取ってから配送と比較対象のイベントを読み直す
+			if err := tx.First(&shipment, shipment.ID).Error; err != nil {
+				return nil, err
+			}
+			latest, err := latestShipmentEvent(tx, shipment.ID, event.ID)
+			if err != nil {
+				return nil, err
+			}
+			if err := apply(&shipment, latest, order, item); err != nil {
+				return nil, err
+			}
+			return nil, tx.Save(&shipment).Error
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return duplicate, nil
+}
+
+// 配送に記録済みのイベントのうち、発生日時が最も新しいものを取得する（なければnil）
+// excludeIdには、いま記録したばかりのイベントを渡して比較対象から外す
+func latestShipmentEvent(db *gorm.DB, shipmentId uint, excludeId uint) (*models.ShipmentEvent, error) {
+	var events []models.ShipmentEvent
+	result := db.Where("shipment_id = ? AND id <> ?", shipmentId, excludeId).Order("occurred_at DESC, id DESC").Limit(1).Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[0], nil
+}
+
+type ShipmentMemoryRepository struct {
+	store *MemoryStore
+}
+
+func NewShipmentMemoryRepository(store *MemoryStore) IShipmentRepository {
+	return &ShipmentMemoryRepository{store: store}
+}
+
+func (r *ShipmentMemoryRepository) ApplyTrackingEvent(ctx context.Context, carrier string, trackingNumber string, event models.ShipmentEvent, apply TrackingEventFunc) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	shipment, ok := r.store.shipments.first(func(v *models.Shipment) bool {
+		return v.Carrier == carrier && v.TrackingNumber == trackingNumber
+	})
+	if !ok {
+		return false, errors.New("Shipment is not found")
+	}
+
+	event.ShipmentId = shipment.ID
+	if r.store.shipmentEvents.exists(func(e *models.ShipmentEvent) bool {
+		return e.ShipmentId == event.ShipmentId && e.ExternalId == event.ExternalId
+	}) {
+		return true, nil
+	}
+
+	// latestShipmentEventのメモリ版。発生日時が同じならIDが大きい方を新しいとみなす
+	var latest *models.ShipmentEvent
+	for _, v := range r.store.shipmentEvents.find(func(e *models.ShipmentEvent) bool { return e.ShipmentId == shipment.ID }) {
+		if latest == nil || !v.OccurredAt.Before(latest.OccurredAt) {
+			latest = &v
+		}
+	}
+
+	order, ok := r.store.orders.first(func(o *models.Order) bool { return o.ID == shipment.OrderId })
+	if !ok {
+		return false, gorm.ErrRecordNotFound
+	}
+	err := r.store.transitionOrder(order, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		return nil, apply(shipment, latest, order, item)
+	})
+	if err != nil {
+		return false, err
+	}
+	r.store.shipments.save(shipment)
+	r.store.shipmentEvents.create(&event)
+	return false, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"gin-freemarket/controllers"
+	"gin-freemarket/metrics"
+	"gin-freemarket/middlewares"
+	"gin-freemarket/problem"
+	"gin-freemarket/services"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ルーティングに必要な依存先。main()ではnewAppが組み立てて渡す
+type routerDeps struct {
+	logger         *slog.Logger
+	requestTimeout time.Duration
+	// 認証が必要なルートでJWTを検証するのに使う
+	authService services.IAuthService
+	// 認証が必要なルートのPOSTでIdempotency-Keyを扱うのに使う
+	idempotencyService services.IIdempotencyService
+
+	itemController     controllers.IItemController
+	categoryController controllers.ICategoryController
+	authController     controllers.IAuthController
+	offerController    controllers.IOfferController
+	orderController    controllers.IOrderController
+	addressController  controllers.IAddressController
+	walletController   controllers.IWalletController
+	reviewController   controllers.IReviewController
+	shipmentController controllers.IShipmentController
+	webhookController  controllers.IWebhookController
+	healthController   controllers.IHealthController
+}
+
+// ミドルウェアとエンドポイントを設定したルーターを作る
+func newRouter(deps routerDeps) *gin.Engine {
+	// エンドポイント設定
+	// gin.Default()のテキスト形式のログと空のボディを返すリカバリは使わず、自前のミドルウェアに置き換える
+	router := gin.New()
+
+	router.Use(
+		middlewares.TracingMiddleware(),
+		middlewares.LoggingMiddleware(deps.logger),
+		middlewares.MetricsMiddleware(),
+		middlewares.RecoveryMiddleware(),
+		middlewares.TimeoutMiddleware(deps.requestTimeout),
+	)
+
+	// 存在しないルートもエラーレスポンスの形を揃える
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(func(ctx *gin.Context) {
+		problem.Respond(ctx, http.StatusNotFound, "Route not found")
+	})
+	router.NoMethod(func(ctx *gin.Context) {
+		problem.Respond(ctx, http.StatusMethodNotAllowed, "Method not allowed")
+	})
+
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{})))
+	router.GET("/healthz", deps.healthController.Live)
+	router.GET("/readyz", deps.healthController.Ready)
+	router.GET("/version", deps.healthController.Version)
+
+	// ルーティングをグルーピング化する
+	itemRouter := router.Group("/items")
+	authRouter := router.Group("/auth")
+	categoryRouter := router.Group("/categories")
+	userRouter := router.Group("/users")
+	// 認証が必要なルーティングはミドルウェアを挟んだグループにまとめる
+	// 再送されたPOSTを二重に処理しないよう、Idempotency-Keyも扱う（キーはユーザーごとなので認証の後）
+	withAuth := []gin.HandlerFunc{
+		middlewares.AuthMiddleware(deps.authService),
+		middlewares.IdempotencyMiddleware(deps.idempotencyService),
+	}
+	itemRouterWithAuth := router.Group("/items", withAuth...)
+	offerRouterWithAuth := router.Group("/offers", withAuth...)
+	orderRouterWithAuth := router.Group("/orders", withAuth...)
+	meRouterWithAuth := router.Group("/me", withAuth...)
+	// Webhookは決済プロバイダから呼ばれるので、JWTではなく署名で認証する
+	webhookRouter := router.Group("/webhooks")
+
+	itemRouter.GET("/", deps.itemController.FindAll)
+	itemRouter.GET("/:id", deps.itemController.FindById)
+	itemRouterWithAuth.POST("/", deps.itemController.Create)
+	itemRouterWithAuth.PUT("/:id", deps.itemController.Update)
+	itemRouterWithAuth.PATCH("/:id", deps.itemController.Patch)
+	itemRouterWithAuth.DELETE("/:id", deps.itemController.Delete)
+	itemRouterWithAuth.POST("/:id/hold", deps.itemController.Hold)
+	itemRouterWithAuth.DELETE("/:id/hold", deps.itemController.Release)
+	itemRouterWithAuth.POST("/:id/purchase", deps.orderController.Purchase)
+	itemRouterWithAuth.GET("/:id/offers", deps.offerController.FindByItem)
+	itemRouterWithAuth.POST("/:id/offers", deps.offerController.Create)
+
+	categoryRouter.GET("/", deps.categoryController.FindAll)
+
+	offerRouterWithAuth.POST("/:id/accept", deps.offerController.Accept)
+	offerRouterWithAuth.POST("/:id/reject", deps.offerController.Reject)
+	offerRouterWithAuth.POST("/:id/counter", deps.offerController.Counter)
+
+	orderRouterWithAuth.GET("/:id", deps.orderController.FindById)
+	orderRouterWithAuth.POST("/:id/cancel", deps.orderController.Cancel)
+	orderRouterWithAuth.POST("/:id/complete", deps.orderController.Complete)
+	orderRouterWithAuth.POST("/:id/ship", deps.shipmentController.Ship)
+	orderRouterWithAuth.POST("/:id/reviews", deps.reviewController.Create)
+
+	userRouter.GET("/:id/reviews", deps.reviewController.FindByUser)
+
+	meRouterWithAuth.GET("/wallet", deps.walletController.GetWallet)
+	meRouterWithAuth.POST("/payouts", deps.walletController.RequestPayout)
+	meRouterWithAuth.GET("/addresses", deps.addressController.FindAll)
+	meRouterWithAuth.POST("/addresses", deps.addressController.Create)
+	meRouterWithAuth.PUT("/addresses/:id", deps.addressController.Update)
+	meRouterWithAuth.DELETE("/addresses/:id", deps.addressController.Delete)
+
+	webhookRouter.POST("/payments", deps.webhookController.Payments)
+	webhookRouter.POST("/shipments", deps.webhookController.Shipments)
+
+	authRouter.POST("/signup", deps.authController.Signup)
+	authRouter.POST("/login", deps.authController.Login)
+
+	return router
+}
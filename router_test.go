@@ -0,0 +1,584 @@
+package main
+
+import (
+	"fmt"
+	"gin-freemarket/models"
+	"gin-freemarket/patch"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAuthSignup(t *testing.T) {
+	t.Run("creates a user", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(http.MethodPost, "/auth/signup", map[string]string{"email": "alice@example.com", "password": "password123"}, "")
+		assertStatus(t, rec, http.StatusCreated)
+	})
+
+	t.Run("rejects a registered email", func(t *testing.T) {
+		s := newTestServer(t)
+		s.signup("alice@example.com", "password123")
+		rec := s.do(http.MethodPost, "/auth/signup", map[string]string{"email": "alice@example.com", "password": "password456"}, "")
+		assertProblem(t, rec, http.StatusConflict, "Email is already registered")
+	})
+
+	validationCases := []struct {
+		name   string
+		body   any
+		fields []string
+	}{
+		{"missing fields", map[string]string{}, []string{"email", "password"}},
+		{"invalid email", map[string]string{"email": "not-an-email", "password": "password123"}, []string{"email"}},
+		{"short password", map[string]string{"email": "alice@example.com", "password": "short"}, []string{"password"}},
+	}
+	for _, tc := range validationCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			rec := s.do(http.MethodPost, "/auth/signup", tc.body, "")
+			details := assertProblem(t, rec, http.StatusBadRequest, "")
+			assertFieldErrors(t, details, tc.fields...)
+		})
+	}
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(http.MethodPost, "/auth/signup", `{"email":`, "")
+		assertProblem(t, rec, http.StatusBadRequest, "")
+	})
+}
+
+func TestAuthLogin(t *testing.T) {
+	t.Run("returns a token that authenticates requests", func(t *testing.T) {
+		s := newTestServer(t)
+		s.signup("alice@example.com", "password123")
+		token := s.login("alice@example.com", "password123")
+
+		rec := s.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}, token)
+		assertStatus(t, rec, http.StatusCreated)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		s := newTestServer(t)
+		s.signup("alice@example.com", "password123")
+		rec := s.do(http.MethodPost, "/auth/login", map[string]string{"email": "alice@example.com", "password": "wrong-password"}, "")
+		assertProblem(t, rec, http.StatusUnauthorized, "Invalid email or password")
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(http.MethodPost, "/auth/login", map[string]string{"email": "nobody@example.com", "password": "password123"}, "")
+		assertProblem(t, rec, http.StatusNotFound, "User not found")
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		s := newTestServer(t)
+		rec := s.do(http.MethodPost, "/auth/login", map[string]string{"email": "alice@example.com"}, "")
+		details := assertProblem(t, rec, http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "password")
+	})
+}
+
+func TestItemsPublicRoutes(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	for _, name := range []string{"book", "lamp"} {
+		assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": name, "price": 1000}), http.StatusCreated)
+	}
+
+	t.Run("GET /items/ lists items without authentication", func(t *testing.T) {
+		rec := s.do(http.MethodGet, "/items/", nil, "")
+		assertStatus(t, rec, http.StatusOK)
+		var items []models.Item
+		decodeData(t, rec, &items)
+		if len(items) != 2 {
+			t.Fatalf("len(items) = %d, want 2", len(items))
+		}
+	})
+
+	t.Run("GET /items/:id returns the item", func(t *testing.T) {
+		rec := s.do(http.MethodGet, "/items/1", nil, "")
+		assertStatus(t, rec, http.StatusOK)
+		var item models.Item
+		decodeData(t, rec, &item)
+		if item.ID != 1 || item.Name != "book" || item.Price != 1000 {
+			t.Fatalf("item = %+v, want {ID: 1, Name: book, Price: 1000}", item)
+		}
+	})
+
+	t.Run("GET /items/:id with an unknown ID", func(t *testing.T) {
+		assertProblem(t, s.do(http.MethodGet, "/items/999", nil, ""), http.StatusNotFound, "Item is not found")
+	})
+
+	t.Run("GET /items/:id with an invalid ID", func(t *testing.T) {
+		assertProblem(t, s.do(http.MethodGet, "/items/abc", nil, ""), http.StatusBadRequest, "Invalid id")
+	})
+}
+
+func TestItemsRequireAuthentication(t *testing.T) {
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/items/"},
+		{http.MethodPut, "/items/1"},
+		{http.MethodPatch, "/items/1"},
+		{http.MethodDelete, "/items/1"},
+		{http.MethodPost, "/items/1/hold"},
+		{http.MethodDelete, "/items/1/hold"},
+		{http.MethodPost, "/items/1/purchase"},
+		{http.MethodGet, "/items/1/offers"},
+		{http.MethodPost, "/items/1/offers"},
+	}
+	s := newTestServer(t)
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			assertProblem(t, s.do(route.method, route.path, nil, ""), http.StatusUnauthorized, "Authorization header is required")
+			assertProblem(t, s.do(route.method, route.path, nil, "not-a-jwt"), http.StatusUnauthorized, "Invalid or expired token")
+
+			basic := http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}}
+			assertProblem(t, s.doWithHeader(route.method, route.path, nil, "", basic), http.StatusUnauthorized, "Authorization header must be a Bearer token")
+		})
+	}
+}
+
+func TestItemsCreate(t *testing.T) {
+	t.Run("creates an item owned by the caller", func(t *testing.T) {
+		s := newTestServer(t)
+		seller := s.userClient("seller@example.com")
+		rec := seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000, "description": "used"})
+		assertStatus(t, rec, http.StatusCreated)
+		var item models.Item
+		decodeData(t, rec, &item)
+		if item.ID == 0 || item.UserId != 1 || item.ShippingPayer != models.ShippingPayerSeller || item.ShipsWithinDays != 3 {
+			t.Fatalf("item = %+v, want an item of user 1 with default shipping settings", item)
+		}
+	})
+
+	validationCases := []struct {
+		name   string
+		body   any
+		fields []string
+	}{
+		{"missing fields", map[string]any{}, []string{"name", "price"}},
+		{"short name", map[string]any{"name": "a", "price": 1000}, []string{"name"}},
+		{"price too high", map[string]any{"name": "book", "price": 100000000}, []string{"price"}},
+		{"unknown shipping payer", map[string]any{"name": "book", "price": 1000, "shipping_payer": "someone"}, []string{"shipping_payer"}},
+	}
+	for _, tc := range validationCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t)
+			seller := s.userClient("seller@example.com")
+			details := assertProblem(t, seller.do(http.MethodPost, "/items/", tc.body), http.StatusBadRequest, "")
+			assertFieldErrors(t, details, tc.fields...)
+		})
+	}
+
+	t.Run("wrong JSON type", func(t *testing.T) {
+		s := newTestServer(t)
+		seller := s.userClient("seller@example.com")
+		details := assertProblem(t, seller.do(http.MethodPost, "/items/", `{"name":"book","price":"cheap"}`), http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "price")
+	})
+}
+
+func TestItemsUpdate(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000, "description": "used"}), http.StatusCreated)
+
+	// GETで受け取ったETagをIf-Matchに入れて更新する
+	rec := s.do(http.MethodGet, "/items/1", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	etag := rec.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %q, want %q", etag, `"1"`)
+	}
+	ifMatch := http.Header{"If-Match": {etag}}
+
+	t.Run("replaces the whole item", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPut, "/items/1", map[string]any{"name": "book", "price": 800}, ifMatch)
+		assertStatus(t, rec, http.StatusOK)
+		var item models.Item
+		decodeData(t, rec, &item)
+		// 省略したdescriptionは空になる
+		if item.Name != "book" || item.Price != 800 || item.Description != "" || item.Version != 2 {
+			t.Fatalf("item = %+v, want {Name: book, Price: 800, Description: \"\", Version: 2}", item)
+		}
+		if got := rec.Header().Get("ETag"); got != `"2"` {
+			t.Fatalf("ETag = %q, want %q", got, `"2"`)
+		}
+	})
+
+	t.Run("stale ETag", func(t *testing.T) {
+		// 1つ前の更新でバージョンが上がっているので、最初のETagでは更新できない
+		assertProblem(t, seller.doWithHeader(http.MethodPut, "/items/1", map[string]any{"name": "overwritten", "price": 1000}, ifMatch), http.StatusPreconditionFailed, "Item has been modified")
+		var item models.Item
+		decodeData(t, s.do(http.MethodGet, "/items/1", nil, ""), &item)
+		if item.Name != "book" {
+			t.Fatalf("Name = %q, want book (the stale update must not be applied)", item.Name)
+		}
+	})
+
+	t.Run("missing If-Match", func(t *testing.T) {
+		assertProblem(t, seller.do(http.MethodPut, "/items/1", map[string]any{"name": "book", "price": 800}), http.StatusPreconditionRequired, "If-Match header is required")
+	})
+
+	current := http.Header{"If-Match": {`"2"`}}
+
+	t.Run("validation error", func(t *testing.T) {
+		// PUTでは必須の項目を省略できない
+		details := assertProblem(t, seller.doWithHeader(http.MethodPut, "/items/1", map[string]any{"name": "a"}, current), http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "name", "price")
+	})
+
+	t.Run("another user's item", func(t *testing.T) {
+		other := s.userClient("other@example.com")
+		assertProblem(t, other.doWithHeader(http.MethodPut, "/items/1", map[string]any{"name": "stolen", "price": 1}, current), http.StatusForbidden, "Forbidden")
+		var item models.Item
+		decodeData(t, s.do(http.MethodGet, "/items/1", nil, ""), &item)
+		if item.Name != "book" || item.Version != 2 {
+			t.Fatalf("item = %+v, want it unchanged", item)
+		}
+	})
+
+	t.Run("soldout cannot be written by the seller", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPut, "/items/1", map[string]any{"name": "book", "price": 800, "soldout": true}, current)
+		assertStatus(t, rec, http.StatusOK)
+		var item models.Item
+		decodeData(t, rec, &item)
+		if item.SoldOut {
+			t.Fatal("SoldOut = true, want the client value to be ignored")
+		}
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		assertProblem(t, seller.doWithHeader(http.MethodPut, "/items/999", map[string]any{"name": "book", "price": 800}, current), http.StatusNotFound, "Item is not found")
+	})
+
+	t.Run("invalid ID", func(t *testing.T) {
+		assertProblem(t, seller.doWithHeader(http.MethodPut, "/items/abc", map[string]any{"name": "book", "price": 800}, current), http.StatusBadRequest, "Invalid id")
+	})
+}
+
+func TestItemsPatch(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000, "description": "used"}), http.StatusCreated)
+
+	patchHeader := func(contentType string, version string) http.Header {
+		return http.Header{"Content-Type": {contentType}, "If-Match": {version}}
+	}
+	getItem := func(t *testing.T) models.Item {
+		t.Helper()
+		var item models.Item
+		decodeData(t, s.do(http.MethodGet, "/items/1", nil, ""), &item)
+		return item
+	}
+
+	t.Run("merge patch updates only the given members and null clears a member", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPatch, "/items/1", `{"price":800,"description":null}`, patchHeader(patch.MergePatchContentType, `"1"`))
+		assertStatus(t, rec, http.StatusOK)
+		if got := rec.Header().Get("ETag"); got != `"2"` {
+			t.Fatalf("ETag = %q, want %q", got, `"2"`)
+		}
+		item := getItem(t)
+		if item.Name != "book" || item.Price != 800 || item.Description != "" {
+			t.Fatalf("item = %+v, want {Name: book, Price: 800, Description: \"\"}", item)
+		}
+	})
+
+	t.Run("JSON patch applies the operations in order", func(t *testing.T) {
+		ops := `[{"op":"test","path":"/price","value":800},{"op":"replace","path":"/price","value":700},{"op":"add","path":"/description","value":"like new"},{"op":"copy","from":"/name","path":"/shipping_method"}]`
+		rec := seller.doWithHeader(http.MethodPatch, "/items/1", ops, patchHeader(patch.JSONPatchContentType, `"2"`))
+		assertStatus(t, rec, http.StatusOK)
+		item := getItem(t)
+		if item.Price != 700 || item.Description != "like new" || item.ShippingMethod != "book" || item.Version != 3 {
+			t.Fatalf("item = %+v, want {Price: 700, Description: like new, ShippingMethod: book, Version: 3}", item)
+		}
+	})
+
+	t.Run("failed test operation changes nothing", func(t *testing.T) {
+		ops := `[{"op":"replace","path":"/price","value":1},{"op":"test","path":"/name","value":"lamp"}]`
+		assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", ops, patchHeader(patch.JSONPatchContentType, `"3"`)), http.StatusConflict, "")
+		if item := getItem(t); item.Price != 700 || item.Version != 3 {
+			t.Fatalf("item = %+v, want it unchanged", item)
+		}
+	})
+
+	t.Run("result is validated with the same rules as PUT", func(t *testing.T) {
+		details := assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `{"name":null,"ships_within_days":31}`, patchHeader(patch.MergePatchContentType, `"3"`)), http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "name", "ships_within_days")
+	})
+
+	t.Run("unknown member", func(t *testing.T) {
+		details := assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `{"nam":"lamp"}`, patchHeader(patch.MergePatchContentType, `"3"`)), http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "nam")
+	})
+
+	t.Run("malformed patch", func(t *testing.T) {
+		assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `[{"op":"rename","path":"/name"}]`, patchHeader(patch.JSONPatchContentType, `"3"`)), http.StatusBadRequest, "")
+	})
+
+	t.Run("stale ETag", func(t *testing.T) {
+		assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `{"price":1}`, patchHeader(patch.MergePatchContentType, `"1"`)), http.StatusPreconditionFailed, "Item has been modified")
+	})
+
+	t.Run("missing If-Match", func(t *testing.T) {
+		header := http.Header{"Content-Type": {patch.MergePatchContentType}}
+		assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `{"price":1}`, header), http.StatusPreconditionRequired, "If-Match header is required")
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPatch, "/items/1", `{"price":1}`, http.Header{"If-Match": {`"3"`}})
+		assertProblem(t, rec, http.StatusUnsupportedMediaType, "Unsupported patch format")
+		if rec.Header().Get("Accept-Patch") == "" {
+			t.Fatal("Accept-Patch header is missing")
+		}
+	})
+
+	t.Run("another user's item", func(t *testing.T) {
+		other := s.userClient("other@example.com")
+		assertProblem(t, other.doWithHeader(http.MethodPatch, "/items/1", `{"price":1}`, patchHeader(patch.MergePatchContentType, `"3"`)), http.StatusForbidden, "Forbidden")
+		if item := getItem(t); item.Price != 700 || item.Version != 3 {
+			t.Fatalf("item = %+v, want it unchanged", item)
+		}
+	})
+
+	t.Run("soldout is not a patchable member", func(t *testing.T) {
+		details := assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/1", `{"soldout":true}`, patchHeader(patch.MergePatchContentType, `"3"`)), http.StatusBadRequest, "")
+		assertFieldErrors(t, details, "soldout")
+		if item := getItem(t); item.SoldOut {
+			t.Fatal("SoldOut = true, want it unchanged")
+		}
+	})
+
+	t.Run("unknown ID", func(t *testing.T) {
+		assertProblem(t, seller.doWithHeader(http.MethodPatch, "/items/999", `{"price":1}`, patchHeader(patch.MergePatchContentType, `"1"`)), http.StatusNotFound, "Item is not found")
+	})
+}
+
+func TestItemsDelete(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}), http.StatusCreated)
+
+	// 出品者以外は削除できない
+	other := s.userClient("other@example.com")
+	assertProblem(t, other.do(http.MethodDelete, "/items/1", nil), http.StatusForbidden, "Forbidden")
+	assertStatus(t, s.do(http.MethodGet, "/items/1", nil, ""), http.StatusOK)
+
+	assertStatus(t, seller.do(http.MethodDelete, "/items/1", nil), http.StatusOK)
+	assertProblem(t, s.do(http.MethodGet, "/items/1", nil, ""), http.StatusNotFound, "Item is not found")
+	assertProblem(t, seller.do(http.MethodDelete, "/items/1", nil), http.StatusNotFound, "Item is not found")
+	assertProblem(t, seller.do(http.MethodDelete, "/items/abc", nil), http.StatusBadRequest, "Invalid id")
+}
+
+func TestItemsHold(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	buyer := s.userClient("buyer@example.com")
+	other := s.userClient("other@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}), http.StatusCreated)
+
+	assertProblem(t, seller.do(http.MethodPost, "/items/1/hold", nil), http.StatusBadRequest, "Cannot trade your own item")
+	assertProblem(t, buyer.do(http.MethodPost, "/items/999/hold", nil), http.StatusNotFound, "Item is not found")
+
+	rec := buyer.do(http.MethodPost, "/items/1/hold", nil)
+	assertStatus(t, rec, http.StatusOK)
+	var reservation models.Reservation
+	decodeData(t, rec, &reservation)
+	if reservation.ItemId != 1 || reservation.UserId != 2 {
+		t.Fatalf("reservation = %+v, want {ItemId: 1, UserId: 2}", reservation)
+	}
+
+	// 他のユーザーがホールド中は確保できない
+	assertProblem(t, other.do(http.MethodPost, "/items/1/hold", nil), http.StatusConflict, "Item is reserved for another buyer")
+
+	assertProblem(t, other.do(http.MethodDelete, "/items/1/hold", nil), http.StatusNotFound, "Reservation is not found")
+	assertStatus(t, buyer.do(http.MethodDelete, "/items/1/hold", nil), http.StatusOK)
+	assertStatus(t, other.do(http.MethodPost, "/items/1/hold", nil), http.StatusOK)
+}
+
+func TestItemsPurchase(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	buyer := s.userClient("buyer@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}), http.StatusCreated)
+
+	// 住所が未登録なら購入できない
+	assertProblem(t, buyer.do(http.MethodPost, "/items/1/purchase", nil), http.StatusBadRequest, "Shipping address is required")
+
+	address := map[string]any{
+		"name": "Buyer", "postal_code": "1000001", "prefecture": "Tokyo", "city": "Chiyoda", "line1": "1-1", "phone": "0312345678",
+	}
+	assertStatus(t, buyer.do(http.MethodPost, "/me/addresses", address), http.StatusCreated)
+
+	rec := buyer.do(http.MethodPost, "/items/1/purchase", nil)
+	assertStatus(t, rec, http.StatusCreated)
+	var res struct {
+		Data    models.Order `json:"data"`
+		Payment struct {
+			ID     string `json:"id"`
+			Amount uint   `json:"amount"`
+		} `json:"payment"`
+	}
+	decodeJSON(t, rec, &res)
+	if res.Data.Status != models.OrderStatusPending || res.Data.Price != 1000 || res.Payment.ID == "" || res.Payment.Amount != 1000 {
+		t.Fatalf("response = %+v, want a pending order with a payment of 1000", res)
+	}
+
+	assertProblem(t, buyer.do(http.MethodPost, "/items/1/purchase", nil), http.StatusConflict, "Order already exists")
+}
+
+func TestItemsOffers(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	buyers := []*testClient{s.userClient("buyer1@example.com"), s.userClient("buyer2@example.com")}
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}), http.StatusCreated)
+
+	assertProblem(t, buyers[0].do(http.MethodPost, "/items/1/offers", map[string]any{"price": 1000}), http.StatusBadRequest, "Offer price is invalid")
+	details := assertProblem(t, buyers[0].do(http.MethodPost, "/items/1/offers", map[string]any{}), http.StatusBadRequest, "")
+	assertFieldErrors(t, details, "price")
+
+	for i, buyer := range buyers {
+		rec := buyer.do(http.MethodPost, "/items/1/offers", map[string]any{"price": 800 + i})
+		assertStatus(t, rec, http.StatusCreated)
+	}
+
+	// 出品者は全件、購入希望者は自分のオファーだけ見られる
+	for _, tc := range []struct {
+		client *testClient
+		want   int
+	}{{seller, 2}, {buyers[0], 1}, {buyers[1], 1}} {
+		rec := tc.client.do(http.MethodGet, "/items/1/offers", nil)
+		assertStatus(t, rec, http.StatusOK)
+		var offers []models.Offer
+		decodeData(t, rec, &offers)
+		if len(offers) != tc.want {
+			t.Fatalf("len(offers) = %d, want %d", len(offers), tc.want)
+		}
+	}
+
+	assertProblem(t, seller.do(http.MethodGet, "/items/999/offers", nil), http.StatusNotFound, "Item is not found")
+	assertProblem(t, seller.do(http.MethodPost, fmt.Sprintf("/items/%d/offers", 1), map[string]any{"price": 500}), http.StatusBadRequest, "Cannot trade your own item")
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	key := func(value string) http.Header { return http.Header{"Idempotency-Key": {value}} }
+	countItems := func(t *testing.T) int {
+		t.Helper()
+		var items []models.Item
+		decodeData(t, s.do(http.MethodGet, "/items/", nil, ""), &items)
+		return len(items)
+	}
+
+	t.Run("retry replays the first response", func(t *testing.T) {
+		body := map[string]any{"name": "book", "price": 1000}
+		first := seller.doWithHeader(http.MethodPost, "/items/", body, key("create-book"))
+		assertStatus(t, first, http.StatusCreated)
+		if first.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatal("first response is marked as replayed")
+		}
+
+		retry := seller.doWithHeader(http.MethodPost, "/items/", body, key("create-book"))
+		assertStatus(t, retry, http.StatusCreated)
+		if retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatal("Idempotent-Replayed header is missing on the retry")
+		}
+		if retry.Body.String() != first.Body.String() || retry.Header().Get("ETag") != first.Header().Get("ETag") {
+			t.Fatalf("retry = %s (ETag %s), want %s (ETag %s)", retry.Body, retry.Header().Get("ETag"), first.Body, first.Header().Get("ETag"))
+		}
+		if n := countItems(t); n != 1 {
+			t.Fatalf("%d items, want 1 (the retry must not create another item)", n)
+		}
+	})
+
+	t.Run("same key with a different body", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 2000}, key("create-book"))
+		assertProblem(t, rec, http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+	})
+
+	t.Run("same key on a different endpoint", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPost, "/items/1/hold", map[string]any{"name": "book", "price": 1000}, key("create-book"))
+		assertProblem(t, rec, http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+	})
+
+	t.Run("keys are per user", func(t *testing.T) {
+		other := s.userClient("other@example.com")
+		rec := other.doWithHeader(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}, key("create-book"))
+		assertStatus(t, rec, http.StatusCreated)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatal("another user's request was replayed")
+		}
+	})
+
+	t.Run("client errors are replayed too", func(t *testing.T) {
+		body := map[string]any{"name": "a"}
+		assertStatus(t, seller.doWithHeader(http.MethodPost, "/items/", body, key("invalid")), http.StatusBadRequest)
+		rec := seller.doWithHeader(http.MethodPost, "/items/", body, key("invalid"))
+		assertStatus(t, rec, http.StatusBadRequest)
+		if rec.Header().Get("Idempotent-Replayed") != "true" {
+			t.Fatal("Idempotent-Replayed header is missing on the retry")
+		}
+	})
+
+	t.Run("requests without a key are not deduplicated", func(t *testing.T) {
+		before := countItems(t)
+		body := map[string]any{"name": "lamp", "price": 500}
+		assertStatus(t, seller.do(http.MethodPost, "/items/", body), http.StatusCreated)
+		assertStatus(t, seller.do(http.MethodPost, "/items/", body), http.StatusCreated)
+		if n := countItems(t); n != before+2 {
+			t.Fatalf("%d items, want %d", n, before+2)
+		}
+	})
+
+	t.Run("too long key", func(t *testing.T) {
+		rec := seller.doWithHeader(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}, key(strings.Repeat("k", 256)))
+		assertProblem(t, rec, http.StatusBadRequest, "Idempotency-Key must be 255 characters or less")
+	})
+}
+
+func TestCategories(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodGet, "/categories/", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	var categories []models.Category
+	decodeData(t, rec, &categories)
+	if len(categories) != 0 {
+		t.Fatalf("categories = %+v, want none", categories)
+	}
+
+	seller := s.userClient("seller@example.com")
+	assertProblem(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000, "category_id": 99}), http.StatusBadRequest, "Category is not found")
+}
+
+func TestOrderReviews(t *testing.T) {
+	s := newTestServer(t)
+	seller := s.userClient("seller@example.com")
+	buyer := s.userClient("buyer@example.com")
+	stranger := s.userClient("stranger@example.com")
+	assertStatus(t, seller.do(http.MethodPost, "/items/", map[string]any{"name": "book", "price": 1000}), http.StatusCreated)
+	address := map[string]any{
+		"name": "Buyer", "postal_code": "1000001", "prefecture": "Tokyo", "city": "Chiyoda", "line1": "1-1", "phone": "0312345678",
+	}
+	assertStatus(t, buyer.do(http.MethodPost, "/me/addresses", address), http.StatusCreated)
+	assertStatus(t, buyer.do(http.MethodPost, "/items/1/purchase", nil), http.StatusCreated)
+
+	details := assertProblem(t, buyer.do(http.MethodPost, "/orders/1/reviews", map[string]any{"rating": 6}), http.StatusBadRequest, "")
+	assertFieldErrors(t, details, "rating")
+	assertProblem(t, stranger.do(http.MethodPost, "/orders/1/reviews", map[string]any{"rating": 5}), http.StatusForbidden, "Forbidden")
+	// 取引が完了するまでは評価できない
+	assertProblem(t, buyer.do(http.MethodPost, "/orders/1/reviews", map[string]any{"rating": 5}), http.StatusConflict, "Order cannot be changed in its current status")
+	assertProblem(t, buyer.do(http.MethodPost, "/orders/99/reviews", map[string]any{"rating": 5}), http.StatusNotFound, "Order is not found")
+
+	rec := s.do(http.MethodGet, "/users/1/reviews", nil, "")
+	assertStatus(t, rec, http.StatusOK)
+	var reviews []models.Review
+	decodeData(t, rec, &reviews)
+	if len(reviews) != 0 {
+		t.Fatalf("reviews = %+v, want none", reviews)
+	}
+}
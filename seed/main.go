@@ -0,0 +1,431 @@
+// 開発・負荷試験用のデータを投入するコマンド
+//
+//	go run ./seed -profile small -seed 1
+//
+// 同じseedなら毎回同じデータになるので、バグの再現に使える。
+// 登録はサービス層を通して行う（パスワードのハッシュ化や在庫・注文の状態遷移などのルールがそのまま適用される）。
+// カテゴリ・ユーザー・住所・商品・注文・レビューはそれぞれ決まったキーで登録済みかを確認するので、何度実行しても重複しない。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gin-freemarket/carriers"
+	"gin-freemarket/dto"
+	"gin-freemarket/infra"
+	"gin-freemarket/models"
+	"gin-freemarket/payments"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 投入する量
+type profile struct {
+	Users        int
+	ItemsPerUser int
+	Orders       int
+}
+
+var profiles = map[string]profile{
+	"small":     {Users: 5, ItemsPerUser: 3, Orders: 5},
+	"load-test": {Users: 1000, ItemsPerUser: 10, Orders: 2000},
+}
+
+// 注文をどこまで進めるか
+const (
+	stagePending = iota // 支払い待ち
+	stagePaid
+	stageShipped
+	stageCompleted
+)
+
+var (
+	adjectives  = []string{"ヴィンテージ", "新品", "美品", "限定", "ハンドメイド", "未開封", "レア", "北欧風"}
+	nouns       = []string{"スニーカー", "マグカップ", "腕時計", "トートバッグ", "文庫本", "ワンピース", "フィルムカメラ", "ヘッドホン", "観葉植物", "レコード"}
+	methods     = []string{"ゆうパケット", "ネコポス", "宅急便コンパクト", "ゆうパック"}
+	prefectures = []string{"東京都", "大阪府", "北海道", "福岡県", "愛知県", "京都府", "沖縄県"}
+	cities      = []string{"中央区", "北区", "港区", "緑区", "西区"}
+	familyNames = []string{"佐藤", "鈴木", "高橋", "田中", "伊藤", "渡辺", "山本", "中村"}
+	givenNames  = []string{"太郎", "花子", "健", "さくら", "翔", "葵", "蓮", "結衣"}
+	comments    = []string{"丁寧に梱包していただきました。", "迅速な発送ありがとうございました。", "説明どおりの商品でした。", ""}
+
+	// 商品名の名詞ごとのカテゴリ。乱数を使わずに決まるので、カテゴリを増やしても他の内容は変わらない
+	categories = map[string]string{
+		"スニーカー":   "ファッション",
+		"ワンピース":   "ファッション",
+		"トートバッグ":  "ファッション",
+		"腕時計":     "ファッション",
+		"マグカップ":   "インテリア・雑貨",
+		"観葉植物":    "インテリア・雑貨",
+		"文庫本":     "本・音楽",
+		"レコード":    "本・音楽",
+		"フィルムカメラ": "家電・カメラ",
+		"ヘッドホン":   "家電・カメラ",
+	}
+)
+
+// 乱数から作った投入内容。DBの状態に関係なく、seedが同じなら同じ内容になる
+type userFixture struct {
+	Email   string
+	Address dto.AddressInput
+	Items   []itemFixture
+}
+
+type itemFixture struct {
+	Input    dto.CreateItemInput
+	Category string // カテゴリ名。IDは登録してから決まる
+}
+
+type orderFixture struct {
+	Buyer int // usersのインデックス
+	Item  int // 全商品を通し番号にしたときのインデックス
+	Stage int
+	// 取引完了まで進めた注文で、購入者が出品者を評価する内容
+	Review dto.CreateReviewInput
+}
+
+func main() {
+	profileName := flag.String("profile", "small", "投入する量（small, load-test）")
+	seed := flag.Uint64("seed", 1, "乱数のシード。同じ値なら同じデータになる")
+	password := flag.String("password", "password123", "投入するユーザー全員のパスワード")
+	flag.Parse()
+
+	p, ok := profiles[*profileName]
+	if !ok {
+		log.Fatalf("Unknown profile: %s", *profileName)
+	}
+
+	cfg, err := infra.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	// メモリで動かすときは保存先のDBがない
+	if cfg.DB.Driver == infra.DriverMemory {
+		log.Fatalf("DB_DRIVER=%s has no database to connect to", infra.DriverMemory)
+	}
+	db := infra.SetupDB(cfg.DB)
+	defer infra.CloseDB(db)
+
+	// main.goと同じ組み立て方にして、APIから登録したときと同じルールを通す
+	authRepository := repositories.NewAuthRepository(db)
+	orderRepository := repositories.NewOrderRepository(db)
+	addressService := services.NewAddressService(repositories.NewAddressRepository(db))
+	fees := services.FeeSchedule{RateBasisPoints: cfg.Market.FeeRateBps, Minimum: cfg.Market.FeeMinimum}
+	orderService := services.NewOrderService(orderRepository, addressService, payments.NewFakePaymentGateway(), fees)
+	s := &seeder{
+		db:              db,
+		authRepository:  authRepository,
+		authService:     services.NewAuthService(authRepository, cfg.Auth.SecretKey.Value(), cfg.Auth.TokenTTL),
+		itemService:     services.NewItemService(repositories.NewItemRepository(db), repositories.NewReservationRepository(db), repositories.NewCategoryRepository(db), cfg.Market.HoldTTL),
+		addressService:  addressService,
+		categoryService: services.NewCategoryService(repositories.NewCategoryRepository(db)),
+		orderService:    orderService,
+		reviewService:   services.NewReviewService(repositories.NewReviewRepository(db), orderService),
+		shipmentService: services.NewShipmentService(repositories.NewShipmentRepository(db), orderRepository, []carriers.Carrier{carriers.NewFakeCarrier()}),
+		password:        *password,
+	}
+
+	users, orders := generate(rand.New(rand.NewPCG(*seed, *seed)), p)
+	ctx := context.Background()
+
+	// カテゴリ名 → カテゴリID
+	categoryIds := s.ensureCategories(ctx)
+
+	// ユーザー・住所・商品。前回の実行が途中で止まっていても続きから登録できるよう、1件ずつ確認する
+	userIds := make([]uint, len(users))
+	// 通し番号 → 商品ID
+	itemIds := []uint{}
+	for i, u := range users {
+		userIds[i] = s.ensureUser(ctx, u.Email)
+		s.ensureAddress(ctx, userIds[i], u.Address)
+		for _, item := range u.Items {
+			input := item.Input
+			input.CategoryId = categoryIds[item.Category]
+			itemIds = append(itemIds, s.ensureItem(ctx, userIds[i], input))
+		}
+	}
+
+	// 注文とレビュー。商品の持ち主や売り切れなど、ルール上作れない注文は飛ばす
+	for _, o := range orders {
+		order := s.ensureOrder(ctx, itemIds[o.Item], userIds[o.Buyer], o.Stage)
+		if order != nil && order.Status == models.OrderStatusCompleted {
+			s.ensureReview(ctx, order, o.Review)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "Seeded profile=%s seed=%d: created %d categories, %d users, %d addresses, %d items, %d orders, %d reviews (%d already existed)\n",
+		*profileName, *seed, s.created.categories, s.created.users, s.created.addresses, s.created.items, s.created.orders, s.created.reviews, s.existing)
+}
+
+// サービス層を通してフィクスチャを登録する。
+// 各フィクスチャは決まったキー（カテゴリ名、メールアドレス、住所の内容、商品の説明文に入れた管理番号、商品と購入者、注文と評価した人）で
+// 登録済みかを確認し、なければ作る。既にあるものはそのまま使う
+type seeder struct {
+	db              *gorm.DB
+	authRepository  repositories.IAuthRepository
+	authService     services.IAuthService
+	itemService     services.IItemService
+	addressService  services.IAddressService
+	categoryService services.ICategoryService
+	orderService    services.IOrderService
+	reviewService   services.IReviewService
+	shipmentService services.IShipmentService
+	password        string
+
+	created struct {
+		categories, users, addresses, items, orders, reviews int
+	}
+	existing int
+}
+
+// 商品名の名詞に対応するカテゴリをすべて登録し、名前からIDを引けるようにする
+func (s *seeder) ensureCategories(ctx context.Context) map[string]uint {
+	existing, err := s.categoryService.FindAll(ctx)
+	if err != nil {
+		log.Fatalf("Failed to find categories: %v", err)
+	}
+	ids := map[string]uint{}
+	for _, v := range *existing {
+		ids[v.Name] = v.ID
+	}
+
+	// mapの順番は毎回変わるので、名詞の並び順で登録してIDを揃える
+	for _, noun := range nouns {
+		name := categories[noun]
+		if _, ok := ids[name]; ok {
+			continue
+		}
+		created, err := s.categoryService.Create(ctx, name)
+		if err != nil {
+			log.Fatalf("Failed to create category %s: %v", name, err)
+		}
+		ids[name] = created.ID
+		s.created.categories++
+	}
+	s.existing += len(*existing)
+	return ids
+}
+
+func (s *seeder) ensureUser(ctx context.Context, email string) uint {
+	existing, err := s.authRepository.FindUser(ctx, email)
+	if err == nil {
+		s.existing++
+		return existing.ID
+	}
+	if err.Error() != "User not found" {
+		log.Fatalf("Failed to find user %s: %v", email, err)
+	}
+
+	if err := s.authService.Signup(ctx, email, s.password); err != nil {
+		log.Fatalf("Failed to sign up %s: %v", email, err)
+	}
+	created, err := s.authRepository.FindUser(ctx, email)
+	if err != nil {
+		log.Fatalf("Failed to find user %s: %v", email, err)
+	}
+	s.created.users++
+	return created.ID
+}
+
+func (s *seeder) ensureAddress(ctx context.Context, userId uint, input dto.AddressInput) {
+	addresses, err := s.addressService.FindAll(ctx, userId)
+	if err != nil {
+		log.Fatalf("Failed to find addresses of user %d: %v", userId, err)
+	}
+	for _, v := range *addresses {
+		if v.Name == input.Name && v.PostalCode == input.PostalCode && v.Line1 == input.Line1 {
+			s.existing++
+			return
+		}
+	}
+
+	if _, err := s.addressService.Create(ctx, userId, input); err != nil {
+		log.Fatalf("Failed to create address for user %d: %v", userId, err)
+	}
+	s.created.addresses++
+}
+
+// 説明文には商品ごとの管理番号が入っているので、出品者と説明文で同じ商品かを判断する
+func (s *seeder) ensureItem(ctx context.Context, userId uint, input dto.CreateItemInput) uint {
+	var items []models.Item
+	if err := s.db.WithContext(ctx).Where("user_id = ? AND description = ?", userId, input.Desciption).Order("id").Limit(1).Find(&items).Error; err != nil {
+		log.Fatalf("Failed to find items of user %d: %v", userId, err)
+	}
+	if len(items) > 0 {
+		s.existing++
+		return items[0].ID
+	}
+
+	item, err := s.itemService.Create(ctx, input, userId)
+	if err != nil {
+		log.Fatalf("Failed to create item for user %d: %v", userId, err)
+	}
+	s.created.items++
+	return item.ID
+}
+
+// 同じ購入者の注文があればそれを、なければ新しく購入した注文をstageまで進めて返す。
+// ルール上購入できなかった場合はnil
+func (s *seeder) ensureOrder(ctx context.Context, itemId uint, buyerId uint, stage int) *models.Order {
+	var orders []models.Order
+	if err := s.db.WithContext(ctx).Where("item_id = ? AND buyer_id = ?", itemId, buyerId).Order("id DESC").Limit(1).Find(&orders).Error; err != nil {
+		log.Fatalf("Failed to find orders of item %d: %v", itemId, err)
+	}
+
+	var order *models.Order
+	if len(orders) > 0 {
+		s.existing++
+		order = &orders[0]
+	} else {
+		created, _, err := s.orderService.Purchase(ctx, itemId, buyerId, dto.PurchaseInput{})
+		if err != nil {
+			if isRuleViolation(err) {
+				return nil
+			}
+			log.Fatalf("Failed to purchase item %d: %v", itemId, err)
+		}
+		s.created.orders++
+		order = created
+	}
+	return s.advance(ctx, order, stage)
+}
+
+// 取引完了した注文で、購入者が出品者を評価する。評価済みならそのまま
+func (s *seeder) ensureReview(ctx context.Context, order *models.Order, input dto.CreateReviewInput) {
+	reviews, err := s.reviewService.FindByUser(ctx, order.SellerId)
+	if err != nil {
+		log.Fatalf("Failed to find reviews of user %d: %v", order.SellerId, err)
+	}
+	for _, v := range *reviews {
+		if v.OrderId == order.ID && v.ReviewerId == order.BuyerId {
+			s.existing++
+			return
+		}
+	}
+
+	if _, err := s.reviewService.Create(ctx, order.ID, order.BuyerId, input); err != nil {
+		log.Fatalf("Failed to review order %d: %v", order.ID, err)
+	}
+	s.created.reviews++
+}
+
+// 注文を1段階ずつstageまで進め、進めた後の注文を返す。前回の実行で途中まで進んだ注文も続きから進む
+func (s *seeder) advance(ctx context.Context, order *models.Order, stage int) *models.Order {
+	for {
+		var err error
+		switch {
+		case stage >= stagePaid && order.Status == models.OrderStatusPending:
+			// イベントIDは注文ごとに決まっているので、同じ支払いが二重に処理されることはない
+			event := payments.WebhookEvent{ID: fmt.Sprintf("evt_seed_%d", order.ID), Type: payments.EventPaymentAuthorized}
+			event.Data.PaymentIntentId = order.PaymentIntentId
+			err = s.orderService.HandlePaymentEvent(ctx, event)
+		case stage >= stagePaid && order.Status == models.OrderStatusCaptureRequested:
+			// 本番ではCaptureの後に決済プロバイダから届くイベント
+			event := payments.WebhookEvent{ID: fmt.Sprintf("evt_seed_%d_succeeded", order.ID), Type: payments.EventPaymentSucceeded}
+			event.Data.PaymentIntentId = order.PaymentIntentId
+			err = s.orderService.HandlePaymentEvent(ctx, event)
+		case stage >= stageShipped && order.Status == models.OrderStatusPaid:
+			input := dto.ShipInput{Carrier: "fake", TrackingNumber: fmt.Sprintf("FAKE%09d", order.ID)}
+			_, err = s.shipmentService.Ship(ctx, order.ID, order.SellerId, input)
+		case stage >= stageCompleted && (order.Status == models.OrderStatusShipped || order.Status == models.OrderStatusDelivered):
+			_, err = s.orderService.Complete(ctx, order.ID, order.BuyerId)
+		default:
+			return order
+		}
+		if err != nil {
+			log.Fatalf("Failed to advance order %d from %s: %v", order.ID, order.Status, err)
+		}
+
+		previous := order
+		order, err = s.orderService.FindById(ctx, previous.ID, previous.BuyerId)
+		if err != nil {
+			log.Fatalf("Failed to find order %d: %v", previous.ID, err)
+		}
+		// 処理済みのイベントとして無視された場合など、状態が変わらなければ先に進めない
+		if order.Status == previous.Status {
+			log.Fatalf("Order %d did not advance from %s", order.ID, order.Status)
+		}
+	}
+}
+
+// 投入内容をすべて先に決めておく。
+// DBの状態によって乱数の消費順が変わると、2回目以降の実行で内容がずれてしまうため
+func generate(r *rand.Rand, p profile) ([]userFixture, []orderFixture) {
+	users := make([]userFixture, p.Users)
+	for i := range users {
+		name := pick(r, familyNames) + " " + pick(r, givenNames)
+		users[i] = userFixture{
+			Email: fmt.Sprintf("seed-user-%04d@example.com", i+1),
+			Address: dto.AddressInput{
+				Name:       name,
+				PostalCode: fmt.Sprintf("%07d", r.IntN(10_000_000)),
+				Prefecture: pick(r, prefectures),
+				City:       pick(r, cities),
+				Line1:      fmt.Sprintf("%d-%d-%d", r.IntN(9)+1, r.IntN(30)+1, r.IntN(20)+1),
+				Phone:      fmt.Sprintf("090%08d", r.IntN(100_000_000)),
+				IsDefault:  true,
+			},
+		}
+		for j := 0; j < p.ItemsPerUser; j++ {
+			// 説明文の管理番号で、再実行時に登録済みの商品を見分ける
+			payer := "seller"
+			var fee uint
+			if r.IntN(3) == 0 {
+				payer = "buyer"
+				fee = uint(r.IntN(10)+2) * 100
+			}
+			adjective := pick(r, adjectives)
+			noun := pick(r, nouns)
+			users[i].Items = append(users[i].Items, itemFixture{
+				Input: dto.CreateItemInput{
+					Name:            adjective + "の" + noun,
+					Price:           uint(r.IntN(200)+3) * 100,
+					Desciption:      strings.Repeat("状態は良好です。", r.IntN(3)+1) + fmt.Sprintf("（管理番号: S%04d-%02d）", i+1, j+1),
+					ShippingMethod:  pick(r, methods),
+					ShippingPayer:   payer,
+					ShippingFee:     fee,
+					ShipsWithinDays: uint(r.IntN(7) + 1),
+				},
+				Category: categories[noun],
+			})
+		}
+	}
+
+	orders := make([]orderFixture, 0, p.Orders)
+	totalItems := p.Users * p.ItemsPerUser
+	if totalItems == 0 || p.Users < 2 {
+		return users, orders
+	}
+	for i := 0; i < p.Orders; i++ {
+		orders = append(orders, orderFixture{
+			Buyer: r.IntN(p.Users),
+			Item:  r.IntN(totalItems),
+			Stage: r.IntN(stageCompleted + 1),
+			Review: dto.CreateReviewInput{
+				Rating:  uint(r.IntN(3) + 3), // 3〜5
+				Comment: pick(r, comments),
+			},
+		})
+	}
+	return users, orders
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.IntN(len(values))]
+}
+
+// 自分の商品を買おうとした、既に売れているなど、データの組み合わせ上起こりうるエラー
+func isRuleViolation(err error) bool {
+	return errors.Is(err, services.ErrOwnItem) ||
+		errors.Is(err, services.ErrItemUnavailable) ||
+		errors.Is(err, services.ErrItemReserved) ||
+		errors.Is(err, services.ErrOrderAlreadyExists)
+}
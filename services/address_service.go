@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+)
+
+var ErrAddressRequired = errors.New("Shipping address is required")
+
+type IAddressService interface {
+	FindAll(ctx context.Context, userId uint) (*[]models.Address, error)
+	Create(ctx context.Context, userId uint, addressInput dto.AddressInput) (*models.Address, error)
+	Update(ctx context.Context, addressId uint, userId uint, addressInput dto.AddressInput) (*models.Address, error)
+	Delete(ctx context.Context, addressId uint, userId uint) error
+
+	// 購入時の送り先を決める。addressIdがnilならデフォルトの住所
+	FindForOrder(ctx context.Context, addressId *uint, userId uint) (*models.Address, error)
+}
+
+type AddressService struct {
+	repository repositories.IAddressRepository
+}
+
+func NewAddressService(repository repositories.IAddressRepository) IAddressService {
+	return &AddressService{repository: repository}
+}
+
+func (s *AddressService) FindAll(ctx context.Context, userId uint) (*[]models.Address, error) {
+	return s.repository.FindAll(ctx, userId)
+}
+
+func (s *AddressService) Create(ctx context.Context, userId uint, addressInput dto.AddressInput) (*models.Address, error) {
+	newAddress := models.Address{
+		UserId:          userId,
+		ShippingAddress: toShippingAddress(addressInput),
+		IsDefault:       addressInput.IsDefault,
+	}
+	return s.repository.Create(ctx, newAddress)
+}
+
+func (s *AddressService) Update(ctx context.Context, addressId uint, userId uint, addressInput dto.AddressInput) (*models.Address, error) {
+	targetAddress, err := s.repository.FindById(ctx, addressId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	targetAddress.ShippingAddress = toShippingAddress(addressInput)
+	// デフォルトを外すのは別の住所をデフォルトにしたときだけ。falseを送っても外れない
+	if addressInput.IsDefault {
+		targetAddress.IsDefault = true
+	}
+	return s.repository.Update(ctx, *targetAddress)
+}
+
+func (s *AddressService) Delete(ctx context.Context, addressId uint, userId uint) error {
+	return s.repository.Delete(ctx, addressId, userId)
+}
+
+func (s *AddressService) FindForOrder(ctx context.Context, addressId *uint, userId uint) (*models.Address, error) {
+	if addressId != nil {
+		return s.repository.FindById(ctx, *addressId, userId)
+	}
+
+	address, err := s.repository.FindDefault(ctx, userId)
+	if err != nil {
+		if err.Error() == "Address is not found" {
+			return nil, ErrAddressRequired
+		}
+		return nil, err
+	}
+	return address, nil
+}
+
+func toShippingAddress(addressInput dto.AddressInput) models.ShippingAddress {
+	return models.ShippingAddress{
+		Name:       addressInput.Name,
+		PostalCode: addressInput.PostalCode,
+		Prefecture: addressInput.Prefecture,
+		City:       addressInput.City,
+		Line1:      addressInput.Line1,
+		Line2:      addressInput.Line2,
+		Phone:      addressInput.Phone,
+	}
+}
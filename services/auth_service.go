@@ -1,34 +1,50 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"gin-freemarket/metrics"
 	"gin-freemarket/models"
 	"gin-freemarket/repositories"
-	"os"
+	"gin-freemarket/tracing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	// パスワードが一致しない
+	ErrInvalidCredentials = errors.New("Invalid email or password")
+	// 同じメールアドレスで登録済み
+	ErrEmailAlreadyExists = errors.New("Email is already registered")
+)
+
+//go:generate go tool mockgen -source=auth_service.go -destination=../mocks/mock_auth_service.go -package=mocks
+
 type IAuthService interface {
-	Signup(email string, password string) error
-	Login(email string, password string) (*string, error)
-	GetUserFromToken(tokenString string) (*models.User, error)
+	Signup(ctx context.Context, email string, password string) error
+	Login(ctx context.Context, email string, password string) (*string, error)
+	GetUserFromToken(ctx context.Context, tokenString string) (*models.User, error)
 }
 
 type AuthService struct {
 	repository repositories.IAuthRepository
+	secretKey  []byte        // JWTの署名に使う鍵
+	tokenTTL   time.Duration // JWTの有効期限
 }
 
-func NewAuthService(repository repositories.IAuthRepository) IAuthService {
-	return &AuthService{repository: repository}
+func NewAuthService(repository repositories.IAuthRepository, secretKey string, tokenTTL time.Duration) IAuthService {
+	return &AuthService{repository: repository, secretKey: []byte(secretKey), tokenTTL: tokenTTL}
 }
 
-func (s *AuthService) Signup(email string, password string) error {
+func (s *AuthService) Signup(ctx context.Context, email string, password string) error {
 	// パスワードハッシュ化
+	// bcryptはわざと遅く作られているので、DBのクエリと区別できるよう個別のスパンにする
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.GenerateFromPassword")
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	span.End()
 	if err != nil {
 		return err
 	}
@@ -37,47 +53,62 @@ func (s *AuthService) Signup(email string, password string) error {
 		Email:    email,
 		Password: string(hashedPassword),
 	}
-	return s.repository.CreateUser(user)
+	if err := s.repository.CreateUser(ctx, user); err != nil {
+		if err.Error() == "Email is already registered" {
+			return ErrEmailAlreadyExists
+		}
+		return err
+	}
+	metrics.SignupsTotal.Inc()
+	return nil
 }
 
-func (s *AuthService) Login(email string, password string) (*string, error) {
-	foundUser, err := s.repository.FindUser(email)
+func (s *AuthService) Login(ctx context.Context, email string, password string) (*string, error) {
+	foundUser, err := s.repository.FindUser(ctx, email)
 	if err != nil {
+		metrics.LoginsTotal.WithLabelValues("failure").Inc()
 		return nil, err
 	}
 
+	_, span := tracing.Tracer().Start(ctx, "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(password))
+	span.End()
 	if err != nil {
+		metrics.LoginsTotal.WithLabelValues("failure").Inc()
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return nil, ErrInvalidCredentials
+		}
 		return nil, err
 	}
 
 	// Tokenの生成
-	token, err := CreateToken(foundUser.ID, foundUser.Email)
+	token, err := CreateToken(foundUser.ID, foundUser.Email, s.secretKey, s.tokenTTL)
 	if err != nil {
 		return nil, err
 	}
 
+	metrics.LoginsTotal.WithLabelValues("success").Inc()
 	return token, nil
 }
 
 // プライベートメソッド（ポインタレシーバいらない）
-func CreateToken(userId uint, email string) (*string, error) {
+func CreateToken(userId uint, email string, secretKey []byte, ttl time.Duration) (*string, error) {
 
 	// token生成
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub":   userId, //ユーザー識別子
 		"email": email,
-		"exp":   time.Now().Add(time.Hour).Unix(), //Tokenの有効期限
+		"exp":   time.Now().Add(ttl).Unix(), //Tokenの有効期限
 	})
 
-	tokenString, err := token.SignedString([]byte(os.Getenv(("SECRET_KEY"))))
+	tokenString, err := token.SignedString(secretKey)
 	if err != nil {
 		return nil, err
 	}
 	return &tokenString, nil
 }
 
-func (s *AuthService) GetUserFromToken(tokenString string) (*models.User, error) {
+func (s *AuthService) GetUserFromToken(ctx context.Context, tokenString string) (*models.User, error) {
 
 	// jwtトークンを解析するために、jwt.Parseの第一引数に渡ってきたTokenStringを第二引数は無名関数。
 	// これはjwt暗号化アルゴリズムが正しいかどうかをチェックしてあっていたら暗号化時に使用したsecret_keyを返すというもの
@@ -87,7 +118,7 @@ func (s *AuthService) GetUserFromToken(tokenString string) (*models.User, error)
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("Unexpected signing method %v", token.Header["alg"])
 		}
-		return []byte(os.Getenv("SECRET_KEY")), nil
+		return s.secretKey, nil
 	})
 	if err != nil {
 		return nil, err
@@ -108,7 +139,7 @@ func (s *AuthService) GetUserFromToken(tokenString string) (*models.User, error)
 		// ここで上で宣言したvar user *models.UserにDB検索結果を入れる。
 		// 宣言した変数に格納するので:=ではなく、=。errも使いまわせる
 		if email, ok := claims["email"].(string); ok {
-			user, err = s.repository.FindUser(email)
+			user, err = s.repository.FindUser(ctx, email)
 			if err != nil {
 				return nil, err
 			}
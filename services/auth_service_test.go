@@ -0,0 +1,171 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/mocks"
+	"gin-freemarket/models"
+	"gin-freemarket/services"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testSecret = "test-secret"
+
+func TestAuthServiceSignup(t *testing.T) {
+	cases := []struct {
+		name    string
+		repoErr error
+		wantErr error
+	}{
+		{name: "created"},
+		{name: "email already registered", repoErr: errors.New("Email is already registered"), wantErr: services.ErrEmailAlreadyExists},
+		{name: "repository error", repoErr: context.DeadlineExceeded, wantErr: context.DeadlineExceeded},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIAuthRepository(ctrl)
+			repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) error {
+				// パスワードは平文ではなくハッシュで保存される
+				if user.Email != "alice@example.com" {
+					t.Errorf("user.Email = %q, want alice@example.com", user.Email)
+				}
+				if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("password123")); err != nil {
+					t.Errorf("user.Password is not a hash of the password: %v", err)
+				}
+				return tc.repoErr
+			})
+
+			err := services.NewAuthService(repo, testSecret, time.Hour).Signup(context.Background(), "alice@example.com", "password123")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthServiceLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &models.User{Model: gorm.Model{ID: 3}, Email: "alice@example.com", Password: string(hash)}
+	notFound := errors.New("User not found")
+
+	cases := []struct {
+		name     string
+		password string
+		found    *models.User
+		findErr  error
+		wantErr  error
+	}{
+		{name: "success", password: "password123", found: user},
+		{name: "wrong password", password: "password456", found: user, wantErr: services.ErrInvalidCredentials},
+		{name: "unknown user", password: "password123", findErr: notFound, wantErr: notFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIAuthRepository(ctrl)
+			repo.EXPECT().FindUser(gomock.Any(), "alice@example.com").Return(tc.found, tc.findErr)
+
+			token, err := services.NewAuthService(repo, testSecret, time.Hour).Login(context.Background(), "alice@example.com", tc.password)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr != nil {
+				return
+			}
+
+			claims := jwt.MapClaims{}
+			if _, err := jwt.ParseWithClaims(*token, claims, func(*jwt.Token) (any, error) { return []byte(testSecret), nil }); err != nil {
+				t.Fatalf("token is invalid: %v", err)
+			}
+			if claims["sub"] != float64(3) || claims["email"] != "alice@example.com" {
+				t.Fatalf("claims = %v, want sub 3 and email alice@example.com", claims)
+			}
+		})
+	}
+}
+
+func TestAuthServiceGetUserFromToken(t *testing.T) {
+	user := &models.User{Model: gorm.Model{ID: 3}, Email: "alice@example.com"}
+
+	sign := func(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	created := func(t *testing.T, ttl time.Duration) string {
+		t.Helper()
+		token, err := services.CreateToken(3, "alice@example.com", []byte(testSecret), ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *token
+	}
+	future := time.Now().Add(time.Hour).Unix()
+
+	cases := []struct {
+		name  string
+		token func(t *testing.T) string
+		// nilならリポジトリは呼ばれない
+		found   *models.User
+		findErr error
+		wantErr error
+	}{
+		{name: "valid token", token: func(t *testing.T) string { return created(t, time.Hour) }, found: user},
+		{name: "expired token", token: func(t *testing.T) string { return created(t, -time.Minute) }, wantErr: jwt.ErrTokenExpired},
+		{
+			name: "signed with another key",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{"email": "alice@example.com", "exp": future})
+			},
+			wantErr: jwt.ErrTokenSignatureInvalid,
+		},
+		{
+			name: "unsigned token",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"email": "alice@example.com", "exp": future})
+			},
+			// HMAC以外の署名方式はkeyfuncで拒否する
+			wantErr: jwt.ErrTokenUnverifiable,
+		},
+		{name: "malformed token", token: func(t *testing.T) string { return "not-a-jwt" }, wantErr: jwt.ErrTokenMalformed},
+		{
+			name:    "user no longer exists",
+			token:   func(t *testing.T) string { return created(t, time.Hour) },
+			findErr: errors.New("User not found"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIAuthRepository(ctrl)
+			if tc.found != nil || tc.findErr != nil {
+				repo.EXPECT().FindUser(gomock.Any(), "alice@example.com").Return(tc.found, tc.findErr)
+			}
+
+			got, err := services.NewAuthService(repo, testSecret, time.Hour).GetUserFromToken(context.Background(), tc.token(t))
+			switch {
+			case tc.findErr != nil:
+				if err == nil || err.Error() != tc.findErr.Error() {
+					t.Fatalf("err = %v, want %v", err, tc.findErr)
+				}
+			case !errors.Is(err, tc.wantErr):
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			case got != tc.found:
+				t.Fatalf("user = %+v, want %+v", got, tc.found)
+			}
+		})
+	}
+}
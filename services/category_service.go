@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+)
+
+// 出品・更新で指定されたカテゴリがない
+var ErrCategoryNotFound = errors.New("Category is not found")
+
+type ICategoryService interface {
+	FindAll(ctx context.Context) (*[]models.Category, error)
+	// 同じ名前のカテゴリがあれば"Category already exists"
+	Create(ctx context.Context, name string) (*models.Category, error)
+}
+
+type CategoryService struct {
+	repository repositories.ICategoryRepository
+}
+
+func NewCategoryService(repository repositories.ICategoryRepository) ICategoryService {
+	return &CategoryService{repository: repository}
+}
+
+func (s *CategoryService) FindAll(ctx context.Context) (*[]models.Category, error) {
+	return s.repository.FindAll(ctx)
+}
+
+func (s *CategoryService) Create(ctx context.Context, name string) (*models.Category, error) {
+	return s.repository.Create(ctx, models.Category{Name: name})
+}
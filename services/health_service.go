@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readyzで確認する依存先ひとつ分のチェック
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// /versionで返すビルド情報
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+// バージョンとコミットはビルド時に-ldflags "-X main.version=..."で埋め込む想定。
+// コミットが埋め込まれていなければ、go buildが記録したVCSの情報を使う
+func NewBuildInfo(version string, commit string) BuildInfo {
+	if commit == "" {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, v := range info.Settings {
+				if v.Key == "vcs.revision" {
+					commit = v.Value
+				}
+			}
+		}
+	}
+	return BuildInfo{Version: version, Commit: commit, GoVersion: runtime.Version()}
+}
+
+type IHealthService interface {
+	// 各チェックの結果（"ok"またはエラー内容）と、すべて成功したかどうかを返す
+	Ready(ctx context.Context) (map[string]string, bool)
+	// 終了処理に入ったことを記録する。以降Readyは常に失敗し、ロードバランサから外される
+	SetShuttingDown()
+	BuildInfo() BuildInfo
+}
+
+type HealthService struct {
+	checks       []HealthCheck
+	timeout      time.Duration // 1回のチェック全体にかける最大時間
+	buildInfo    BuildInfo
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(checks []HealthCheck, timeout time.Duration, buildInfo BuildInfo) IHealthService {
+	return &HealthService{checks: checks, timeout: timeout, buildInfo: buildInfo}
+}
+
+func (s *HealthService) Ready(ctx context.Context) (map[string]string, bool) {
+	results := map[string]string{}
+	if s.shuttingDown.Load() {
+		results["shutdown"] = "shutting down"
+		return results, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	// 遅いチェックに引きずられないよう、並行して実行する
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	ready := true
+	for _, v := range s.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			err := check.Check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				results[check.Name] = err.Error()
+				ready = false
+				return
+			}
+			results[check.Name] = "ok"
+		}(v)
+	}
+	wg.Wait()
+	return results, ready
+}
+
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *HealthService) BuildInfo() BuildInfo {
+	return s.buildInfo
+}
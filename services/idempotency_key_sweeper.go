@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"gin-freemarket/logging"
+	"gin-freemarket/repositories"
+	"log/slog"
+	"time"
+)
+
+// 期限切れの冪等キーを定期的に消すバックグラウンド処理
+type IdempotencyKeySweeper struct {
+	periodicWorker
+	repository repositories.IIdempotencyKeyRepository
+}
+
+func NewIdempotencyKeySweeper(repository repositories.IIdempotencyKeyRepository, interval time.Duration) *IdempotencyKeySweeper {
+	s := &IdempotencyKeySweeper{repository: repository}
+	s.periodicWorker = periodicWorker{interval: interval, run: s.sweep}
+	return s
+}
+
+func (s *IdempotencyKeySweeper) sweep(ctx context.Context, now time.Time) {
+	deleted, err := s.repository.DeleteExpired(ctx, now)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to delete expired idempotency keys", slog.Any("error", err))
+		return
+	}
+	if deleted > 0 {
+		logging.FromContext(ctx).Info("Deleted expired idempotency keys", slog.Int64("count", deleted))
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"net/http"
+	"time"
+)
+
+var (
+	// 同じキーのリクエストがまだ処理中
+	ErrIdempotencyKeyInUse = errors.New("A request with the same Idempotency-Key is being processed")
+	// 同じキーが別の内容のリクエストで使われている
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key has already been used for a different request")
+)
+
+// 処理済みのリクエストに返したレスポンス
+type StoredResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type IIdempotencyService interface {
+	// キーの処理を始める。初めてのキーならnilを返すので、リクエストを処理してからCompleteかAbandonを呼ぶこと。
+	// 処理済みのキーなら保存してあるレスポンスを返す。
+	// fingerprintはリクエストの内容を表す値で、同じキーで違う値が来たらErrIdempotencyKeyReusedになる
+	Begin(ctx context.Context, userId uint, key string, fingerprint string) (*StoredResponse, error)
+	// レスポンスを保存し、以降の再送ではこれを返す
+	Complete(ctx context.Context, userId uint, key string, response StoredResponse) error
+	// 処理に失敗したときに呼ぶ。レスポンスは保存せず、同じキーでやり直せるようにする
+	Abandon(ctx context.Context, userId uint, key string) error
+}
+
+type IdempotencyService struct {
+	repository repositories.IIdempotencyKeyRepository
+	ttl        time.Duration // キーを覚えておく期間
+	lease      time.Duration // 処理中のキーを持っていられる期間
+}
+
+func NewIdempotencyService(repository repositories.IIdempotencyKeyRepository, ttl time.Duration, lease time.Duration) IIdempotencyService {
+	return &IdempotencyService{repository: repository, ttl: ttl, lease: lease}
+}
+
+func (s *IdempotencyService) Begin(ctx context.Context, userId uint, key string, fingerprint string) (*StoredResponse, error) {
+	now := time.Now()
+	found, created, err := s.repository.Reserve(ctx, models.IdempotencyKey{
+		UserId:      userId,
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.ttl),
+		LockedUntil: now.Add(s.lease),
+	})
+	if err != nil {
+		// 同時に来た先のリクエストが失敗して記録を消した直後。処理中だったものとして、やり直してもらう
+		if err.Error() == "Idempotency key is not found" {
+			return nil, ErrIdempotencyKeyInUse
+		}
+		return nil, err
+	}
+	if created {
+		return nil, nil
+	}
+
+	// 内容が違えば、処理中かどうかに関わらず使い回しとして拒否する
+	if found.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if found.StatusCode == 0 {
+		return nil, ErrIdempotencyKeyInUse
+	}
+
+	response := &StoredResponse{StatusCode: found.StatusCode, Header: http.Header{}, Body: found.ResponseBody}
+	if found.ResponseHeader != "" {
+		if err := json.Unmarshal([]byte(found.ResponseHeader), &response.Header); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (s *IdempotencyService) Complete(ctx context.Context, userId uint, key string, response StoredResponse) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	return s.repository.SaveResponse(ctx, userId, key, response.StatusCode, string(header), response.Body)
+}
+
+func (s *IdempotencyService) Abandon(ctx context.Context, userId uint, key string) error {
+	return s.repository.Delete(ctx, userId, key)
+}
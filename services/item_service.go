@@ -1,81 +1,297 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"gin-freemarket/dto"
+	"gin-freemarket/metrics"
 	"gin-freemarket/models"
 	"gin-freemarket/repositories"
+	"gin-freemarket/tracing"
+	"slices"
+	"time"
 )
 
+var (
+	ErrItemUnavailable = errors.New("Item is not available")
+	ErrItemReserved    = errors.New("Item is reserved for another buyer")
+	ErrOwnItem         = errors.New("Cannot trade your own item")
+	// 更新のもとにしたバージョンが古い（他の更新が先に入った）
+	ErrItemModified = errors.New("Item has been modified")
+)
+
+// PATCHの差分を適用する関数。商品の今の内容を受け取り、適用した後の内容を返す
+type ItemPatchFunc func(current dto.UpdateItemInput) (dto.UpdateItemInput, error)
+
+//go:generate go tool mockgen -source=item_service.go -destination=../mocks/mock_item_service.go -package=mocks
+
 // サービスクラスにもinterfaceを作るのがお作法らしい
 type IItemService interface {
-	FindAll() (*[]models.Item, error)
-	FindById(itemId uint) (*models.Item, error)
-	Create(createItemInput dto.CreateItemInput) (*models.Item, error)
-	Update(itemId uint, updateItemInput dto.UpdateItemInput) (*models.Item, error)
-	Delete(itemId uint) error
+	FindAll(ctx context.Context) (*[]models.Item, error)
+	FindById(ctx context.Context, itemId uint) (*models.Item, error)
+	// 存在しないカテゴリを指定した場合はErrCategoryNotFound（更新も同じ）
+	Create(ctx context.Context, createItemInput dto.CreateItemInput, userId uint) (*models.Item, error)
+	// 更新できる項目をすべてupdateItemInputの内容で置き換える（PUT）
+	// 更新・削除できるのは出品者（userId）だけで、それ以外はErrForbidden
+	// versionは利用者が更新のもとにした商品のバージョン（If-Match）。違っていればErrItemModified。0なら確認しない
+	Update(ctx context.Context, itemId uint, userId uint, version uint, updateItemInput dto.UpdateItemInput) (*models.Item, error)
+	// 今の内容にapplyで差分を適用した結果で置き換える（PATCH）。applyのエラーはそのまま返す
+	Patch(ctx context.Context, itemId uint, userId uint, version uint, apply ItemPatchFunc) (*models.Item, error)
+	Delete(ctx context.Context, itemId uint, userId uint) error
+
+	// 購入手続きの開始時に商品を一定時間確保する。自分のホールドが残っていれば延長になる
+	Hold(ctx context.Context, itemId uint, userId uint) (*models.Reservation, error)
+	Release(ctx context.Context, itemId uint, userId uint) error
 }
 
 // ItemServiceの本体（クラスに相当）
 // repositories.IItemRepositoryはインタフェース。(newしたときの定義)
 // インタフェースを定義することで差し替えが容易になる
 type ItemService struct {
-	repository repositories.IItemRepository
+	repository            repositories.IItemRepository
+	reservationRepository repositories.IReservationRepository
+	categoryRepository    repositories.ICategoryRepository
+	holdTTL               time.Duration // ホールドの有効期間
 }
 
 // コンストラクタ
-func NewItemService(repository repositories.IItemRepository) IItemService {
-	return &ItemService{repository: repository}
+func NewItemService(repository repositories.IItemRepository, reservationRepository repositories.IReservationRepository, categoryRepository repositories.ICategoryRepository, holdTTL time.Duration) IItemService {
+	return &ItemService{
+		repository:            repository,
+		reservationRepository: reservationRepository,
+		categoryRepository:    categoryRepository,
+		holdTTL:               holdTTL,
+	}
 }
 
-func (s *ItemService) FindAll() (*[]models.Item, error) {
-	// ここを&s.repository.FindAll()とやらないのは、すでに利用しているrepository(IItemRepository)のFindAllの戻り値がポインタだから。
+func (s *ItemService) FindAll(ctx context.Context) (*[]models.Item, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.FindAll")
+	defer span.End()
+
+	// ここを&s.repository.FindAll(ctx)とやらないのは、すでに利用しているrepository(IItemRepository)のFindAllの戻り値がポインタだから。
 	// 同じメソッド名でわかりにくいが、リポジトリ経由で呼び出していて、リポジトリ側ですでに参照を返しているので、こちらでわざわざ参照を返す必要がない
-	return s.repository.FindAll()
+	return s.repository.FindAll(ctx)
 }
 
-func (s *ItemService) FindById(itemId uint) (*models.Item, error) {
-	return s.repository.FindById(itemId)
+func (s *ItemService) FindById(ctx context.Context, itemId uint) (*models.Item, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.FindById")
+	defer span.End()
+
+	return s.repository.FindById(ctx, itemId)
 }
 
-func (s *ItemService) Create(createItemInput dto.CreateItemInput) (*models.Item, error) {
+func (s *ItemService) Create(ctx context.Context, createItemInput dto.CreateItemInput, userId uint) (*models.Item, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Create")
+	defer span.End()
+
 	newItem := models.Item{
 		Name:        createItemInput.Name,
 		Price:       createItemInput.Price,
 		Description: createItemInput.Desciption,
 		SoldOut:     false,
+		UserId:      userId, // 出品者
+		CategoryId:  createItemInput.CategoryId,
+
+		ShippingMethod:  createItemInput.ShippingMethod,
+		ShippingPayer:   createItemInput.ShippingPayer,
+		ShippingFee:     createItemInput.ShippingFee,
+		ShipsWithinDays: createItemInput.ShipsWithinDays,
+	}
+	if newItem.ShippingPayer == "" {
+		newItem.ShippingPayer = models.ShippingPayerSeller
+	}
+	if newItem.ShipsWithinDays == 0 {
+		newItem.ShipsWithinDays = 3
+	}
+	if err := s.checkCategory(ctx, newItem.CategoryId); err != nil {
+		return nil, err
 	}
 
-	return s.repository.Create(newItem)
+	createdItem, err := s.repository.Create(ctx, newItem)
+	if err != nil {
+		return nil, err
+	}
+	metrics.ItemsCreatedTotal.Inc()
+	return createdItem, nil
+}
+
+func (s *ItemService) Update(ctx context.Context, itemId uint, userId uint, version uint, updateItemInput dto.UpdateItemInput) (*models.Item, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Update")
+	defer span.End()
+
+	targetItem, err := s.findForUpdate(ctx, itemId, userId, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.replace(ctx, targetItem, updateItemInput)
 }
 
-func (s *ItemService) Update(itemId uint, updateItemInput dto.UpdateItemInput) (*models.Item, error) {
+func (s *ItemService) Patch(ctx context.Context, itemId uint, userId uint, version uint, apply ItemPatchFunc) (*models.Item, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Patch")
+	defer span.End()
+
+	targetItem, err := s.findForUpdate(ctx, itemId, userId, version)
+	if err != nil {
+		return nil, err
+	}
+	input, err := apply(updateInputOf(targetItem))
+	if err != nil {
+		return nil, err
+	}
+	return s.replace(ctx, targetItem, input)
+}
 
-	targetItem, err := s.FindById(itemId)
+// 更新する商品を取得する
+// 利用者が見ていた内容から既に変わっていれば、その内容をもとにした変更は受け付けない
+func (s *ItemService) findForUpdate(ctx context.Context, itemId uint, userId uint, version uint) (*models.Item, error) {
+	targetItem, err := s.findOwned(ctx, itemId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && targetItem.Version != version {
+		return nil, ErrItemModified
+	}
+	return targetItem, nil
+}
 
+// 出品者本人の商品を取得する。他のユーザーの商品ならErrForbidden
+func (s *ItemService) findOwned(ctx context.Context, itemId uint, userId uint) (*models.Item, error) {
+	targetItem, err := s.FindById(ctx, itemId)
 	if err != nil {
 		return nil, err
 	}
+	if targetItem.UserId != userId {
+		return nil, ErrForbidden
+	}
+	return targetItem, nil
+}
 
-	if updateItemInput.Name != nil {
-		targetItem.Name = *updateItemInput.Name
+// 商品の更新できる項目をinputで置き換える。今の値と違う項目だけを保存する
+func (s *ItemService) replace(ctx context.Context, targetItem *models.Item, input dto.UpdateItemInput) (*models.Item, error) {
+	// 省略された項目は出品時と同じ既定値にする
+	if input.ShippingPayer == "" {
+		input.ShippingPayer = models.ShippingPayerSeller
 	}
-	if updateItemInput.Price != nil {
-		targetItem.Price = *updateItemInput.Price
+	if input.ShipsWithinDays == 0 {
+		input.ShipsWithinDays = 3
 	}
-	if updateItemInput.Description != nil {
-		targetItem.Description = *updateItemInput.Description
+
+	var fields []string
+	fields = applyChange(fields, "Name", &targetItem.Name, input.Name)
+	fields = applyChange(fields, "Price", &targetItem.Price, input.Price)
+	fields = applyChange(fields, "Description", &targetItem.Description, input.Description)
+	fields = applyChange(fields, "CategoryId", &targetItem.CategoryId, input.CategoryId)
+	fields = applyChange(fields, "ShippingMethod", &targetItem.ShippingMethod, input.ShippingMethod)
+	fields = applyChange(fields, "ShippingPayer", &targetItem.ShippingPayer, input.ShippingPayer)
+	fields = applyChange(fields, "ShippingFee", &targetItem.ShippingFee, input.ShippingFee)
+	fields = applyChange(fields, "ShipsWithinDays", &targetItem.ShipsWithinDays, input.ShipsWithinDays)
+	if len(fields) == 0 {
+		return targetItem, nil
 	}
-	if updateItemInput.SoldOut != nil {
-		targetItem.SoldOut = *updateItemInput.SoldOut
+	if slices.Contains(fields, "CategoryId") {
+		if err := s.checkCategory(ctx, input.CategoryId); err != nil {
+			return nil, err
+		}
 	}
 
 	// ここで*targetItemを渡しているのは、s.FindById(itemId)の結果がポインタで返ってくるから。
 	// s.repository.Updateは普通の値を引数として要求しているので、ここでデシリアライズして値渡しをしている。
 	// createは構造体をその時に作っていてそのまま渡しているので値渡しとなる。
 	// よっぽど巨大なインスタンスを渡さないのであれば、参照渡しでOK
-	return s.repository.Update(*targetItem)
+	// 読み込んでから保存するまでに他の更新が入った場合も、リポジトリがバージョンの不一致で弾く
+	updatedItem, err := s.repository.Update(ctx, *targetItem, fields)
+	if err != nil {
+		if err.Error() == "Item has been modified" {
+			return nil, ErrItemModified
+		}
+		return nil, err
+	}
+	return updatedItem, nil
+}
+
+// 未分類（0）以外なら、カテゴリが登録されていることを確認する
+func (s *ItemService) checkCategory(ctx context.Context, categoryId uint) error {
+	if categoryId == 0 {
+		return nil
+	}
+	if _, err := s.categoryRepository.FindById(ctx, categoryId); err != nil {
+		if err.Error() == "Category is not found" {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	return nil
 }
 
-func (s *ItemService) Delete(itemId uint) error {
-	return s.repository.Delete(itemId)
+// 今の値と違えばdstに反映し、更新対象のフィールド名をfieldsに追加する
+func applyChange[T comparable](fields []string, name string, dst *T, value T) []string {
+	if *dst == value {
+		return fields
+	}
+	*dst = value
+	return append(fields, name)
+}
+
+// 商品の今の内容を、PATCHの差分を適用する元のドキュメントにする
+func updateInputOf(item *models.Item) dto.UpdateItemInput {
+	return dto.UpdateItemInput{
+		Name:            item.Name,
+		Price:           item.Price,
+		Description:     item.Description,
+		CategoryId:      item.CategoryId,
+		ShippingMethod:  item.ShippingMethod,
+		ShippingPayer:   item.ShippingPayer,
+		ShippingFee:     item.ShippingFee,
+		ShipsWithinDays: item.ShipsWithinDays,
+	}
+}
+
+func (s *ItemService) Delete(ctx context.Context, itemId uint, userId uint) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Delete")
+	defer span.End()
+
+	if _, err := s.findOwned(ctx, itemId, userId); err != nil {
+		return err
+	}
+	return s.repository.Delete(ctx, itemId)
+}
+
+func (s *ItemService) Hold(ctx context.Context, itemId uint, userId uint) (*models.Reservation, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Hold")
+	defer span.End()
+
+	now := time.Now()
+	newReservation := models.Reservation{
+		ItemId:    itemId,
+		UserId:    userId,
+		ExpiresAt: now.Add(s.holdTTL),
+	}
+
+	return s.reservationRepository.Hold(ctx, newReservation, now, func(item *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error {
+		if item.SoldOut {
+			return ErrItemUnavailable
+		}
+		if item.UserId == userId {
+			return ErrOwnItem
+		}
+		// 購入手続き中の注文がある商品は、その注文がキャンセルされるまで確保できない
+		if len(orders) > 0 {
+			return ErrItemReserved
+		}
+		if active != nil && active.UserId != userId {
+			return ErrItemReserved
+		}
+		// 値下げ交渉で他の購入希望者と合意済みの商品も確保できない
+		if accepted != nil && accepted.BuyerId != userId {
+			return ErrItemReserved
+		}
+		return nil
+	})
+}
+
+func (s *ItemService) Release(ctx context.Context, itemId uint, userId uint) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ItemService.Release")
+	defer span.End()
+
+	return s.reservationRepository.Release(ctx, itemId, userId)
 }
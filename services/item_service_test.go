@@ -0,0 +1,418 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/mocks"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+)
+
+func TestItemServiceFindById(t *testing.T) {
+	ctx := context.Background()
+	notFound := errors.New("Item is not found")
+
+	cases := []struct {
+		name    string
+		item    *models.Item
+		err     error
+		wantErr error
+	}{
+		{name: "found", item: &models.Item{Model: gorm.Model{ID: 1}, Name: "book"}},
+		{name: "not found", err: notFound, wantErr: notFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIItemRepository(ctrl)
+			repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(tc.item, tc.err)
+
+			item, err := services.NewItemService(repo, nil, nil, time.Minute).FindById(ctx, 1)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			if item != tc.item {
+				t.Fatalf("item = %+v, want %+v", item, tc.item)
+			}
+		})
+	}
+}
+
+func TestItemServiceCreate(t *testing.T) {
+	cases := []struct {
+		name  string
+		input dto.CreateItemInput
+		want  models.Item
+	}{
+		{
+			name:  "fills in shipping defaults",
+			input: dto.CreateItemInput{Name: "book", Price: 1000, Desciption: "used"},
+			want: models.Item{
+				Name: "book", Price: 1000, Description: "used", UserId: 7,
+				ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3,
+			},
+		},
+		{
+			name: "keeps given shipping settings",
+			input: dto.CreateItemInput{
+				Name: "lamp", Price: 3000,
+				ShippingMethod: "post", ShippingPayer: models.ShippingPayerBuyer, ShippingFee: 500, ShipsWithinDays: 7,
+			},
+			want: models.Item{
+				Name: "lamp", Price: 3000, UserId: 7,
+				ShippingMethod: "post", ShippingPayer: models.ShippingPayerBuyer, ShippingFee: 500, ShipsWithinDays: 7,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIItemRepository(ctrl)
+			repo.EXPECT().Create(gomock.Any(), tc.want).DoAndReturn(func(_ context.Context, item models.Item) (*models.Item, error) {
+				item.ID = 1
+				return &item, nil
+			})
+
+			item, err := services.NewItemService(repo, nil, nil, time.Minute).Create(context.Background(), tc.input, 7)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if item.ID != 1 {
+				t.Fatalf("item.ID = %d, want 1", item.ID)
+			}
+		})
+	}
+}
+
+func TestItemServiceUpdate(t *testing.T) {
+	stored := models.Item{
+		Model: gorm.Model{ID: 1}, Name: "book", Price: 1000, Description: "used", UserId: 7,
+		ShippingMethod: "post", ShippingPayer: models.ShippingPayerSeller, ShippingFee: 0, ShipsWithinDays: 3, Version: 2,
+	}
+	full := dto.UpdateItemInput{
+		Name: "book", Price: 1000, Description: "used",
+		ShippingMethod: "post", ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3,
+	}
+
+	// 更新できる項目はすべて置き換わるが、保存するのは今の値と違う項目だけ
+	cases := []struct {
+		name   string
+		input  func(input *dto.UpdateItemInput)
+		modify func(item *models.Item)
+		// リポジトリに渡る更新対象。空ならリポジトリの更新は呼ばれない
+		fields []string
+	}{
+		{name: "same values", input: func(input *dto.UpdateItemInput) {}, modify: func(item *models.Item) {}},
+		{
+			name:   "one changed field",
+			input:  func(input *dto.UpdateItemInput) { input.Price = 800 },
+			modify: func(item *models.Item) { item.Price = 800 },
+			fields: []string{"Price"},
+		},
+		{
+			name:   "omitted fields are cleared",
+			input:  func(input *dto.UpdateItemInput) { *input = dto.UpdateItemInput{Name: "book", Price: 1000} },
+			modify: func(item *models.Item) { item.Description, item.ShippingMethod = "", "" },
+			fields: []string{"Description", "ShippingMethod"},
+		},
+		{
+			name: "omitted shipping settings fall back to the defaults",
+			input: func(input *dto.UpdateItemInput) {
+				input.ShippingPayer, input.ShipsWithinDays = "", 0
+			},
+			modify: func(item *models.Item) {},
+		},
+		{
+			name: "all fields",
+			input: func(input *dto.UpdateItemInput) {
+				*input = dto.UpdateItemInput{
+					Name: "lamp", Price: 3000, Description: "new",
+					ShippingMethod: "courier", ShippingPayer: models.ShippingPayerBuyer, ShippingFee: 500, ShipsWithinDays: 7,
+				}
+			},
+			modify: func(item *models.Item) {
+				item.Name, item.Price, item.Description = "lamp", 3000, "new"
+				item.ShippingMethod, item.ShippingPayer, item.ShippingFee, item.ShipsWithinDays = "courier", models.ShippingPayerBuyer, 500, 7
+			},
+			fields: []string{"Name", "Price", "Description", "ShippingMethod", "ShippingPayer", "ShippingFee", "ShipsWithinDays"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := full
+			tc.input(&input)
+			want := stored
+			tc.modify(&want)
+
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIItemRepository(ctrl)
+			found := stored
+			repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+			if len(tc.fields) > 0 {
+				repo.EXPECT().Update(gomock.Any(), want, tc.fields).DoAndReturn(func(_ context.Context, item models.Item, _ []string) (*models.Item, error) {
+					item.Version++
+					return &item, nil
+				})
+				want.Version++
+			}
+
+			item, err := services.NewItemService(repo, nil, nil, time.Minute).Update(context.Background(), 1, 7, 2, input)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if *item != want {
+				t.Fatalf("item = %+v, want %+v", *item, want)
+			}
+		})
+	}
+
+	changed := full
+	changed.Price = 800
+	errorCases := []struct {
+		name    string
+		itemId  uint
+		userId  uint
+		version uint
+		expect  func(repo *mocks.MockIItemRepository)
+		wantErr string
+	}{
+		{
+			name: "not found", itemId: 9, userId: 7, version: 2,
+			expect: func(repo *mocks.MockIItemRepository) {
+				repo.EXPECT().FindById(gomock.Any(), uint(9)).Return(nil, errors.New("Item is not found"))
+			},
+			wantErr: "Item is not found",
+		},
+		{
+			// If-Matchのバージョンが古ければリポジトリの更新は呼ばれない
+			name: "stale version", itemId: 1, userId: 7, version: 1,
+			expect: func(repo *mocks.MockIItemRepository) {
+				found := stored
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+			},
+			wantErr: services.ErrItemModified.Error(),
+		},
+		{
+			// 読み込んでから保存するまでに他の更新が入った
+			name: "modified before saving", itemId: 1, userId: 7, version: 2,
+			expect: func(repo *mocks.MockIItemRepository) {
+				found := stored
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+				repo.EXPECT().Update(gomock.Any(), gomock.Any(), []string{"Price"}).Return(nil, errors.New("Item has been modified"))
+			},
+			wantErr: services.ErrItemModified.Error(),
+		},
+		{
+			// 出品者以外は、バージョンが合っていても更新できない
+			name: "another user's item", itemId: 1, userId: 8, version: 2,
+			expect: func(repo *mocks.MockIItemRepository) {
+				found := stored
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+			},
+			wantErr: services.ErrForbidden.Error(),
+		},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIItemRepository(ctrl)
+			tc.expect(repo)
+
+			_, err := services.NewItemService(repo, nil, nil, time.Minute).Update(context.Background(), tc.itemId, tc.userId, tc.version, changed)
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("err = %v, want %s", err, tc.wantErr)
+			}
+		})
+	}
+
+	t.Run("version 0 skips the version check", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockIItemRepository(ctrl)
+		found := stored
+		repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+		// 読み込んだバージョンで更新する
+		repo.EXPECT().Update(gomock.Any(), gomock.Cond(func(item models.Item) bool { return item.Version == 2 }), []string{"Price"}).Return(&found, nil)
+
+		if _, err := services.NewItemService(repo, nil, nil, time.Minute).Update(context.Background(), 1, 7, 0, changed); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	})
+}
+
+func TestItemServicePatch(t *testing.T) {
+	stored := models.Item{
+		Model: gorm.Model{ID: 1}, Name: "book", Price: 1000, Description: "used", UserId: 7,
+		ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3, Version: 2,
+	}
+
+	t.Run("applies the patch to the current values", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockIItemRepository(ctrl)
+		found := stored
+		repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+		want := stored
+		want.Description = ""
+		repo.EXPECT().Update(gomock.Any(), want, []string{"Description"}).Return(&want, nil)
+
+		_, err := services.NewItemService(repo, nil, nil, time.Minute).Patch(context.Background(), 1, 7, 2, func(current dto.UpdateItemInput) (dto.UpdateItemInput, error) {
+			wantCurrent := dto.UpdateItemInput{Name: "book", Price: 1000, Description: "used", ShippingPayer: models.ShippingPayerSeller, ShipsWithinDays: 3}
+			if current != wantCurrent {
+				t.Errorf("current = %+v, want %+v", current, wantCurrent)
+			}
+			current.Description = ""
+			return current, nil
+		})
+		if err != nil {
+			t.Fatalf("Patch: %v", err)
+		}
+	})
+
+	t.Run("returns the error of the patch as is", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockIItemRepository(ctrl)
+		found := stored
+		repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+		patchErr := errors.New("test failed")
+
+		_, err := services.NewItemService(repo, nil, nil, time.Minute).Patch(context.Background(), 1, 7, 2, func(current dto.UpdateItemInput) (dto.UpdateItemInput, error) {
+			return current, patchErr
+		})
+		if !errors.Is(err, patchErr) {
+			t.Fatalf("err = %v, want %v", err, patchErr)
+		}
+	})
+
+	t.Run("stale version does not apply the patch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockIItemRepository(ctrl)
+		found := stored
+		repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+
+		_, err := services.NewItemService(repo, nil, nil, time.Minute).Patch(context.Background(), 1, 7, 1, func(current dto.UpdateItemInput) (dto.UpdateItemInput, error) {
+			t.Error("the patch was applied to a stale version")
+			return current, nil
+		})
+		if !errors.Is(err, services.ErrItemModified) {
+			t.Fatalf("err = %v, want ErrItemModified", err)
+		}
+	})
+
+	t.Run("another user's item does not apply the patch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mocks.NewMockIItemRepository(ctrl)
+		found := stored
+		repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+
+		_, err := services.NewItemService(repo, nil, nil, time.Minute).Patch(context.Background(), 1, 8, 2, func(current dto.UpdateItemInput) (dto.UpdateItemInput, error) {
+			t.Error("the patch was applied to another user's item")
+			return current, nil
+		})
+		if !errors.Is(err, services.ErrForbidden) {
+			t.Fatalf("err = %v, want ErrForbidden", err)
+		}
+	})
+}
+
+func TestItemServiceDelete(t *testing.T) {
+	stored := models.Item{Model: gorm.Model{ID: 1}, Name: "book", Price: 1000, UserId: 7}
+	cases := []struct {
+		name   string
+		userId uint
+		expect func(repo *mocks.MockIItemRepository)
+		err    error
+	}{
+		{
+			name: "deleted", userId: 7,
+			expect: func(repo *mocks.MockIItemRepository) {
+				found := stored
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+				repo.EXPECT().Delete(gomock.Any(), uint(1)).Return(nil)
+			},
+		},
+		{
+			name: "not found", userId: 7,
+			expect: func(repo *mocks.MockIItemRepository) {
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(nil, errors.New("Item is not found"))
+			},
+			err: errors.New("Item is not found"),
+		},
+		{
+			// 出品者以外は削除できない
+			name: "another user's item", userId: 8,
+			expect: func(repo *mocks.MockIItemRepository) {
+				found := stored
+				repo.EXPECT().FindById(gomock.Any(), uint(1)).Return(&found, nil)
+			},
+			err: services.ErrForbidden,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockIItemRepository(ctrl)
+			tc.expect(repo)
+
+			err := services.NewItemService(repo, nil, nil, time.Minute).Delete(context.Background(), 1, tc.userId)
+			if (err == nil) != (tc.err == nil) || (err != nil && err.Error() != tc.err.Error()) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
+
+// 期限切れの合意が残っていても、新しい合意で確保された商品は他のユーザーがホールドできない
+func TestItemServiceHoldAfterReaccept(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	itemId := reacceptedItem(t, store)
+	service := services.NewItemService(repositories.NewItemMemoryRepository(store), repositories.NewReservationMemoryRepository(store), repositories.NewCategoryMemoryRepository(store), 15*time.Minute)
+
+	if _, err := service.Hold(ctx, itemId, reacceptOtherId); !errors.Is(err, services.ErrItemReserved) {
+		t.Fatalf("Hold(other) err = %v, want ErrItemReserved", err)
+	}
+	if _, err := service.Hold(ctx, itemId, reacceptSecondBuyerId); err != nil {
+		t.Fatalf("Hold(second buyer) err = %v, want nil", err)
+	}
+}
+
+// 未分類（0）か登録済みのカテゴリだけを指定できる
+func TestItemServiceCategory(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	categories := repositories.NewCategoryMemoryRepository(store)
+	books, err := categories.Create(ctx, models.Category{Name: "本"})
+	if err != nil {
+		t.Fatalf("Create category: %v", err)
+	}
+	service := services.NewItemService(repositories.NewItemMemoryRepository(store), repositories.NewReservationMemoryRepository(store), categories, time.Minute)
+
+	if _, err := service.Create(ctx, dto.CreateItemInput{Name: "book", Price: 1000, CategoryId: 99}, 7); !errors.Is(err, services.ErrCategoryNotFound) {
+		t.Fatalf("Create(unknown category) err = %v, want ErrCategoryNotFound", err)
+	}
+	item, err := service.Create(ctx, dto.CreateItemInput{Name: "book", Price: 1000, CategoryId: books.ID}, 7)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if item.CategoryId != books.ID {
+		t.Fatalf("CategoryId = %d, want %d", item.CategoryId, books.ID)
+	}
+
+	if _, err := service.Update(ctx, item.ID, 7, 0, dto.UpdateItemInput{Name: "book", Price: 1000, CategoryId: 99}); !errors.Is(err, services.ErrCategoryNotFound) {
+		t.Fatalf("Update(unknown category) err = %v, want ErrCategoryNotFound", err)
+	}
+	// 未分類に戻すのはいつでもできる
+	updated, err := service.Update(ctx, item.ID, 7, 0, dto.UpdateItemInput{Name: "book", Price: 1000})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.CategoryId != 0 {
+		t.Fatalf("CategoryId = %d, want 0", updated.CategoryId)
+	}
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gin-freemarket/models"
+)
+
+var ErrLedgerUnbalanced = errors.New("Ledger transaction is unbalanced")
+
+// プラットフォーム側の勘定
+const (
+	accountPlatformClearing = "platform:clearing" // 購入者から受け取り、まだ出品者に渡していないお金
+	accountPlatformFees     = "platform:fees"     // 手数料収入
+	accountPlatformPayouts  = "platform:payouts"  // 出品者への振込待ち
+)
+
+// 販売手数料の設定。手数料 = max(販売価格 × RateBasisPoints / 10000, Minimum)（販売価格を上限とする）
+type FeeSchedule struct {
+	RateBasisPoints uint // 1bp = 0.01%。10%なら1000
+	Minimum         uint
+}
+
+func (f FeeSchedule) Fee(price uint) uint {
+	// 端数は切り捨て（出品者に有利な方）
+	fee := uint(uint64(price) * uint64(f.RateBasisPoints) / 10000)
+	if fee < f.Minimum {
+		fee = f.Minimum
+	}
+	if fee > price {
+		fee = price
+	}
+	return fee
+}
+
+// 出品者ごとの勘定。pendingは取引完了待ち、availableは出金可能な売上
+func sellerPendingAccount(userId uint) models.LedgerAccount {
+	return models.LedgerAccount{Name: fmt.Sprintf("seller:%d:pending", userId), UserId: &userId}
+}
+
+func sellerAvailableAccount(userId uint) models.LedgerAccount {
+	return models.LedgerAccount{Name: fmt.Sprintf("seller:%d:available", userId), UserId: &userId}
+}
+
+func debit(account models.LedgerAccount, amount uint) models.LedgerEntry {
+	return models.LedgerEntry{Account: account, Direction: models.LedgerDebit, Amount: amount}
+}
+
+func credit(account models.LedgerAccount, amount uint) models.LedgerEntry {
+	return models.LedgerEntry{Account: account, Direction: models.LedgerCredit, Amount: amount}
+}
+
+// 仕訳を組み立てる。金額0の明細は除き、借方と貸方が一致しなければエラーにする
+func newLedgerTransaction(reference string, description string, entries ...models.LedgerEntry) (*models.LedgerTransaction, error) {
+	var debits, credits uint64
+	nonZero := []models.LedgerEntry{}
+	for _, v := range entries {
+		if v.Amount == 0 {
+			continue
+		}
+		if v.Direction == models.LedgerDebit {
+			debits += uint64(v.Amount)
+		} else {
+			credits += uint64(v.Amount)
+		}
+		nonZero = append(nonZero, v)
+	}
+	if debits != credits || debits == 0 {
+		return nil, ErrLedgerUnbalanced
+	}
+
+	return &models.LedgerTransaction{
+		Reference:   reference,
+		Description: description,
+		Entries:     nonZero,
+	}, nil
+}
+
+// 決済確定: 受け取った代金を出品者の取引完了待ちの売上として計上する
+func ledgerForOrderPaid(order *models.Order) (*models.LedgerTransaction, error) {
+	return newLedgerTransaction(orderReference(order), "order paid",
+		debit(models.LedgerAccount{Name: accountPlatformClearing}, order.Total()),
+		credit(sellerPendingAccount(order.SellerId), order.Total()),
+	)
+}
+
+// 返金: 決済確定時の仕訳を取り消す
+func ledgerForOrderRefunded(order *models.Order) (*models.LedgerTransaction, error) {
+	return newLedgerTransaction(orderReference(order), "order refunded",
+		debit(sellerPendingAccount(order.SellerId), order.Total()),
+		credit(models.LedgerAccount{Name: accountPlatformClearing}, order.Total()),
+	)
+}
+
+// 取引完了: 手数料を差し引いた金額を出金可能な売上に振り替える
+// 購入者負担の送料は発送した出品者にそのまま渡す（手数料は商品代金にだけかかる）
+func ledgerForOrderCompleted(order *models.Order) (*models.LedgerTransaction, error) {
+	return newLedgerTransaction(orderReference(order), "order completed",
+		debit(sellerPendingAccount(order.SellerId), order.Total()),
+		credit(sellerAvailableAccount(order.SellerId), order.Total()-order.Fee),
+		credit(models.LedgerAccount{Name: accountPlatformFees}, order.Fee),
+	)
+}
+
+func orderReference(order *models.Order) string {
+	return fmt.Sprintf("order:%d", order.ID)
+}
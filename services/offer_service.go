@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"time"
+)
+
+var (
+	ErrForbidden          = errors.New("Forbidden")
+	ErrOfferPriceInvalid  = errors.New("Offer price is invalid")
+	ErrOfferAlreadyExists = errors.New("Offer already exists")
+	ErrOfferNotOpen       = errors.New("Offer is not open")
+	ErrOfferExpired       = errors.New("Offer is expired")
+)
+
+type IOfferService interface {
+	FindByItem(ctx context.Context, itemId uint, userId uint) (*[]models.Offer, error)
+	Create(ctx context.Context, itemId uint, buyerId uint, createOfferInput dto.CreateOfferInput) (*models.Offer, error)
+	Accept(ctx context.Context, offerId uint, userId uint) (*models.Offer, error)
+	Reject(ctx context.Context, offerId uint, userId uint) (*models.Offer, error)
+	Counter(ctx context.Context, offerId uint, userId uint, counterOfferInput dto.CounterOfferInput) (*models.Offer, error)
+}
+
+type OfferService struct {
+	repository     repositories.IOfferRepository
+	itemRepository repositories.IItemRepository
+	// オファーの有効期限。回答待ちの間と、合意後に購入されるまでの確保期間の両方に使う
+	ttl time.Duration
+}
+
+func NewOfferService(repository repositories.IOfferRepository, itemRepository repositories.IItemRepository, ttl time.Duration) IOfferService {
+	return &OfferService{repository: repository, itemRepository: itemRepository, ttl: ttl}
+}
+
+func (s *OfferService) FindByItem(ctx context.Context, itemId uint, userId uint) (*[]models.Offer, error) {
+	item, err := s.itemRepository.FindById(ctx, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	offers, err := s.repository.FindByItem(ctx, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 出品者は全件、それ以外のユーザーは自分のオファーのみ見られる
+	if item.UserId == userId {
+		return offers, nil
+	}
+	mine := []models.Offer{}
+	for _, v := range *offers {
+		if v.BuyerId == userId {
+			mine = append(mine, v)
+		}
+	}
+	return &mine, nil
+}
+
+func (s *OfferService) Create(ctx context.Context, itemId uint, buyerId uint, createOfferInput dto.CreateOfferInput) (*models.Offer, error) {
+	now := time.Now()
+	newOffer := models.Offer{
+		ItemId:    itemId,
+		BuyerId:   buyerId,
+		Price:     createOfferInput.Price,
+		Status:    models.OfferStatusPending,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	return s.repository.Create(ctx, newOffer, func(item *models.Item, offers []models.Offer) error {
+		if item.SoldOut {
+			return ErrItemUnavailable
+		}
+		if item.UserId == buyerId {
+			return ErrOwnItem
+		}
+		// 値下げ交渉なので、定価以上の提示は受け付けない
+		if createOfferInput.Price >= item.Price {
+			return ErrOfferPriceInvalid
+		}
+		for _, v := range offers {
+			if now.After(v.ExpiresAt) {
+				continue
+			}
+			if v.Status == models.OfferStatusAccepted {
+				return ErrItemUnavailable
+			}
+			if v.IsOpen() && v.BuyerId == buyerId {
+				return ErrOfferAlreadyExists
+			}
+		}
+		return nil
+	})
+}
+
+func (s *OfferService) Accept(ctx context.Context, offerId uint, userId uint) (*models.Offer, error) {
+	return s.transition(ctx, offerId, func(offer *models.Offer, item *models.Item, now time.Time) error {
+		if item.SoldOut {
+			return ErrItemUnavailable
+		}
+		if respondent(offer, item) != userId {
+			return ErrForbidden
+		}
+
+		// pendingなら購入希望者の提示額、counteredなら出品者の提示額で合意
+		agreedPrice := offer.Price
+		if offer.Status == models.OfferStatusCountered {
+			agreedPrice = *offer.CounterPrice
+		}
+		offer.AgreedPrice = &agreedPrice
+		offer.Status = models.OfferStatusAccepted
+		// 合意後は、この期限までこの購入希望者のために商品を確保しておく
+		offer.ExpiresAt = now.Add(s.ttl)
+		return nil
+	})
+}
+
+func (s *OfferService) Reject(ctx context.Context, offerId uint, userId uint) (*models.Offer, error) {
+	return s.transition(ctx, offerId, func(offer *models.Offer, item *models.Item, now time.Time) error {
+		if respondent(offer, item) != userId {
+			return ErrForbidden
+		}
+		offer.Status = models.OfferStatusRejected
+		return nil
+	})
+}
+
+func (s *OfferService) Counter(ctx context.Context, offerId uint, userId uint, counterOfferInput dto.CounterOfferInput) (*models.Offer, error) {
+	return s.transition(ctx, offerId, func(offer *models.Offer, item *models.Item, now time.Time) error {
+		if item.SoldOut {
+			return ErrItemUnavailable
+		}
+		// 逆提示できるのは出品者だけで、やり取りは1往復まで
+		if item.UserId != userId {
+			return ErrForbidden
+		}
+		if offer.Status != models.OfferStatusPending {
+			return ErrOfferNotOpen
+		}
+		// 逆提示額は「購入希望者の提示額 < 逆提示額 < 定価」の範囲でなければならない
+		if counterOfferInput.Price <= offer.Price || counterOfferInput.Price >= item.Price {
+			return ErrOfferPriceInvalid
+		}
+
+		counterPrice := counterOfferInput.Price
+		offer.CounterPrice = &counterPrice
+		offer.Status = models.OfferStatusCountered
+		offer.ExpiresAt = now.Add(s.ttl)
+		return nil
+	})
+}
+
+// 回答待ちのオファーに対する状態遷移の共通処理。
+// 期限切れのオファーはexpiredに更新したうえでErrOfferExpiredを返す
+func (s *OfferService) transition(ctx context.Context, offerId uint, apply func(offer *models.Offer, item *models.Item, now time.Time) error) (*models.Offer, error) {
+	now := time.Now()
+	expired := false
+
+	offer, err := s.repository.Transition(ctx, offerId, func(offer *models.Offer, item *models.Item) error {
+		if !offer.IsOpen() {
+			return ErrOfferNotOpen
+		}
+		if now.After(offer.ExpiresAt) {
+			// ここでエラーを返すとロールバックされてしまうので、状態だけ変えて保存させる
+			offer.Status = models.OfferStatusExpired
+			expired = true
+			return nil
+		}
+		return apply(offer, item, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, ErrOfferExpired
+	}
+	return offer, nil
+}
+
+// 現在そのオファーに回答すべきユーザー（pendingなら出品者、counteredなら購入希望者）
+func respondent(offer *models.Offer, item *models.Item) uint {
+	if offer.Status == models.OfferStatusCountered {
+		return offer.BuyerId
+	}
+	return item.UserId
+}
@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"testing"
+	"time"
+)
+
+const (
+	reacceptSellerId      = 1
+	reacceptFirstBuyerId  = 2
+	reacceptSecondBuyerId = 3
+	reacceptOtherId       = 4
+)
+
+// 最初の購入希望者との合意の確保期限が切れた後、2人目の購入希望者と900円で合意し直した商品を作る
+func reacceptedItem(t *testing.T, store *repositories.MemoryStore) uint {
+	t.Helper()
+	ctx := context.Background()
+	offerRepository := repositories.NewOfferMemoryRepository(store)
+	itemRepository := repositories.NewItemMemoryRepository(store)
+	service := services.NewOfferService(offerRepository, itemRepository, time.Hour)
+
+	item, err := itemRepository.Create(ctx, models.Item{Name: "book", Price: 1000, UserId: reacceptSellerId})
+	if err != nil {
+		t.Fatalf("Create item: %v", err)
+	}
+	first, err := service.Create(ctx, item.ID, reacceptFirstBuyerId, dto.CreateOfferInput{Price: 800})
+	if err != nil {
+		t.Fatalf("Create first offer: %v", err)
+	}
+	if _, err := service.Accept(ctx, first.ID, reacceptSellerId); err != nil {
+		t.Fatalf("Accept first offer: %v", err)
+	}
+	// 最初の合意の確保期限を切らせる
+	if _, err := offerRepository.Transition(ctx, first.ID, func(offer *models.Offer, item *models.Item) error {
+		offer.ExpiresAt = time.Now().Add(-time.Minute)
+		return nil
+	}); err != nil {
+		t.Fatalf("expire first offer: %v", err)
+	}
+
+	second, err := service.Create(ctx, item.ID, reacceptSecondBuyerId, dto.CreateOfferInput{Price: 900})
+	if err != nil {
+		t.Fatalf("Create second offer: %v", err)
+	}
+	if _, err := service.Accept(ctx, second.ID, reacceptSellerId); err != nil {
+		t.Fatalf("Accept second offer: %v", err)
+	}
+	return item.ID
+}
+
+// 回答待ちのオファーへの回答。誰が・どの状態のオファーに回答できるかを確認する
+func TestOfferServiceTransitions(t *testing.T) {
+	const sellerId, buyerId, otherId = 1, 2, 3
+	ctx := context.Background()
+
+	type action func(service services.IOfferService, offerId uint) (*models.Offer, error)
+	accept := func(userId uint) action {
+		return func(service services.IOfferService, offerId uint) (*models.Offer, error) {
+			return service.Accept(ctx, offerId, userId)
+		}
+	}
+	reject := func(userId uint) action {
+		return func(service services.IOfferService, offerId uint) (*models.Offer, error) {
+			return service.Reject(ctx, offerId, userId)
+		}
+	}
+	counter := func(userId uint, price uint) action {
+		return func(service services.IOfferService, offerId uint) (*models.Offer, error) {
+			return service.Counter(ctx, offerId, userId, dto.CounterOfferInput{Price: price})
+		}
+	}
+	// 確保期限を切らせる（状態はまだ変わらない）
+	expire := func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint) {
+		t.Helper()
+		if _, err := repository.Transition(ctx, offerId, func(offer *models.Offer, item *models.Item) error {
+			offer.ExpiresAt = time.Now().Add(-time.Minute)
+			return nil
+		}); err != nil {
+			t.Fatalf("expire offer: %v", err)
+		}
+	}
+	// stepsを順に実行して、オファーを回答前の状態に進める
+	then := func(steps ...action) func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint) {
+		return func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint) {
+			t.Helper()
+			for _, step := range steps {
+				if _, err := step(service, offerId); err != nil {
+					t.Fatalf("setup: %v", err)
+				}
+			}
+		}
+	}
+
+	// 各ケースは、出品者の1000円の商品への購入希望者の800円のオファーから始まる
+	cases := []struct {
+		name       string
+		setup      func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint)
+		action     action
+		wantErr    error
+		wantStatus string
+	}{
+		{name: "seller accepts a pending offer", action: accept(sellerId), wantStatus: models.OfferStatusAccepted},
+		{name: "buyer accepts a counter offer", setup: then(counter(sellerId, 900)), action: accept(buyerId), wantStatus: models.OfferStatusAccepted},
+		{name: "seller rejects a pending offer", action: reject(sellerId), wantStatus: models.OfferStatusRejected},
+		{name: "seller counters a pending offer", action: counter(sellerId, 900), wantStatus: models.OfferStatusCountered},
+
+		// 回答できるのは、回答待ちになっている側だけ
+		{name: "buyer accepts their own pending offer", action: accept(buyerId), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusPending},
+		{name: "another user accepts a pending offer", action: accept(otherId), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusPending},
+		{name: "another user rejects a pending offer", action: reject(otherId), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusPending},
+		{name: "seller accepts their own counter offer", setup: then(counter(sellerId, 900)), action: accept(sellerId), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusCountered},
+		{name: "buyer counters their own offer", action: counter(buyerId, 900), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusPending},
+		{name: "another user counters a pending offer", action: counter(otherId, 900), wantErr: services.ErrForbidden, wantStatus: models.OfferStatusPending},
+
+		// 逆提示は1往復まで
+		{name: "seller counters a counter offer", setup: then(counter(sellerId, 900)), action: counter(sellerId, 950), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusCountered},
+		{name: "seller counters at the offered price", action: counter(sellerId, 800), wantErr: services.ErrOfferPriceInvalid, wantStatus: models.OfferStatusPending},
+		{name: "seller counters at the list price", action: counter(sellerId, 1000), wantErr: services.ErrOfferPriceInvalid, wantStatus: models.OfferStatusPending},
+
+		// 回答済みのオファーには回答できない
+		{name: "seller accepts an accepted offer", setup: then(accept(sellerId)), action: accept(sellerId), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusAccepted},
+		{name: "seller rejects an accepted offer", setup: then(accept(sellerId)), action: reject(sellerId), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusAccepted},
+		{name: "seller accepts a rejected offer", setup: then(reject(sellerId)), action: accept(sellerId), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusRejected},
+		{name: "seller counters a rejected offer", setup: then(reject(sellerId)), action: counter(sellerId, 900), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusRejected},
+
+		// 期限切れのオファーはexpiredになり、以降は回答できない
+		{name: "seller accepts an expired offer", setup: expire, action: accept(sellerId), wantErr: services.ErrOfferExpired, wantStatus: models.OfferStatusExpired},
+		{name: "buyer accepts an expired counter offer", setup: func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint) {
+			then(counter(sellerId, 900))(t, service, repository, offerId)
+			expire(t, service, repository, offerId)
+		}, action: accept(buyerId), wantErr: services.ErrOfferExpired, wantStatus: models.OfferStatusExpired},
+		{name: "seller rejects an expired offer", setup: expire, action: reject(sellerId), wantErr: services.ErrOfferExpired, wantStatus: models.OfferStatusExpired},
+		{name: "seller accepts an offer already marked expired", setup: func(t *testing.T, service services.IOfferService, repository repositories.IOfferRepository, offerId uint) {
+			expire(t, service, repository, offerId)
+			if _, err := service.Accept(ctx, offerId, sellerId); !errors.Is(err, services.ErrOfferExpired) {
+				t.Fatalf("setup: err = %v, want ErrOfferExpired", err)
+			}
+		}, action: accept(sellerId), wantErr: services.ErrOfferNotOpen, wantStatus: models.OfferStatusExpired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := repositories.NewMemoryStore()
+			offerRepository := repositories.NewOfferMemoryRepository(store)
+			itemRepository := repositories.NewItemMemoryRepository(store)
+			service := services.NewOfferService(offerRepository, itemRepository, time.Hour)
+			item, err := itemRepository.Create(ctx, models.Item{Name: "book", Price: 1000, UserId: sellerId})
+			if err != nil {
+				t.Fatalf("Create item: %v", err)
+			}
+			offer, err := service.Create(ctx, item.ID, buyerId, dto.CreateOfferInput{Price: 800})
+			if err != nil {
+				t.Fatalf("Create offer: %v", err)
+			}
+			if tc.setup != nil {
+				tc.setup(t, service, offerRepository, offer.ID)
+			}
+
+			_, err = tc.action(service, offer.ID)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+			found, err := offerRepository.FindById(ctx, offer.ID)
+			if err != nil {
+				t.Fatalf("FindById: %v", err)
+			}
+			if found.Status != tc.wantStatus {
+				t.Fatalf("Status = %q, want %q", found.Status, tc.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"gin-freemarket/logging"
+	"log/slog"
+	"time"
+)
+
+// 配達完了から猶予期間が過ぎた注文を定期的に取引完了にするバックグラウンド処理
+type OrderAutoCompleter struct {
+	periodicWorker
+	orderService IOrderService
+	gracePeriod  time.Duration
+}
+
+func NewOrderAutoCompleter(orderService IOrderService, gracePeriod time.Duration, interval time.Duration) *OrderAutoCompleter {
+	c := &OrderAutoCompleter{orderService: orderService, gracePeriod: gracePeriod}
+	c.periodicWorker = periodicWorker{interval: interval, run: c.completeDelivered}
+	return c
+}
+
+func (c *OrderAutoCompleter) completeDelivered(ctx context.Context, now time.Time) {
+	completed, err := c.orderService.CompleteDelivered(ctx, now.Add(-c.gracePeriod))
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to complete delivered orders", slog.Any("error", err))
+	}
+	if completed > 0 {
+		logging.FromContext(ctx).Info("Completed delivered orders", slog.Int("count", completed))
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/logging"
+	"gin-freemarket/metrics"
+	"gin-freemarket/models"
+	"gin-freemarket/payments"
+	"gin-freemarket/repositories"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrOrderAlreadyExists = errors.New("Order already exists")
+	ErrOrderStatus        = errors.New("Order cannot be changed in its current status")
+)
+
+type IOrderService interface {
+	FindById(ctx context.Context, orderId uint, userId uint) (*models.Order, error)
+	// 注文を作成し、決済プロバイダに支払いを作成する。
+	// 支払いが完了したかどうかはWebhook（HandlePaymentEvent）で反映される
+	Purchase(ctx context.Context, itemId uint, buyerId uint, purchaseInput dto.PurchaseInput) (*models.Order, *payments.PaymentIntent, error)
+	// 支払い前ならキャンセルし、支払い後なら返金を依頼する（返金されたことはWebhookで反映される）
+	Cancel(ctx context.Context, orderId uint, userId uint) (*models.Order, error)
+	// 購入者が受け取りを確認し、取引を完了する。出品者の売上が出金可能になる
+	Complete(ctx context.Context, orderId uint, userId uint) (*models.Order, error)
+	// 配達完了から一定期間が過ぎても受け取り確認がない注文を、取引完了にする。完了させた件数を返す
+	CompleteDelivered(ctx context.Context, before time.Time) (int, error)
+	// 作成から一定期間が過ぎても支払われない注文をキャンセルし、商品を他のユーザーが買えるようにする。キャンセルした件数を返す
+	CancelExpiredPending(ctx context.Context, before time.Time) (int, error)
+	HandlePaymentEvent(ctx context.Context, event payments.WebhookEvent) error
+}
+
+type OrderService struct {
+	repository     repositories.IOrderRepository
+	addressService IAddressService
+	gateway        payments.PaymentGateway
+	fees           FeeSchedule
+}
+
+func NewOrderService(repository repositories.IOrderRepository, addressService IAddressService, gateway payments.PaymentGateway, fees FeeSchedule) IOrderService {
+	return &OrderService{
+		repository:     repository,
+		addressService: addressService,
+		gateway:        gateway,
+		fees:           fees,
+	}
+}
+
+func (s *OrderService) FindById(ctx context.Context, orderId uint, userId uint) (*models.Order, error) {
+	order, err := s.repository.FindById(ctx, orderId)
+	if err != nil {
+		return nil, err
+	}
+	// 注文を見られるのは購入者と出品者だけ
+	if order.BuyerId != userId && order.SellerId != userId {
+		return nil, ErrForbidden
+	}
+	return order, nil
+}
+
+func (s *OrderService) Purchase(ctx context.Context, itemId uint, buyerId uint, purchaseInput dto.PurchaseInput) (*models.Order, *payments.PaymentIntent, error) {
+	// 住所は購入者本人のもので、値としてコピーするだけなので、ロックの外で読んでよい
+	address, err := s.addressService.FindForOrder(ctx, purchaseInput.AddressId, buyerId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newOrder := models.Order{
+		ItemId:  itemId,
+		BuyerId: buyerId,
+		Status:  models.OrderStatusPending,
+		// 住所は値としてコピーするので、後で住所を編集しても注文の送り先は変わらない
+		ShipTo: address.ShippingAddress,
+	}
+	// 商品・ホールド・オファーは商品をロックした後の状態で確認し、金額もそこから決める
+	// （先に読んでおくと、ロックを取るまでの間に他のユーザーがホールドや合意をしても気づけない）
+	order, err := s.repository.Create(ctx, newOrder, time.Now(), func(order *models.Order, item *models.Item, orders []models.Order, active *models.Reservation, accepted *models.Offer) error {
+		if item.SoldOut {
+			return ErrItemUnavailable
+		}
+		if item.UserId == buyerId {
+			return ErrOwnItem
+		}
+		for _, v := range orders {
+			if v.Status == models.OrderStatusPending && v.BuyerId == buyerId {
+				return ErrOrderAlreadyExists
+			}
+			if v.IsOpen() {
+				return ErrItemReserved
+			}
+		}
+		// 他のユーザーがホールド中なら買えない
+		if active != nil && active.UserId != buyerId {
+			return ErrItemReserved
+		}
+
+		// オファーで合意していればその金額になる。他の購入希望者と合意済みなら買えない
+		order.Price = item.Price
+		if accepted != nil {
+			if accepted.BuyerId != buyerId {
+				return ErrItemReserved
+			}
+			order.Price = *accepted.AgreedPrice
+		}
+		order.SellerId = item.UserId
+		if item.ShippingPayer == models.ShippingPayerBuyer {
+			order.ShippingFee = item.ShippingFee
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	intent, err := s.gateway.CreateIntent(ctx, order.ID, order.Total())
+	if err != nil {
+		// 支払いを作れなかった注文は残しておいても意味がないのでキャンセル扱いにする
+		// 支払い待ちのまま残ると商品が売れなくなるので、リクエストのctxがタイムアウトしていても保存する
+		order.Status = models.OrderStatusCanceled
+		if _, cancelErr := s.repository.Update(context.WithoutCancel(ctx), *order); cancelErr != nil {
+			logging.FromContext(ctx).Error("Failed to cancel order without payment",
+				slog.Uint64("order_id", uint64(order.ID)), slog.Any("error", cancelErr))
+		}
+		return nil, nil, err
+	}
+
+	order.PaymentIntentId = intent.ID
+	order, err = s.repository.Update(ctx, *order)
+	if err != nil {
+		return nil, nil, err
+	}
+	metrics.OrdersPlacedTotal.Inc()
+	return order, intent, nil
+}
+
+func (s *OrderService) Cancel(ctx context.Context, orderId uint, userId uint) (*models.Order, error) {
+	order, err := s.repository.Transition(ctx, orderId, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		switch {
+		case order.BuyerId != userId && order.SellerId != userId:
+			return nil, ErrForbidden
+		case order.Status == models.OrderStatusPending:
+			order.Status = models.OrderStatusCanceled
+			return nil, nil
+		case order.Status == models.OrderStatusPaid || order.Status == models.OrderStatusRefundRequested:
+			// 支払い後の取り消しは出品者だけができ、返金となる。
+			// 返金の結果はWebhookで届くので、ここでは依頼中にするだけで商品や帳簿はまだ戻さない
+			// （返金の依頼に失敗していたら、もう一度呼べば依頼し直す）
+			if order.SellerId != userId {
+				return nil, ErrForbidden
+			}
+			order.Status = models.OrderStatusRefundRequested
+			return nil, nil
+		default:
+			return nil, ErrOrderStatus
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 決済プロバイダへの依頼はロックを外してから行う
+	if order.Status == models.OrderStatusRefundRequested {
+		if _, err := s.gateway.Refund(ctx, order.PaymentIntentId); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func (s *OrderService) Complete(ctx context.Context, orderId uint, userId uint) (*models.Order, error) {
+	return s.repository.Transition(ctx, orderId, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		if order.BuyerId != userId {
+			return nil, ErrForbidden
+		}
+		// 配達完了の連絡を待たずに、購入者が受け取りを確認してもよい
+		if order.Status != models.OrderStatusShipped && order.Status != models.OrderStatusDelivered {
+			return nil, ErrOrderStatus
+		}
+		return s.complete(order)
+	})
+}
+
+func (s *OrderService) CompleteDelivered(ctx context.Context, before time.Time) (int, error) {
+	orders, err := s.repository.FindDeliveredBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, v := range *orders {
+		_, err := s.repository.Transition(ctx, v.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			// 検索してからロックを取るまでの間に、購入者が受け取り確認をしているかもしれない
+			if order.Status != models.OrderStatusDelivered {
+				return nil, ErrOrderStatus
+			}
+			return s.complete(order)
+		})
+		if err != nil {
+			if errors.Is(err, ErrOrderStatus) {
+				continue
+			}
+			return completed, err
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+func (s *OrderService) CancelExpiredPending(ctx context.Context, before time.Time) (int, error) {
+	orders, err := s.repository.FindPendingBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	canceled := 0
+	for _, v := range *orders {
+		_, err := s.repository.Transition(ctx, v.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+			// 検索してからロックを取るまでの間に、支払いが完了しているかもしれない
+			if order.Status != models.OrderStatusPending {
+				return nil, ErrOrderStatus
+			}
+			order.Status = models.OrderStatusCanceled
+			return nil, nil
+		})
+		if err != nil {
+			if errors.Is(err, ErrOrderStatus) {
+				continue
+			}
+			return canceled, err
+		}
+		canceled++
+	}
+	return canceled, nil
+}
+
+func (s *OrderService) complete(order *models.Order) (*models.LedgerTransaction, error) {
+	order.Status = models.OrderStatusCompleted
+	order.Fee = s.fees.Fee(order.Price)
+	return ledgerForOrderCompleted(order)
+}
+
+func (s *OrderService) HandlePaymentEvent(ctx context.Context, event payments.WebhookEvent) error {
+	record := models.WebhookEvent{
+		Provider: "payments",
+		EventId:  event.ID,
+		Type:     event.Type,
+	}
+
+	// 重複して届いたイベントはリポジトリ側で読み飛ばされるので、ここでは成功として扱う
+	order, _, err := s.repository.ApplyPaymentEvent(ctx, record, event.Data.PaymentIntentId, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		// 各イベントは想定した状態のときだけ反映する。
+		// 順番が前後して届いた場合や既に反映済みの場合は何もしない
+		switch event.Type {
+		case payments.EventPaymentAuthorized:
+			// 確定はロックを外してから依頼し、結果はEventPaymentSucceededで反映する
+			if order.Status == models.OrderStatusPending {
+				order.Status = models.OrderStatusCaptureRequested
+			}
+		case payments.EventPaymentSucceeded:
+			if order.Status == models.OrderStatusCaptureRequested {
+				order.Status = models.OrderStatusPaid
+				item.SoldOut = true
+				return ledgerForOrderPaid(order)
+			}
+		case payments.EventPaymentFailed:
+			if order.Status == models.OrderStatusPending || order.Status == models.OrderStatusCaptureRequested {
+				order.Status = models.OrderStatusCanceled
+			}
+		case payments.EventPaymentRefunded:
+			if order.Status == models.OrderStatusPaid || order.Status == models.OrderStatusRefundRequested {
+				order.Status = models.OrderStatusRefunded
+				item.SoldOut = false
+				return ledgerForOrderRefunded(order)
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 決済プロバイダへの依頼はロックを外してから行う。
+	// 依頼に失敗したらエラーを返すので、プロバイダがイベントを再送したときに（重複として記録済みでも）依頼し直す
+	if event.Type != payments.EventPaymentAuthorized {
+		return nil
+	}
+	switch order.Status {
+	case models.OrderStatusCaptureRequested:
+		_, err = s.gateway.Capture(ctx, order.PaymentIntentId)
+	case models.OrderStatusCanceled:
+		// 期限切れやキャンセルの後に支払われた。確定せずに取り消し、購入者の与信枠を解放する
+		_, err = s.gateway.Void(ctx, order.PaymentIntentId)
+	}
+	return err
+}
@@ -0,0 +1,376 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/payments"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 支払いの作成に失敗する決済プロバイダ
+// TimeoutMiddlewareでリクエストが打ち切られた場合を再現するため、失敗する前にリクエストのctxをキャンセルする
+type failingGateway struct {
+	payments.PaymentGateway
+	cancel context.CancelFunc
+}
+
+func (g failingGateway) CreateIntent(ctx context.Context, orderId uint, amount uint) (*payments.PaymentIntent, error) {
+	g.cancel()
+	return nil, context.DeadlineExceeded
+}
+
+func newOrderService(store *repositories.MemoryStore, gateway payments.PaymentGateway) services.IOrderService {
+	addressService := services.NewAddressService(repositories.NewAddressMemoryRepository(store))
+	return services.NewOrderService(repositories.NewOrderMemoryRepository(store), addressService, gateway, services.FeeSchedule{RateBasisPoints: 1000})
+}
+
+// 購入できるよう、ユーザーに既定の住所を登録する
+func registerAddress(t *testing.T, store *repositories.MemoryStore, userIds ...uint) {
+	t.Helper()
+	addressService := services.NewAddressService(repositories.NewAddressMemoryRepository(store))
+	for _, userId := range userIds {
+		_, err := addressService.Create(context.Background(), userId, dto.AddressInput{
+			Name: "Buyer", PostalCode: "1000001", Prefecture: "Tokyo", City: "Chiyoda", Line1: "1-1", Phone: "0312345678", IsDefault: true,
+		})
+		if err != nil {
+			t.Fatalf("Create address: %v", err)
+		}
+	}
+}
+
+func createItem(t *testing.T, store *repositories.MemoryStore, sellerId uint) uint {
+	t.Helper()
+	item, err := repositories.NewItemMemoryRepository(store).Create(context.Background(), models.Item{Name: "book", Price: 1000, UserId: sellerId})
+	if err != nil {
+		t.Fatalf("Create item: %v", err)
+	}
+	return item.ID
+}
+
+// 合意し直した商品は、新しい合意の購入希望者だけがその金額で買える。購入すると合意は使い切られる
+func TestOrderServicePurchaseAfterReaccept(t *testing.T) {
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	itemId := reacceptedItem(t, store)
+	registerAddress(t, store, reacceptFirstBuyerId, reacceptSecondBuyerId, reacceptOtherId)
+	service := newOrderService(store, payments.NewFakePaymentGateway())
+
+	for _, buyerId := range []uint{reacceptFirstBuyerId, reacceptOtherId} {
+		if _, _, err := service.Purchase(ctx, itemId, buyerId, dto.PurchaseInput{}); !errors.Is(err, services.ErrItemReserved) {
+			t.Fatalf("Purchase(buyer %d) err = %v, want ErrItemReserved", buyerId, err)
+		}
+	}
+
+	order, _, err := service.Purchase(ctx, itemId, reacceptSecondBuyerId, dto.PurchaseInput{})
+	if err != nil {
+		t.Fatalf("Purchase(second buyer): %v", err)
+	}
+	if order.Price != 900 || order.SellerId != reacceptSellerId {
+		t.Fatalf("order = {Price: %d, SellerId: %d}, want {900 %d}", order.Price, order.SellerId, reacceptSellerId)
+	}
+	if _, err := repositories.NewOfferMemoryRepository(store).FindAccepted(ctx, itemId, time.Now()); err == nil {
+		t.Fatal("the accepted offer is still live after the purchase")
+	}
+}
+
+func TestOrderServicePurchaseHold(t *testing.T) {
+	const sellerId, buyerId, otherId = 1, 2, 3
+	ctx := context.Background()
+
+	t.Run("another user's hold blocks the purchase", func(t *testing.T) {
+		store := repositories.NewMemoryStore()
+		itemId := createItem(t, store, sellerId)
+		registerAddress(t, store, buyerId)
+		itemService := services.NewItemService(repositories.NewItemMemoryRepository(store), repositories.NewReservationMemoryRepository(store), repositories.NewCategoryMemoryRepository(store), time.Minute)
+		if _, err := itemService.Hold(ctx, itemId, otherId); err != nil {
+			t.Fatalf("Hold: %v", err)
+		}
+
+		_, _, err := newOrderService(store, payments.NewFakePaymentGateway()).Purchase(ctx, itemId, buyerId, dto.PurchaseInput{})
+		if !errors.Is(err, services.ErrItemReserved) {
+			t.Fatalf("err = %v, want ErrItemReserved", err)
+		}
+	})
+
+	t.Run("the buyer's own hold is consumed by the purchase", func(t *testing.T) {
+		store := repositories.NewMemoryStore()
+		itemId := createItem(t, store, sellerId)
+		registerAddress(t, store, buyerId)
+		reservations := repositories.NewReservationMemoryRepository(store)
+		itemService := services.NewItemService(repositories.NewItemMemoryRepository(store), reservations, repositories.NewCategoryMemoryRepository(store), time.Minute)
+		if _, err := itemService.Hold(ctx, itemId, buyerId); err != nil {
+			t.Fatalf("Hold: %v", err)
+		}
+
+		if _, _, err := newOrderService(store, payments.NewFakePaymentGateway()).Purchase(ctx, itemId, buyerId, dto.PurchaseInput{}); err != nil {
+			t.Fatalf("Purchase: %v", err)
+		}
+		if _, err := reservations.FindActive(ctx, itemId, time.Now()); err == nil {
+			t.Fatal("the hold is still active after the purchase")
+		}
+	})
+}
+
+// 支払い待ちの注文がある商品は、他のユーザーがホールドできない。注文がキャンセルされれば確保できる
+func TestItemServiceHoldWithPendingOrder(t *testing.T) {
+	const sellerId, buyerId, otherId = 1, 2, 3
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	itemId := createItem(t, store, sellerId)
+	registerAddress(t, store, buyerId)
+	orderService := newOrderService(store, payments.NewFakePaymentGateway())
+	itemService := services.NewItemService(repositories.NewItemMemoryRepository(store), repositories.NewReservationMemoryRepository(store), repositories.NewCategoryMemoryRepository(store), time.Minute)
+
+	order, _, err := orderService.Purchase(ctx, itemId, buyerId, dto.PurchaseInput{})
+	if err != nil {
+		t.Fatalf("Purchase: %v", err)
+	}
+	if _, err := itemService.Hold(ctx, itemId, otherId); !errors.Is(err, services.ErrItemReserved) {
+		t.Fatalf("Hold err = %v, want ErrItemReserved", err)
+	}
+
+	if _, err := orderService.Cancel(ctx, order.ID, buyerId); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if _, err := itemService.Hold(ctx, itemId, otherId); err != nil {
+		t.Fatalf("Hold after the cancel: %v", err)
+	}
+}
+
+// 支払いを作れなかった注文は、リクエストのctxがキャンセルされていてもキャンセル済みとして保存され、商品が売れなくならない
+func TestOrderServicePurchasePaymentFailure(t *testing.T) {
+	const sellerId, buyerId, otherId = 1, 2, 3
+	store := repositories.NewMemoryStore()
+	itemId := createItem(t, store, sellerId)
+	registerAddress(t, store, buyerId, otherId)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, _, err := newOrderService(store, failingGateway{cancel: cancel}).Purchase(ctx, itemId, buyerId, dto.PurchaseInput{}); err == nil {
+		t.Fatal("Purchase succeeded, want the payment error")
+	}
+
+	order, err := repositories.NewOrderMemoryRepository(store).FindById(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("FindById: %v", err)
+	}
+	if order.Status != models.OrderStatusCanceled {
+		t.Fatalf("Status = %q, want %q", order.Status, models.OrderStatusCanceled)
+	}
+	if _, _, err := newOrderService(store, payments.NewFakePaymentGateway()).Purchase(context.Background(), itemId, otherId, dto.PurchaseInput{}); err != nil {
+		t.Fatalf("Purchase by another buyer: %v", err)
+	}
+}
+
+// 支払われないまま期限を過ぎた注文はキャンセルされ、他のユーザーが買えるようになる
+func TestOrderServiceCancelExpiredPending(t *testing.T) {
+	const sellerId, buyerId, otherId = 1, 2, 3
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	itemId := createItem(t, store, sellerId)
+	registerAddress(t, store, buyerId, otherId)
+	service := newOrderService(store, payments.NewFakePaymentGateway())
+
+	order, _, err := service.Purchase(ctx, itemId, buyerId, dto.PurchaseInput{})
+	if err != nil {
+		t.Fatalf("Purchase: %v", err)
+	}
+	if _, _, err := service.Purchase(ctx, itemId, otherId, dto.PurchaseInput{}); !errors.Is(err, services.ErrItemReserved) {
+		t.Fatalf("Purchase while pending err = %v, want ErrItemReserved", err)
+	}
+
+	// 期限前の注文はキャンセルしない
+	if canceled, err := service.CancelExpiredPending(ctx, order.CreatedAt.Add(-time.Second)); err != nil || canceled != 0 {
+		t.Fatalf("CancelExpiredPending(before creation) = (%d, %v), want (0, nil)", canceled, err)
+	}
+	if canceled, err := service.CancelExpiredPending(ctx, time.Now()); err != nil || canceled != 1 {
+		t.Fatalf("CancelExpiredPending = (%d, %v), want (1, nil)", canceled, err)
+	}
+	if _, _, err := service.Purchase(ctx, itemId, otherId, dto.PurchaseInput{}); err != nil {
+		t.Fatalf("Purchase after expiry: %v", err)
+	}
+}
+
+// 決済プロバイダへの依頼を記録する。
+// 依頼の時点で注文がどう見えるかも記録し、注文のロックを外してから依頼していることを確認できるようにする
+type recordingGateway struct {
+	payments.PaymentGateway
+	orders  repositories.IOrderRepository
+	orderId uint
+	fail    error // 次の依頼を1回だけ失敗させる
+	calls   []string
+}
+
+func (g *recordingGateway) Capture(ctx context.Context, intentId string) (*payments.PaymentIntent, error) {
+	if err := g.record("Capture"); err != nil {
+		return nil, err
+	}
+	return g.PaymentGateway.Capture(ctx, intentId)
+}
+
+func (g *recordingGateway) Refund(ctx context.Context, intentId string) (*payments.PaymentIntent, error) {
+	if err := g.record("Refund"); err != nil {
+		return nil, err
+	}
+	return g.PaymentGateway.Refund(ctx, intentId)
+}
+
+func (g *recordingGateway) Void(ctx context.Context, intentId string) (*payments.PaymentIntent, error) {
+	if err := g.record("Void"); err != nil {
+		return nil, err
+	}
+	return g.PaymentGateway.Void(ctx, intentId)
+}
+
+// 「メソッド名:その時点の注文のステータス」を記録する。ロックを持ったまま呼ばれると注文を読めないので「locked」になる
+func (g *recordingGateway) record(method string) error {
+	seen := make(chan string, 1)
+	go func() {
+		order, err := g.orders.FindById(context.Background(), g.orderId)
+		if err != nil {
+			seen <- err.Error()
+			return
+		}
+		seen <- order.Status
+	}()
+	select {
+	case status := <-seen:
+		g.calls = append(g.calls, method+":"+status)
+	case <-time.After(time.Second):
+		g.calls = append(g.calls, method+":locked")
+	}
+
+	err := g.fail
+	g.fail = nil
+	return err
+}
+
+// 支払い待ちの注文と、その注文へのWebhookを処理するサービス
+type paymentFixture struct {
+	service services.IOrderService
+	gateway *recordingGateway
+	items   repositories.IItemRepository
+	order   *models.Order
+}
+
+func newPaymentFixture(t *testing.T, sellerId uint, buyerId uint) paymentFixture {
+	t.Helper()
+	store := repositories.NewMemoryStore()
+	itemId := createItem(t, store, sellerId)
+	registerAddress(t, store, buyerId)
+	gateway := &recordingGateway{PaymentGateway: payments.NewFakePaymentGateway(), orders: repositories.NewOrderMemoryRepository(store)}
+	service := newOrderService(store, gateway)
+
+	order, _, err := service.Purchase(context.Background(), itemId, buyerId, dto.PurchaseInput{})
+	if err != nil {
+		t.Fatalf("Purchase: %v", err)
+	}
+	gateway.orderId = order.ID
+	return paymentFixture{service: service, gateway: gateway, items: repositories.NewItemMemoryRepository(store), order: order}
+}
+
+// 決済プロバイダからのWebhookを処理する
+func (f paymentFixture) send(id string, eventType string) error {
+	event := payments.WebhookEvent{ID: id, Type: eventType}
+	event.Data.PaymentIntentId = f.order.PaymentIntentId
+	return f.service.HandlePaymentEvent(context.Background(), event)
+}
+
+func (f paymentFixture) assertState(t *testing.T, wantStatus string, wantSoldOut bool, wantCalls ...string) {
+	t.Helper()
+	order, err := f.service.FindById(context.Background(), f.order.ID, f.order.BuyerId)
+	if err != nil {
+		t.Fatalf("FindById: %v", err)
+	}
+	item, err := f.items.FindById(context.Background(), f.order.ItemId)
+	if err != nil {
+		t.Fatalf("FindById(item): %v", err)
+	}
+	if order.Status != wantStatus || item.SoldOut != wantSoldOut {
+		t.Fatalf("(Status, SoldOut) = (%q, %v), want (%q, %v)", order.Status, item.SoldOut, wantStatus, wantSoldOut)
+	}
+	if !slices.Equal(f.gateway.calls, wantCalls) {
+		t.Fatalf("gateway calls = %v, want %v", f.gateway.calls, wantCalls)
+	}
+}
+
+// 決済プロバイダへの確定・返金の依頼は注文を保存してから行い、結果はWebhookで反映する
+func TestOrderServicePaymentEvents(t *testing.T) {
+	const sellerId, buyerId = 1, 2
+	ctx := context.Background()
+
+	t.Run("authorization requests the capture and the succeeded event marks the order paid", func(t *testing.T) {
+		f := newPaymentFixture(t, sellerId, buyerId)
+		if err := f.send("evt_1", payments.EventPaymentAuthorized); err != nil {
+			t.Fatalf("authorized: %v", err)
+		}
+		f.assertState(t, models.OrderStatusCaptureRequested, false, "Capture:capture_requested")
+
+		if err := f.send("evt_2", payments.EventPaymentSucceeded); err != nil {
+			t.Fatalf("succeeded: %v", err)
+		}
+		f.assertState(t, models.OrderStatusPaid, true, "Capture:capture_requested")
+	})
+
+	t.Run("a failed capture is requested again when the event is redelivered", func(t *testing.T) {
+		f := newPaymentFixture(t, sellerId, buyerId)
+		f.gateway.fail = errors.New("provider unavailable")
+		if err := f.send("evt_1", payments.EventPaymentAuthorized); err == nil {
+			t.Fatal("authorized succeeded, want the capture error so that the provider redelivers it")
+		}
+		f.assertState(t, models.OrderStatusCaptureRequested, false, "Capture:capture_requested")
+
+		if err := f.send("evt_1", payments.EventPaymentAuthorized); err != nil {
+			t.Fatalf("redelivered authorized: %v", err)
+		}
+		f.assertState(t, models.OrderStatusCaptureRequested, false, "Capture:capture_requested", "Capture:capture_requested")
+	})
+
+	t.Run("an authorization for an expired order is voided", func(t *testing.T) {
+		f := newPaymentFixture(t, sellerId, buyerId)
+		if _, err := f.service.CancelExpiredPending(ctx, time.Now()); err != nil {
+			t.Fatalf("CancelExpiredPending: %v", err)
+		}
+		if err := f.send("evt_1", payments.EventPaymentAuthorized); err != nil {
+			t.Fatalf("authorized: %v", err)
+		}
+		f.assertState(t, models.OrderStatusCanceled, false, "Void:canceled")
+	})
+
+	t.Run("cancel after payment requests the refund and the refunded event releases the item", func(t *testing.T) {
+		f := newPaymentFixture(t, sellerId, buyerId)
+		for _, event := range []struct{ id, eventType string }{{"evt_1", payments.EventPaymentAuthorized}, {"evt_2", payments.EventPaymentSucceeded}} {
+			if err := f.send(event.id, event.eventType); err != nil {
+				t.Fatalf("%s: %v", event.eventType, err)
+			}
+		}
+
+		if _, err := f.service.Cancel(ctx, f.order.ID, buyerId); !errors.Is(err, services.ErrForbidden) {
+			t.Fatalf("Cancel by the buyer err = %v, want ErrForbidden", err)
+		}
+		f.gateway.fail = errors.New("provider unavailable")
+		if _, err := f.service.Cancel(ctx, f.order.ID, sellerId); err == nil {
+			t.Fatal("Cancel succeeded, want the refund error")
+		}
+		// 返金の依頼に失敗しても、もう一度取り消せば依頼し直す
+		order, err := f.service.Cancel(ctx, f.order.ID, sellerId)
+		if err != nil {
+			t.Fatalf("Cancel: %v", err)
+		}
+		if order.Status != models.OrderStatusRefundRequested {
+			t.Fatalf("Status = %q, want %q", order.Status, models.OrderStatusRefundRequested)
+		}
+		f.assertState(t, models.OrderStatusRefundRequested, true, "Capture:capture_requested", "Refund:refund_requested", "Refund:refund_requested")
+
+		if err := f.send("evt_3", payments.EventPaymentRefunded); err != nil {
+			t.Fatalf("refunded: %v", err)
+		}
+		f.assertState(t, models.OrderStatusRefunded, false, "Capture:capture_requested", "Refund:refund_requested", "Refund:refund_requested")
+	})
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"gin-freemarket/logging"
+	"log/slog"
+	"time"
+)
+
+// 作成から一定期間が過ぎても支払われない注文を定期的にキャンセルするバックグラウンド処理
+// 支払い待ちの注文があると他のユーザーは商品を買えないので、放置された注文で商品が売れなくならないようにする
+type PendingOrderCanceler struct {
+	periodicWorker
+	orderService IOrderService
+	ttl          time.Duration
+}
+
+func NewPendingOrderCanceler(orderService IOrderService, ttl time.Duration, interval time.Duration) *PendingOrderCanceler {
+	c := &PendingOrderCanceler{orderService: orderService, ttl: ttl}
+	c.periodicWorker = periodicWorker{interval: interval, run: c.cancelExpired}
+	return c
+}
+
+func (c *PendingOrderCanceler) cancelExpired(ctx context.Context, now time.Time) {
+	canceled, err := c.orderService.CancelExpiredPending(ctx, now.Add(-c.ttl))
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to cancel expired pending orders", slog.Any("error", err))
+	}
+	if canceled > 0 {
+		logging.FromContext(ctx).Info("Canceled expired pending orders", slog.Int("count", canceled))
+	}
+}
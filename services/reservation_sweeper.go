@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"gin-freemarket/logging"
+	"gin-freemarket/repositories"
+	"log/slog"
+	"time"
+)
+
+// 期限切れのホールドを定期的に解放するバックグラウンド処理
+type ReservationSweeper struct {
+	periodicWorker
+	repository repositories.IReservationRepository
+}
+
+func NewReservationSweeper(repository repositories.IReservationRepository, interval time.Duration) *ReservationSweeper {
+	s := &ReservationSweeper{repository: repository}
+	s.periodicWorker = periodicWorker{interval: interval, run: s.sweep}
+	return s
+}
+
+func (s *ReservationSweeper) sweep(ctx context.Context, now time.Time) {
+	released, err := s.repository.DeleteExpired(ctx, now)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to release expired reservations", slog.Any("error", err))
+		return
+	}
+	if released > 0 {
+		logging.FromContext(ctx).Info("Released expired reservations", slog.Int64("count", released))
+	}
+}
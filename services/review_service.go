@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+)
+
+var ErrReviewAlreadyExists = errors.New("Review already exists")
+
+type IReviewService interface {
+	// 取引が完了した注文について、購入者または出品者（reviewerId）が取引相手を評価する
+	// 注文の当事者でなければErrForbidden、取引が完了していなければErrOrderStatus、評価済みならErrReviewAlreadyExists
+	Create(ctx context.Context, orderId uint, reviewerId uint, input dto.CreateReviewInput) (*models.Review, error)
+	// userIdが受けた評価を新しい順に返す
+	FindByUser(ctx context.Context, userId uint) (*[]models.Review, error)
+}
+
+type ReviewService struct {
+	repository   repositories.IReviewRepository
+	orderService IOrderService
+}
+
+func NewReviewService(repository repositories.IReviewRepository, orderService IOrderService) IReviewService {
+	return &ReviewService{repository: repository, orderService: orderService}
+}
+
+func (s *ReviewService) Create(ctx context.Context, orderId uint, reviewerId uint, input dto.CreateReviewInput) (*models.Review, error) {
+	// 注文の当事者かどうかはOrderServiceが確認する
+	order, err := s.orderService.FindById(ctx, orderId, reviewerId)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.OrderStatusCompleted {
+		return nil, ErrOrderStatus
+	}
+
+	revieweeId := order.SellerId
+	if reviewerId == order.SellerId {
+		revieweeId = order.BuyerId
+	}
+	newReview := models.Review{
+		OrderId:    order.ID,
+		ReviewerId: reviewerId,
+		RevieweeId: revieweeId,
+		Rating:     input.Rating,
+		Comment:    input.Comment,
+	}
+	createdReview, err := s.repository.Create(ctx, newReview)
+	if err != nil {
+		if err.Error() == "Review already exists" {
+			return nil, ErrReviewAlreadyExists
+		}
+		return nil, err
+	}
+	return createdReview, nil
+}
+
+func (s *ReviewService) FindByUser(ctx context.Context, userId uint) (*[]models.Review, error) {
+	return s.repository.FindByReviewee(ctx, userId)
+}
@@ -0,0 +1,95 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/payments"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"testing"
+)
+
+const (
+	reviewSellerId   uint = 1
+	reviewBuyerId    uint = 2
+	reviewStrangerId uint = 3
+)
+
+// 出品者1の商品を購入者2が購入し、注文をstatusにしたものを用意する
+func newReviewService(t *testing.T, status string) (services.IReviewService, uint) {
+	t.Helper()
+	ctx := context.Background()
+	store := repositories.NewMemoryStore()
+	itemId := createItem(t, store, reviewSellerId)
+	registerAddress(t, store, reviewBuyerId)
+	orderService := newOrderService(store, payments.NewFakePaymentGateway())
+
+	order, _, err := orderService.Purchase(ctx, itemId, reviewBuyerId, dto.PurchaseInput{})
+	if err != nil {
+		t.Fatalf("Purchase: %v", err)
+	}
+	order.Status = status
+	if _, err := repositories.NewOrderMemoryRepository(store).Update(ctx, *order); err != nil {
+		t.Fatalf("Update order: %v", err)
+	}
+	return services.NewReviewService(repositories.NewReviewMemoryRepository(store), orderService), order.ID
+}
+
+func TestReviewServiceCreate(t *testing.T) {
+	ctx := context.Background()
+	input := dto.CreateReviewInput{Rating: 5, Comment: "thanks"}
+
+	cases := []struct {
+		name         string
+		status       string
+		reviewerId   uint
+		wantReviewee uint
+		err          error
+	}{
+		{"buyer reviews the seller", models.OrderStatusCompleted, reviewBuyerId, reviewSellerId, nil},
+		{"seller reviews the buyer", models.OrderStatusCompleted, reviewSellerId, reviewBuyerId, nil},
+		{"stranger cannot review", models.OrderStatusCompleted, reviewStrangerId, 0, services.ErrForbidden},
+		{"delivered order is not reviewable yet", models.OrderStatusDelivered, reviewBuyerId, 0, services.ErrOrderStatus},
+		{"canceled order is not reviewable", models.OrderStatusCanceled, reviewBuyerId, 0, services.ErrOrderStatus},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, orderId := newReviewService(t, tc.status)
+
+			review, err := service.Create(ctx, orderId, tc.reviewerId, input)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if tc.err != nil {
+				return
+			}
+			if review.OrderId != orderId || review.ReviewerId != tc.reviewerId || review.RevieweeId != tc.wantReviewee || review.Rating != 5 {
+				t.Fatalf("review = %+v, want a review of user %d by user %d", review, tc.wantReviewee, tc.reviewerId)
+			}
+
+			reviews, err := service.FindByUser(ctx, tc.wantReviewee)
+			if err != nil {
+				t.Fatalf("FindByUser: %v", err)
+			}
+			if len(*reviews) != 1 || (*reviews)[0].ID != review.ID {
+				t.Fatalf("FindByUser = %+v, want only the created review", *reviews)
+			}
+		})
+	}
+
+	t.Run("second review of the same order returns ErrReviewAlreadyExists", func(t *testing.T) {
+		service, orderId := newReviewService(t, models.OrderStatusCompleted)
+		if _, err := service.Create(ctx, orderId, reviewBuyerId, input); err != nil {
+			t.Fatalf("first Create: %v", err)
+		}
+		if _, err := service.Create(ctx, orderId, reviewBuyerId, input); !errors.Is(err, services.ErrReviewAlreadyExists) {
+			t.Fatalf("second Create err = %v, want ErrReviewAlreadyExists", err)
+		}
+		// 取引相手は別に評価できる
+		if _, err := service.Create(ctx, orderId, reviewSellerId, input); err != nil {
+			t.Fatalf("Create by seller: %v", err)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-freemarket/carriers"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"time"
+)
+
+var (
+	ErrUnknownCarrier         = errors.New("Unknown carrier")
+	ErrInvalidTrackingNumber  = errors.New("Invalid tracking number")
+	ErrInvalidTrackingPayload = errors.New("Invalid tracking payload")
+)
+
+// 配送状況の進み具合。Webhookは順不同で届くので、古いイベントで状況を戻さないために使う
+// exceptionは進み具合ではないので0にし、新しいイベントのときだけ反映する
+var shipmentStatusRank = map[string]int{
+	models.ShipmentStatusException:      0,
+	models.ShipmentStatusShipped:        1,
+	models.ShipmentStatusInTransit:      2,
+	models.ShipmentStatusOutForDelivery: 3,
+	models.ShipmentStatusDelivered:      4,
+}
+
+type IShipmentService interface {
+	// 出品者が発送を登録する。注文はshippedになる
+	Ship(ctx context.Context, orderId uint, userId uint, shipInput dto.ShipInput) (*models.Order, error)
+	// 運送会社からのWebhookを反映する。配達完了の連絡で注文はdeliveredになる
+	HandleTrackingWebhook(ctx context.Context, carrierName string, body []byte) error
+}
+
+type ShipmentService struct {
+	repository      repositories.IShipmentRepository
+	orderRepository repositories.IOrderRepository
+	carriers        map[string]carriers.Carrier
+}
+
+func NewShipmentService(repository repositories.IShipmentRepository, orderRepository repositories.IOrderRepository, availableCarriers []carriers.Carrier) IShipmentService {
+	registered := map[string]carriers.Carrier{}
+	for _, v := range availableCarriers {
+		registered[v.Name()] = v
+	}
+	return &ShipmentService{repository: repository, orderRepository: orderRepository, carriers: registered}
+}
+
+func (s *ShipmentService) Ship(ctx context.Context, orderId uint, userId uint, shipInput dto.ShipInput) (*models.Order, error) {
+	carrier, ok := s.carriers[shipInput.Carrier]
+	if !ok {
+		return nil, ErrUnknownCarrier
+	}
+	if err := carrier.ValidateTrackingNumber(shipInput.TrackingNumber); err != nil {
+		return nil, ErrInvalidTrackingNumber
+	}
+
+	now := time.Now()
+	return s.orderRepository.Transition(ctx, orderId, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		if order.SellerId != userId {
+			return nil, ErrForbidden
+		}
+		if order.Status != models.OrderStatusPaid {
+			return nil, ErrOrderStatus
+		}
+
+		order.Status = models.OrderStatusShipped
+		order.Shipment = &models.Shipment{
+			OrderId:        order.ID,
+			Carrier:        carrier.Name(),
+			TrackingNumber: shipInput.TrackingNumber,
+			Status:         models.ShipmentStatusShipped,
+			Events: []models.ShipmentEvent{{
+				ExternalId: fmt.Sprintf("order:%d:shipped", order.ID),
+				Status:     models.ShipmentStatusShipped,
+				OccurredAt: now,
+			}},
+		}
+		return nil, nil
+	})
+}
+
+func (s *ShipmentService) HandleTrackingWebhook(ctx context.Context, carrierName string, body []byte) error {
+	carrier, ok := s.carriers[carrierName]
+	if !ok {
+		return ErrUnknownCarrier
+	}
+
+	updates, err := carrier.ParseWebhook(body)
+	if err != nil {
+		return ErrInvalidTrackingPayload
+	}
+
+	for _, update := range updates {
+		event := models.ShipmentEvent{
+			ExternalId:  update.EventId,
+			Status:      update.Status,
+			Description: update.Description,
+			OccurredAt:  update.OccurredAt,
+		}
+		_, err := s.repository.ApplyTrackingEvent(ctx, carrier.Name(), update.TrackingNumber, event, func(shipment *models.Shipment, latest *models.ShipmentEvent, order *models.Order, item *models.Item) error {
+			// 記録済みのイベントより古いイベントは、状況が先に進む場合だけ反映する（履歴には残る）
+			if latest == nil || !update.OccurredAt.Before(latest.OccurredAt) ||
+				shipmentStatusRank[update.Status] > shipmentStatusRank[shipment.Status] {
+				shipment.Status = update.Status
+			}
+
+			// 配達完了の連絡で注文を進める。ここから猶予期間が過ぎると自動的に取引完了になる
+			if update.Status == models.ShipmentStatusDelivered && order.Status == models.OrderStatusShipped {
+				deliveredAt := update.OccurredAt
+				order.Status = models.OrderStatusDelivered
+				order.DeliveredAt = &deliveredAt
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"gin-freemarket/carriers"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+	"gin-freemarket/services"
+	"testing"
+	"time"
+)
+
+// 発送済みの注文を用意する
+func shippedOrder(t *testing.T, store *repositories.MemoryStore, service services.IShipmentService, trackingNumber string) uint {
+	t.Helper()
+	const sellerId, buyerId = 1, 2
+	ctx := context.Background()
+	itemId := createItem(t, store, sellerId)
+	orders := repositories.NewOrderMemoryRepository(store)
+	order, err := orders.Create(ctx, models.Order{ItemId: itemId, BuyerId: buyerId, SellerId: sellerId, Price: 1000}, time.Now(),
+		func(*models.Order, *models.Item, []models.Order, *models.Reservation, *models.Offer) error {
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Create order: %v", err)
+	}
+	if _, err := orders.Transition(ctx, order.ID, func(order *models.Order, item *models.Item) (*models.LedgerTransaction, error) {
+		order.Status = models.OrderStatusPaid
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("Transition to paid: %v", err)
+	}
+	if _, err := service.Ship(ctx, order.ID, sellerId, dto.ShipInput{Carrier: "fake", TrackingNumber: trackingNumber}); err != nil {
+		t.Fatalf("Ship: %v", err)
+	}
+	return order.ID
+}
+
+func trackingWebhook(trackingNumber string, id string, status string, occurredAt time.Time) []byte {
+	return []byte(fmt.Sprintf(`{"events": [{"id": %q, "tracking_number": %q, "status": %q, "occurred_at": %q}]}`,
+		id, trackingNumber, status, occurredAt.UTC().Format(time.RFC3339)))
+}
+
+// Webhookが順不同で届いても、古いイベントで配送状況が戻らない
+func TestShipmentServiceOutOfOrderWebhooks(t *testing.T) {
+	const trackingNumber = "FAKE000000001"
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		events [][]byte
+		want   string
+	}{
+		{
+			name: "a late in_transit does not undo out_for_delivery",
+			events: [][]byte{
+				trackingWebhook(trackingNumber, "evt_2", models.ShipmentStatusOutForDelivery, now.Add(2*time.Hour)),
+				trackingWebhook(trackingNumber, "evt_1", models.ShipmentStatusInTransit, now.Add(time.Hour)),
+			},
+			want: models.ShipmentStatusOutForDelivery,
+		},
+		{
+			name: "a late exception does not undo delivered",
+			events: [][]byte{
+				trackingWebhook(trackingNumber, "evt_2", models.ShipmentStatusDelivered, now.Add(2*time.Hour)),
+				trackingWebhook(trackingNumber, "evt_1", models.ShipmentStatusException, now.Add(time.Hour)),
+			},
+			want: models.ShipmentStatusDelivered,
+		},
+		{
+			name: "a late delivered still advances the shipment",
+			events: [][]byte{
+				trackingWebhook(trackingNumber, "evt_2", models.ShipmentStatusException, now.Add(2*time.Hour)),
+				trackingWebhook(trackingNumber, "evt_1", models.ShipmentStatusDelivered, now.Add(time.Hour)),
+			},
+			want: models.ShipmentStatusDelivered,
+		},
+		{
+			name: "a newer exception is applied",
+			events: [][]byte{
+				trackingWebhook(trackingNumber, "evt_1", models.ShipmentStatusOutForDelivery, now.Add(time.Hour)),
+				trackingWebhook(trackingNumber, "evt_2", models.ShipmentStatusException, now.Add(2*time.Hour)),
+			},
+			want: models.ShipmentStatusException,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := repositories.NewMemoryStore()
+			orders := repositories.NewOrderMemoryRepository(store)
+			service := services.NewShipmentService(repositories.NewShipmentMemoryRepository(store), orders, []carriers.Carrier{carriers.NewFakeCarrier()})
+			orderId := shippedOrder(t, store, service, trackingNumber)
+
+			for _, body := range tt.events {
+				if err := service.HandleTrackingWebhook(ctx, "fake", body); err != nil {
+					t.Fatalf("HandleTrackingWebhook: %v", err)
+				}
+			}
+
+			order, err := orders.FindById(ctx, orderId)
+			if err != nil {
+				t.Fatalf("FindById: %v", err)
+			}
+			if order.Shipment == nil || order.Shipment.Status != tt.want {
+				t.Fatalf("Shipment = %+v, want status %q", order.Shipment, tt.want)
+			}
+		})
+	}
+}
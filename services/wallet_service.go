@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-freemarket/dto"
+	"gin-freemarket/models"
+	"gin-freemarket/repositories"
+)
+
+var ErrInsufficientBalance = errors.New("Insufficient balance")
+
+// 出品者の売上残高（円単位）
+type Wallet struct {
+	Available uint `json:"available"` // 出金可能な売上
+	Pending   uint `json:"pending"`   // 取引完了待ちの売上
+}
+
+type IWalletService interface {
+	GetWallet(ctx context.Context, userId uint) (*Wallet, error)
+	RequestPayout(ctx context.Context, userId uint, payoutInput dto.PayoutInput) (*models.Payout, error)
+}
+
+type WalletService struct {
+	repository repositories.ILedgerRepository
+}
+
+func NewWalletService(repository repositories.ILedgerRepository) IWalletService {
+	return &WalletService{repository: repository}
+}
+
+func (s *WalletService) GetWallet(ctx context.Context, userId uint) (*Wallet, error) {
+	available, err := s.repository.Balance(ctx, sellerAvailableAccount(userId).Name)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := s.repository.Balance(ctx, sellerPendingAccount(userId).Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// 出品者の勘定は貸方残なので、仕訳が正しく組まれていれば負にはならない
+	if available < 0 || pending < 0 {
+		return nil, ErrLedgerUnbalanced
+	}
+	return &Wallet{Available: uint(available), Pending: uint(pending)}, nil
+}
+
+func (s *WalletService) RequestPayout(ctx context.Context, userId uint, payoutInput dto.PayoutInput) (*models.Payout, error) {
+	newPayout := models.Payout{
+		UserId: userId,
+		Amount: payoutInput.Amount,
+		Status: models.PayoutStatusRequested,
+	}
+	account := sellerAvailableAccount(userId)
+
+	return s.repository.CreatePayout(ctx, newPayout, account.Name, func(balance int64) (*models.LedgerTransaction, error) {
+		if int64(payoutInput.Amount) > balance {
+			return nil, ErrInsufficientBalance
+		}
+		return newLedgerTransaction(fmt.Sprintf("payout:user:%d", userId), "payout requested",
+			debit(account, payoutInput.Amount),
+			credit(models.LedgerAccount{Name: accountPlatformPayouts}, payoutInput.Amount),
+		)
+	})
+}
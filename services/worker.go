@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 一定間隔で処理を実行するバックグラウンド処理の共通部分
+// Startでgoroutineを起動し、Stopで実行中の処理が終わるまで待ち合わせる
+type periodicWorker struct {
+	interval time.Duration
+	run      func(ctx context.Context, now time.Time)
+	cancel   context.CancelFunc
+	done     chan struct{}
+	running  atomic.Bool
+}
+
+func (w *periodicWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.done = make(chan struct{})
+	w.running.Store(true)
+
+	go func() {
+		// goroutineを抜けるときに、Stopで待っている側へ終了を知らせる
+		defer close(w.done)
+		defer w.running.Store(false)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				// Stopで止めても実行中の処理は最後まで終わらせたいので、キャンセルは引き継がない
+				w.run(context.WithoutCancel(ctx), now)
+			}
+		}
+	}()
+}
+
+// goroutineを止め、実行中の処理が終わるまで待つ
+func (w *periodicWorker) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+}
+
+// goroutineが動いているかどうか（readyzのチェックで使う）
+func (w *periodicWorker) Running() bool {
+	return w.running.Load()
+}
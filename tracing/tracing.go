@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// スパンを作るときの計装名（Tracerの名前）
+const instrumentationName = "gin-freemarket"
+
+// エクスポーター（トレースの送り先）の種類
+const (
+	ExporterNone   = "none"   // トレースを出力しない
+	ExporterStdout = "stdout" // 標準出力（Fileを指定した場合はファイル）にJSONで出力する。ローカル確認用
+	ExporterOTLP   = "otlp"   // OTLP/HTTPでコレクターに送る
+)
+
+type Options struct {
+	Exporter       string
+	File           string // ExporterStdoutのときの出力先。空なら標準出力
+	OTLPEndpoint   string // ExporterOTLPのときの送り先（例: http://localhost:4318）。空なら環境変数OTEL_EXPORTER_OTLP_ENDPOINTに従う
+	ServiceName    string
+	ServiceVersion string
+}
+
+// グローバルなTracerProviderとプロパゲーターを設定する
+// 戻り値の関数は終了時に呼び出し、バッファに残っているスパンを送り切る
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	// 受け取ったリクエストのtraceparentヘッダーを引き継げるようにする
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var closer io.Closer
+	switch opts.Exporter {
+	case "", ExporterNone:
+		// TracerProviderを設定しなければ、スパンは何もしない実装（noop）になる
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		var w io.Writer = os.Stdout
+		if opts.File != "" {
+			f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open trace file: %w", err)
+			}
+			w, closer = f, f
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	case ExporterOTLP:
+		var options []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(opts.OTLPEndpoint))
+		}
+		e, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter: %s", opts.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(opts.ServiceName),
+		semconv.ServiceVersion(opts.ServiceVersion),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// アプリケーション共通のTracerを返す
+// グローバルなTracerProviderに委譲するので、Setupより前に呼んでも問題ない
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
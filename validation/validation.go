@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ja"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	ja_translations "github.com/go-playground/validator/v10/translations/ja"
+)
+
+// 項目ごとのエラー
+type FieldError struct {
+	// JSONのキー名（例: "name"、"price"）
+	Field string `json:"field"`
+	// エラーになったルール（例: "required"、"min"）
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// 対応している言語。Accept-Languageに一致するものがなければ英語になる
+var uni = ut.New(en.New(), en.New(), ja.New())
+
+// 検証エラー以外でボディを読めなかった場合のメッセージ
+var messages = map[string]map[string]string{
+	"en": {
+		"invalid":   "The request body is invalid",
+		"malformed": "The request body is not valid JSON",
+		"type":      "{0} has an invalid type",
+		"unknown":   "{0} is not a known field",
+	},
+	"ja": {
+		"invalid":   "リクエストの内容に誤りがあります",
+		"malformed": "リクエストの本文が正しいJSONではありません",
+		"type":      "{0}の型が正しくありません",
+		"unknown":   "{0}という項目はありません",
+	},
+}
+
+// ginのバリデータにJSONのキー名と各言語のメッセージを登録する。起動時に1回だけ呼ぶ
+func Setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	// エラーの項目名を構造体のフィールド名（Name）ではなくJSONのキー名（name）にする
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	enTrans, _ := uni.GetTranslator("en")
+	if err := en_translations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return err
+	}
+	jaTrans, _ := uni.GetTranslator("ja")
+	if err := ja_translations.RegisterDefaultTranslations(v, jaTrans); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Accept-Languageヘッダーの値から、使う言語の翻訳を選ぶ
+// 例: "ja-JP,ja;q=0.9,en;q=0.8" → ja
+func Translator(acceptLanguage string) ut.Translator {
+	var locales []string
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		// 地域（-JPなど）は見ず、言語だけで判断する
+		lang, _, _ := strings.Cut(tag, "-")
+		if lang != "" {
+			locales = append(locales, strings.ToLower(lang))
+		}
+	}
+	trans, _ := uni.FindTranslator(locales...)
+	return trans
+}
+
+// ShouldBindJSONのエラーを、レスポンス用の説明と項目ごとのエラーに変換する
+func Describe(err error, trans ut.Translator) (string, []FieldError) {
+	msgs := messages[trans.Locale()]
+
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		fields := make([]FieldError, 0, len(validationErrors))
+		for _, fe := range validationErrors {
+			fields = append(fields, FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: fe.Translate(trans),
+			})
+		}
+		return msgs["invalid"], fields
+	}
+
+	// 数値の項目に文字列が送られてきた場合など
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) {
+		field := typeError.Field
+		if i := strings.LastIndex(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		return msgs["invalid"], []FieldError{{
+			Field:   field,
+			Rule:    "type",
+			Message: strings.ReplaceAll(msgs["type"], "{0}", field),
+		}}
+	}
+
+	// json.DecoderのDisallowUnknownFieldsで、定義されていないキーが送られてきた場合（エラーの型はない）
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field := strings.Trim(name, `"`)
+		return msgs["invalid"], []FieldError{{
+			Field:   field,
+			Rule:    "unknown",
+			Message: strings.ReplaceAll(msgs["unknown"], "{0}", field),
+		}}
+	}
+
+	var syntaxError *json.SyntaxError
+	if errors.As(err, &syntaxError) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return msgs["malformed"], nil
+	}
+	return msgs["invalid"], nil
+}